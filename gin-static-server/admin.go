@@ -0,0 +1,119 @@
+package ginstatic
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CacheEntryInfo 单个缓存条目的摘要
+type CacheEntryInfo struct {
+	Path       string    `json:"path"`                 // 请求路径，如 "/js/app.js"
+	Size       int64     `json:"size"`                 // 原始内容大小（字节）
+	GzipSize   int       `json:"gzipSize,omitempty"`   // Gzip 版本大小，没有时为 0
+	BrotliSize int       `json:"brotliSize,omitempty"` // Brotli 版本大小，没有时为 0
+	Hits       uint64    `json:"hits"`                 // 由缓存直接返回内容的次数
+	LastAccess time.Time `json:"lastAccess"`           // 最后访问时间
+	ModTime    time.Time `json:"modTime"`              // 文件修改时间
+	ETag       string    `json:"etag"`
+}
+
+// CacheEntries 返回当前引擎（挂载点只含自身）的缓存条目，按路径排序
+func (e *StaticEngine) CacheEntries() []CacheEntryInfo {
+	var entries []CacheEntryInfo
+	e.cache.entries.Range(func(key, _ interface{}) bool {
+		path, ok := strings.CutPrefix(key.(string), e.cacheNamespace)
+		if !ok || (e.cacheNamespace == "" && strings.HasPrefix(path, "mount:")) {
+			return true
+		}
+		ce, ok := e.cache.peek(key.(string))
+		if !ok {
+			return true
+		}
+		entries = append(entries, CacheEntryInfo{
+			Path:       path,
+			Size:       ce.Size,
+			GzipSize:   len(ce.Gzipped),
+			BrotliSize: len(ce.Brotli),
+			Hits:       atomic.LoadUint64(&ce.hits),
+			LastAccess: time.Unix(0, atomic.LoadInt64(&ce.LastAccess)),
+			ModTime:    ce.ModTime,
+			ETag:       ce.ETag,
+		})
+		e.cache.release(ce)
+		return true
+	})
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries
+}
+
+// EvictPath 淘汰单个路径的缓存条目，返回条目此前是否在缓存中
+// relPath: 请求路径，如 "/js/app.js"
+func (e *StaticEngine) EvictPath(relPath string) bool {
+	safe, cleanPath := IsPathTraversal(e.config.Root, strings.TrimPrefix(relPath, "/"))
+	if !safe {
+		return false
+	}
+	key := e.cacheKey(cleanPath)
+	if _, ok := e.cache.entries.Load(key); !ok {
+		return false
+	}
+	e.cache.Delete(key)
+	return true
+}
+
+// RegisterAdminRoutes 在 group 下注册缓存管理接口（JSON）：
+//
+//	GET    /cache              缓存大小、文件数、淘汰次数与条目列表
+//	DELETE /cache/entry?path=  淘汰单个路径，路径不在缓存中时返回 404
+//	POST   /cache/clear        重置缓存（ResetCache）
+//	POST   /cache/reload       重新加载缓存（ReloadCache）
+//
+// 接口不做鉴权，group 应挂载鉴权中间件或只在内网地址上注册；静态文件挂载在根路径时
+// 通配路由会与 group 冲突，此时应在独立的 gin.Engine（如内网管理端口）上注册
+func (e *StaticEngine) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/cache", func(c *gin.Context) {
+		entries := e.CacheEntries()
+		if entries == nil {
+			entries = []CacheEntryInfo{}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"size":      e.cache.Size(),
+			"files":     e.cache.FileCount(),
+			"evictions": e.cache.EvictCount(),
+			"entries":   entries,
+		})
+	})
+
+	group.DELETE("/cache/entry", func(c *gin.Context) {
+		path := c.Query("path")
+		if path == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "path required"})
+			return
+		}
+		if !e.EvictPath(path) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "entry not cached"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"evicted": path})
+	})
+
+	group.POST("/cache/clear", func(c *gin.Context) {
+		e.ResetCache()
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	group.POST("/cache/reload", func(c *gin.Context) {
+		if err := e.ReloadCache(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+}
@@ -3,8 +3,11 @@ package ginstatic
 import (
 	"crypto/md5"
 	"fmt"
+	"io/fs"
 	"os"
+	pathpkg "path"
 	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -12,30 +15,90 @@ import (
 
 // cacheEntry 缓存条目
 type cacheEntry struct {
-	Data       []byte      // 原始文件内容
-	Gzipped    []byte      // Gzip 压缩后的内容
-	ModTime    time.Time   // 文件修改时间
-	Size       int64       // 文件大小
-	ETag       string      // ETag 值
-	LastAccess int64       // 最后访问时间（Unix 时间戳）
-	Path       string      // 文件路径
-	once       sync.Once   // 确保只加载一次
-	loadErr    error       // 加载错误
+	Data       []byte    // 原始文件内容
+	Gzipped    []byte    // Gzip 压缩后的内容
+	Brotli     []byte    // Brotli 压缩后的内容
+	ModTime    time.Time // 文件修改时间
+	Size       int64     // 文件大小
+	ETag       string    // ETag 值
+	LastAccess int64     // 最后访问时间（Unix 时间戳）
+	Path       string    // 文件路径
+	once       sync.Once // 确保只加载一次
+	loadErr    error     // 加载错误
+
+	staleUntil int64  // 过期条目可继续服务的截止时间（UnixNano），0 表示未过期
+	storedAt   int64  // 写入缓存或最近一次重新校验的时间（UnixNano），用于 CacheTTL
+	hits       uint64 // 由缓存直接返回内容的次数
+
+	refs   int32 // 读者引用计数，移出缓存后附加 entryDead 标记
+	pinned int32 // 已经 Get 交给调用方，不再回收
+	pooled bool  // 来自 entryPool，移出缓存且无读者后回收
+}
+
+// entryDead 条目已移出缓存的标记位，与引用计数共用 refs
+const entryDead int32 = 1 << 30
+
+// entryPool 可复用的缓存条目，降低高淘汰率场景的 GC 压力
+var entryPool = sync.Pool{
+	New: func() interface{} {
+		return new(cacheEntry)
+	},
+}
+
+// newPooledEntry 从池中取出缓存条目
+// 此类条目只能 Set 到一个键；移出缓存且没有读者引用时自动回收
+func newPooledEntry() *cacheEntry {
+	ce := entryPool.Get().(*cacheEntry)
+	ce.pooled = true
+	return ce
+}
+
+// retireEntry 标记条目已移出缓存，调用时须持有写锁且条目已从 entries 删除
+func retireEntry(ce *cacheEntry) {
+	if !ce.pooled {
+		return
+	}
+	if atomic.AddInt32(&ce.refs, entryDead) == entryDead {
+		recycleEntry(ce)
+	}
+}
+
+// recycleEntry 重置条目并放回池中
+// 只清空字段引用，已复制出去的 Data/Gzipped/Brotli 切片仍然有效
+func recycleEntry(ce *cacheEntry) {
+	if atomic.LoadInt32(&ce.pinned) != 0 {
+		return
+	}
+	ce.Data = nil
+	ce.Gzipped = nil
+	ce.Brotli = nil
+	ce.ModTime = time.Time{}
+	ce.Size = 0
+	ce.ETag = ""
+	ce.Path = ""
+	ce.pooled = false
+	atomic.StoreInt64(&ce.LastAccess, 0)
+	atomic.StoreInt64(&ce.staleUntil, 0)
+	atomic.StoreInt64(&ce.storedAt, 0)
+	atomic.StoreUint64(&ce.hits, 0)
+	atomic.StoreInt32(&ce.refs, 0)
+	entryPool.Put(ce)
 }
 
 // Cache 内存缓存
 type Cache struct {
-	mu            sync.RWMutex
-	entries       sync.Map // map[string]*cacheEntry
-	totalSize    int64     // 当前缓存总大小
-	maxSize      int64     // 最大缓存大小
-	maxFiles     int32     // 最大缓存文件数
-	fileCount    int32     // 当前缓存文件数
-	evictCounter uint64    // 淘汰计数器
-	onEvict      func(string) // 淘汰回调
+	mu           sync.RWMutex
+	entries      sync.Map       // map[string]*cacheEntry
+	totalSize    int64          // 当前缓存总大小
+	maxSize      int64          // 最大缓存大小
+	maxFiles     int32          // 最大缓存文件数
+	fileCount    int32          // 当前缓存文件数
+	evictCounter uint64         // 淘汰计数器
+	onEvict      func(string)   // 淘汰回调
+	policy       EvictionPolicy // 淘汰策略，默认 LRU
 }
 
-// NewCache 创建新的缓存实例
+// NewCache 创建新的缓存实例，使用 LRU 淘汰最久未访问的条目
 func NewCache(maxSize int64, maxFiles int, onEvict func(string)) *Cache {
 	if maxSize <= 0 {
 		maxSize = 100 * 1024 * 1024 // 默认 100MB
@@ -48,119 +111,200 @@ func NewCache(maxSize int64, maxFiles int, onEvict func(string)) *Cache {
 		maxFiles:  int32(maxFiles),
 		onEvict:   onEvict,
 		entries:   sync.Map{},
+		policy:    NewLRUPolicy(),
 		totalSize: 0,
 		fileCount: 0,
 	}
 }
 
+// NewCacheWithPolicy 创建使用指定淘汰策略的缓存实例，policy 为 nil 时与 NewCache 相同
+// 策略实例保存键的状态，不能在多个缓存之间共享
+func NewCacheWithPolicy(maxSize int64, maxFiles int, onEvict func(string), policy EvictionPolicy) *Cache {
+	c := NewCache(maxSize, maxFiles, onEvict)
+	if policy != nil {
+		c.policy = policy
+	}
+	return c
+}
+
 // Get 获取缓存条目
+// 返回的条目不会被回收复用；内部热路径使用 acquire/release
 func (c *Cache) Get(key string) (*cacheEntry, bool) {
+	ce, ok := c.acquire(key)
+	if !ok {
+		return nil, false
+	}
+	atomic.StoreInt32(&ce.pinned, 1)
+	c.release(ce)
+	return ce, true
+}
+
+// acquire 获取缓存条目并持有引用，读取完字段后须调用 release
+// 持有引用期间条目即使被淘汰也不会被回收复用
+func (c *Cache) acquire(key string) (*cacheEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	entry, ok := c.entries.Load(key)
 	if !ok {
 		return nil, false
 	}
-	
+
 	ce := entry.(*cacheEntry)
+	if ce.pooled {
+		atomic.AddInt32(&ce.refs, 1)
+	}
 	// 更新最后访问时间
 	atomic.StoreInt64(&ce.LastAccess, time.Now().UnixNano())
+	c.policy.RecordAccess(key)
+	return ce, true
+}
+
+// peek 与 acquire 相同，但不更新最后访问时间，也不通知淘汰策略，用于只读的统计与查看
+func (c *Cache) peek(key string) (*cacheEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries.Load(key)
+	if !ok {
+		return nil, false
+	}
+	ce := entry.(*cacheEntry)
+	if ce.pooled {
+		atomic.AddInt32(&ce.refs, 1)
+	}
 	return ce, true
 }
 
+// release 释放 acquire 持有的引用，已移出缓存的最后一个读者负责回收
+func (c *Cache) release(ce *cacheEntry) {
+	if !ce.pooled {
+		return
+	}
+	if atomic.AddInt32(&ce.refs, -1) == entryDead {
+		recycleEntry(ce)
+	}
+}
+
 // Set 设置缓存条目
+// 淘汰与写入在同一把写锁内完成，并发写入不会超出大小和文件数限制
 func (c *Cache) Set(key string, entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	// 检查是否需要淘汰
-	c.evictIfNeeded(entry.Size)
-
-	// 检查缓存大小限制
-	if c.totalSize+entry.Size > c.maxSize {
-		// 尝试淘汰更多
-		c.evictIfNeeded(entry.Size)
-		if c.totalSize+entry.Size > c.maxSize {
-			// 仍然超出限制，跳过缓存
-			return
-		}
+	c.evictIfNeeded(key, entry.Size)
+
+	// 检查缓存大小限制（同名旧条目会被替换，不计入占用）
+	replacedSize, _ := c.replacedUsage(key)
+	if atomic.LoadInt64(&c.totalSize)-replacedSize+entry.Size > c.maxSize {
+		// 淘汰全部条目后仍然超出限制，跳过缓存
+		retireEntry(entry)
+		return
 	}
 
-	// 存储条目
-	oldEntry, loaded := c.entries.LoadOrStore(key, entry)
+	// 存储条目（已存在时整体替换）
+	atomic.StoreInt64(&entry.storedAt, time.Now().UnixNano())
+	oldEntry, loaded := c.entries.Swap(key, entry)
 	if loaded {
-		// 已存在，更新
+		// 已存在，扣除旧条目大小，文件数不变
 		oldCe := oldEntry.(*cacheEntry)
-		atomic.StoreInt64(&c.totalSize, atomic.LoadInt64(&c.totalSize)-oldCe.Size)
+		atomic.AddInt64(&c.totalSize, -oldCe.Size)
+		if oldCe != entry {
+			retireEntry(oldCe)
+		}
+	} else {
+		atomic.AddInt32(&c.fileCount, 1)
 	}
 
 	atomic.AddInt64(&c.totalSize, entry.Size)
-	atomic.AddInt32(&c.fileCount, 1)
+	c.policy.Add(key, entry.Size)
 }
 
 // Delete 删除缓存条目
 func (c *Cache) Delete(key string) {
-	entry, ok := c.entries.Load(key)
+	c.mu.Lock()
+	entry, ok := c.entries.LoadAndDelete(key)
 	if !ok {
+		c.mu.Unlock()
 		return
 	}
-	
+
 	ce := entry.(*cacheEntry)
-	c.entries.Delete(key)
 	atomic.AddInt64(&c.totalSize, -ce.Size)
 	atomic.AddInt32(&c.fileCount, -1)
-	
+	retireEntry(ce)
+	c.removeFromPolicy(key)
+	c.mu.Unlock()
+
 	if c.onEvict != nil {
 		c.onEvict(key)
 	}
 }
 
-// evictIfNeeded 检查并执行淘汰
-func (c *Cache) evictIfNeeded(newEntrySize int64) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
+// evictIfNeeded 检查并执行淘汰，调用时须持有写锁
+// key 已存在时旧条目将被替换，其大小和文件数不计入占用
+func (c *Cache) evictIfNeeded(key string, newEntrySize int64) {
 	// 检查文件数限制
-	for c.fileCount >= c.maxFiles && c.fileCount > 0 {
-		c.evictOldest()
+	for {
+		_, replacedCount := c.replacedUsage(key)
+		fileCount := atomic.LoadInt32(&c.fileCount) - replacedCount
+		if fileCount < c.maxFiles || fileCount <= 0 || !c.evictOldest() {
+			break
+		}
 	}
 
 	// 检查大小限制
-	for c.totalSize+newEntrySize > c.maxSize && c.totalSize > 0 {
-		if !c.evictOldest() {
+	for {
+		replacedSize, _ := c.replacedUsage(key)
+		totalSize := atomic.LoadInt64(&c.totalSize) - replacedSize
+		if totalSize+newEntrySize <= c.maxSize || totalSize <= 0 || !c.evictOldest() {
 			break
 		}
 	}
 }
 
-// evictOldest 淘汰最旧的条目
+// replacedUsage 返回 key 当前条目占用的大小和文件数，不存在时均为 0，调用时须持有写锁
+// 旧条目可能在淘汰过程中被移出，因此每轮淘汰前都要重新读取
+func (c *Cache) replacedUsage(key string) (int64, int32) {
+	entry, ok := c.entries.Load(key)
+	if !ok {
+		return 0, 0
+	}
+	return entry.(*cacheEntry).Size, 1
+}
+
+// removeFromPolicy 条目被移除时同步清理淘汰策略的状态
+func (c *Cache) removeFromPolicy(key string) {
+	if r, ok := c.policy.(evictionRemover); ok {
+		r.Remove(key)
+	}
+}
+
+// evictOldest 淘汰策略选出的条目，跳过策略中已不在缓存内的键
 // 返回是否成功淘汰
 func (c *Cache) evictOldest() bool {
-	var oldestKey string
-	var oldestEntry *cacheEntry
-	var oldestTime int64 = ^int64(0)
-
-	// 遍历所有条目找到最旧的
-	c.entries.Range(func(key, value interface{}) bool {
-		ce := value.(*cacheEntry)
-		lastAccess := atomic.LoadInt64(&ce.LastAccess)
-		if lastAccess < oldestTime {
-			oldestTime = lastAccess
-			oldestKey = key.(string)
-			oldestEntry = ce
+	for {
+		key, ok := c.policy.Evict()
+		if !ok {
+			return false
+		}
+		entry, loaded := c.entries.LoadAndDelete(key)
+		if !loaded {
+			continue
 		}
-		return true
-	})
-
-	if oldestKey == "" {
-		return false
-	}
 
-	c.entries.Delete(oldestKey)
-	c.totalSize -= oldestEntry.Size
-	c.fileCount--
-	c.evictCounter++
+		ce := entry.(*cacheEntry)
+		atomic.AddInt64(&c.totalSize, -ce.Size)
+		atomic.AddInt32(&c.fileCount, -1)
+		atomic.AddUint64(&c.evictCounter, 1)
+		retireEntry(ce)
 
-	if c.onEvict != nil {
-		c.onEvict(oldestKey)
+		if c.onEvict != nil {
+			c.onEvict(key)
+		}
+		return true
 	}
-
-	return true
 }
 
 // Clear 清空缓存
@@ -168,12 +312,14 @@ func (c *Cache) Clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.entries.Range(func(key, _ interface{}) bool {
+	c.entries.Range(func(key, value interface{}) bool {
 		c.entries.Delete(key.(string))
+		retireEntry(value.(*cacheEntry))
+		c.removeFromPolicy(key.(string))
 		return true
 	})
-	c.totalSize = 0
-	c.fileCount = 0
+	atomic.StoreInt64(&c.totalSize, 0)
+	atomic.StoreInt32(&c.fileCount, 0)
 }
 
 // Size 返回当前缓存大小
@@ -212,13 +358,12 @@ func (c *Cache) LoadFile(root, relPath string, enableGzip bool, gzipLevel int) (
 	}
 
 	// 创建缓存条目
-	entry := &cacheEntry{
-		Data:    data,
-		ModTime: info.ModTime(),
-		Size:    info.Size(),
-		ETag:    generateETag(absPath, info),
-		Path:    absPath,
-	}
+	entry := newPooledEntry()
+	entry.Data = data
+	entry.ModTime = info.ModTime()
+	entry.Size = info.Size()
+	entry.ETag = generateETag(absPath, info)
+	entry.Path = absPath
 
 	// 压缩（如启用）
 	if enableGzip && len(data) >= 1024 {
@@ -228,36 +373,90 @@ func (c *Cache) LoadFile(root, relPath string, enableGzip bool, gzipLevel int) (
 		}
 	}
 
-	// 存储到缓存
+	// 存储到缓存（条目将返回给调用方，不再回收）
+	atomic.StoreInt32(&entry.pinned, 1)
 	c.Set(relPath, entry)
 
 	return entry, nil
 }
 
-// generateETag 生成 ETag
-func generateETag(path string, info os.FileInfo) string {
-	// 使用 inode、size 和 mtime 生成 ETag
-	h := md5.New()
-	h.Write([]byte(fmt.Sprintf("%d-%d-%s", info.Size(), info.ModTime().UnixNano(), path)))
-	return fmt.Sprintf(`"%x"`, h.Sum(nil))
-}
+// PrecompressFS 遍历 fsys 的 root 子目录，将所有文件连同 Gzip 压缩版本载入新的缓存
+// 不低于 1KB 的文件才会压缩；缓存键与 ETag 与 embed 后端的请求路径一致（如 "/js/app.js"），
+// 返回的缓存可通过 WithMiddlewareCacheInstance 注入，用于测试或构建期预热
+func PrecompressFS(fsys fs.FS, root string, level int) (*Cache, error) {
+	root = strings.Trim(root, "/")
+	sub := fsys
+	if root != "" && root != "." {
+		root = pathpkg.Clean(root)
+		var err error
+		if sub, err = fs.Sub(fsys, root); err != nil {
+			return nil, fmt.Errorf("precompress %s: %w", root, err)
+		}
+	}
 
-// preloadDirectory 预加载目录下所有文件
-func (c *Cache) preloadDirectory(root string, enableGzip bool, gzipLevel int) error {
-	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+	var entries []*cacheEntry
+	var totalSize int64
+	err := fs.WalkDir(sub, ".", func(name string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		if info.IsDir() {
+		if d.IsDir() {
 			return nil
 		}
-		relPath, err := filepath.Rel(root, path)
+		data, err := fs.ReadFile(sub, name)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
 		if err != nil {
 			return err
 		}
-		_, err = c.LoadFile(root, relPath, enableGzip, gzipLevel)
-		return err
+
+		etagName := name
+		if root != "" && root != "." {
+			etagName = pathpkg.Join(root, name)
+		}
+		entry := newPooledEntry()
+		entry.Data = data
+		entry.ModTime = info.ModTime()
+		entry.Size = int64(len(data))
+		entry.ETag = generateETagEmbed(etagName, info)
+		entry.Path = "/" + name
+
+		if len(data) >= 1024 {
+			gzData, err := GzipCompress(data, GetGzipLevel(level))
+			if err != nil {
+				return fmt.Errorf("gzip %s: %w", name, err)
+			}
+			entry.Gzipped = gzData
+		}
+
+		entries = append(entries, entry)
+		totalSize += entry.Size
+		return nil
 	})
+	if err != nil {
+		for _, entry := range entries {
+			recycleEntry(entry)
+		}
+		return nil, fmt.Errorf("precompress: %w", err)
+	}
+
+	// 容量不低于默认值，并按实际内容放大，保证预热的文件不会被淘汰
+	cache := NewCache(0, max(500, len(entries)+1), nil)
+	cache.maxSize = max(cache.maxSize, totalSize)
+	for _, entry := range entries {
+		cache.Set(entry.Path, entry)
+	}
+	return cache, nil
+}
+
+// generateETag 生成 ETag
+func generateETag(path string, info os.FileInfo) string {
+	// 使用 inode、size 和 mtime 生成 ETag
+	h := md5.New()
+	h.Write([]byte(fmt.Sprintf("%d-%d-%s", info.Size(), info.ModTime().UnixNano(), path)))
+	return fmt.Sprintf(`"%x"`, h.Sum(nil))
 }
 
 // containsDotFile 检查路径是否包含点文件
@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"compress/gzip"
 	"io"
+	"strconv"
 	"sync"
 
+	"github.com/aiqoder/my-go-tools/gin-static-server/internal/compress"
+	"github.com/andybalholm/brotli"
 	"github.com/klauspost/compress/zstd"
 )
 
@@ -17,84 +20,92 @@ var gzWriterPool = sync.Pool{
 	},
 }
 
-// gzReaderPool Gzip 读取器池
-var gzReaderPool = sync.Pool{
-	New: func() interface{} {
-		return new(gzip.Reader)
-	},
-}
-
-// zstdEncoderPool Zstd 编码器池
-var zstdEncoderPool = sync.Pool{
-	New: func() interface{} {
-		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
-		return enc
-	},
-}
+// 压缩原语由 internal/compress 实现，以下为兼容原有 API 的转发
 
 // GzipCompress 使用 Gzip 压缩数据
 // level: 压缩级别 1-9
 func GzipCompress(data []byte, level int) ([]byte, error) {
-	if level < 1 {
-		level = gzip.BestSpeed
-	}
-	if level > 9 {
-		level = gzip.BestCompression
-	}
+	return compress.GzipCompress(data, level)
+}
 
-	var buf bytes.Buffer
-	writer, err := gzip.NewWriterLevel(&buf, level)
-	if err != nil {
-		return nil, err
-	}
+// GzipDecompress 解压 Gzip 数据
+func GzipDecompress(data []byte) ([]byte, error) {
+	return compress.GzipDecompress(data)
+}
 
-	_, err = writer.Write(data)
-	if err != nil {
-		writer.Close()
+// ZstdCompress 使用 Zstd 压缩数据
+func ZstdCompress(data []byte) ([]byte, error) {
+	return compress.ZstdCompress(data)
+}
+
+// BrotliCompress 使用 Brotli 压缩数据
+// level: 压缩级别 0-11，超出范围时取边界值
+func BrotliCompress(data []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	w := brotli.NewWriterLevel(&buf, GetBrotliLevel(level))
+	if _, err := w.Write(data); err != nil {
 		return nil, err
 	}
-
-	err = writer.Close()
-	if err != nil {
+	if err := w.Close(); err != nil {
 		return nil, err
 	}
-
 	return buf.Bytes(), nil
 }
 
-// GzipDecompress 解压 Gzip 数据
-func GzipDecompress(data []byte) ([]byte, error) {
-	reader := gzReaderPool.Get().(*gzip.Reader)
-	defer gzReaderPool.Put(reader)
+// BrotliDecompress 解压 Brotli 数据
+func BrotliDecompress(data []byte) ([]byte, error) {
+	return io.ReadAll(brotli.NewReader(bytes.NewReader(data)))
+}
 
-	err := reader.Reset(bytes.NewReader(data))
-	if err != nil {
-		return nil, err
-	}
+// DefaultBrotliLevel 默认的 Brotli 压缩级别，兼顾压缩率与实时压缩耗时
+const DefaultBrotliLevel = 5
 
-	return io.ReadAll(reader)
+// GetBrotliLevel 将 Brotli 压缩级别限制在 0-11
+func GetBrotliLevel(level int) int {
+	if level < brotli.BestSpeed {
+		return brotli.BestSpeed
+	}
+	if level > brotli.BestCompression {
+		return brotli.BestCompression
+	}
+	return level
 }
 
-// ZstdCompress 使用 Zstd 压缩数据
-func ZstdCompress(data []byte) ([]byte, error) {
-	encoder := zstdEncoderPool.Get().(*zstd.Encoder)
-	defer zstdEncoderPool.Put(encoder)
+// ZstdDictEncoding 使用字典压缩的 zstd 内容编码名
+// 浏览器不认识该编码，仅当客户端在 Accept-Encoding 中显式声明且持有同一字典时使用
+const ZstdDictEncoding = "zstd-dict"
 
-	encoder.Reset(bytes.NewBuffer(nil))
+// NewZstdDictEncoder 创建使用字典的 Zstd 编码器
+// dict 须为标准 zstd 字典格式（zstd --train 或 zstd.BuildDict 生成）
+func NewZstdDictEncoder(dict []byte) (*zstd.Encoder, error) {
+	return zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderDict(dict))
+}
+
+// ZstdCompressDict 使用字典压缩数据
+func ZstdCompressDict(data, dict []byte) ([]byte, error) {
+	encoder, err := NewZstdDictEncoder(dict)
+	if err != nil {
+		return nil, err
+	}
 	defer encoder.Close()
 
 	return encoder.EncodeAll(data, nil), nil
 }
 
-// ZstdDecompress 解压 Zstd 数据
-func ZstdDecompress(data []byte) ([]byte, error) {
-	dec, err := zstd.NewReader(bytes.NewReader(data))
+// ZstdDecompressDict 使用字典解压数据
+func ZstdDecompressDict(data, dict []byte) ([]byte, error) {
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderDicts(dict))
 	if err != nil {
 		return nil, err
 	}
 	defer dec.Close()
 
-	return io.ReadAll(dec)
+	return dec.DecodeAll(data, nil)
+}
+
+// ZstdDecompress 解压 Zstd 数据
+func ZstdDecompress(data []byte) ([]byte, error) {
+	return compress.ZstdDecompress(data)
 }
 
 // GetCompressedData 获取压缩后的数据
@@ -114,14 +125,63 @@ func GetCompressedData(data, gzData, zstdData []byte, acceptEncoding string) ([]
 	return data, "", false
 }
 
+// DefaultEncodingPriority 默认的内容编码优先级（服务端偏好，靠前者优先）
+// 引擎与中间件共用；客户端 q 值更高的编码优先，q 值相同时按此顺序选择
+var DefaultEncodingPriority = []string{ZstdDictEncoding, "br", "zstd", "gzip"}
+
+// negotiateEncoding 按客户端 Accept-Encoding 对服务端支持的编码排序
+// 只返回客户端接受（q > 0）的编码：q 值高者在前，q 值相同时保持 priority 中的顺序。
+// 引擎与中间件共用此函数，调用方依次尝试返回的编码，直到某个编码可用
+func negotiateEncoding(acceptEncoding string, priority []string) []string {
+	if acceptEncoding == "" {
+		return nil
+	}
+
+	var accepted []string
+	var qs []float64
+	for _, encoding := range priority {
+		q := encodingQuality(acceptEncoding, encoding)
+		if q <= 0 {
+			continue
+		}
+		// 插入排序保持稳定，q 值相同的编码维持服务端优先级
+		i := len(accepted)
+		for i > 0 && qs[i-1] < q {
+			i--
+		}
+		accepted = append(accepted[:i], append([]string{encoding}, accepted[i:]...)...)
+		qs = append(qs[:i], append([]float64{q}, qs[i:]...)...)
+	}
+	return accepted
+}
+
+// encodingQuality 返回 Accept-Encoding 中指定编码的 q 值，未声明时为 0
+func encodingQuality(acceptEncoding, encoding string) float64 {
+	encodingQ := encoding + ";q="
+
+	for _, part := range splitComma(acceptEncoding) {
+		part = stripWhitespace(part)
+		if part == encoding {
+			return 1
+		}
+		if len(part) > len(encodingQ) && part[:len(encodingQ)] == encodingQ {
+			q, err := strconv.ParseFloat(part[len(encodingQ):], 64)
+			if err != nil {
+				return 0
+			}
+			return q
+		}
+	}
+
+	return 0
+}
+
 // containsEncoding 检查是否包含指定的编码
 func containsEncoding(acceptEncoding, encoding string) bool {
 	if acceptEncoding == "" {
 		return false
 	}
 
-	// 简单检查，实际应该更严格
-	encoding = encoding + ","
 	encodingQ := encoding + ";q="
 
 	for _, part := range splitComma(acceptEncoding) {
@@ -130,9 +190,9 @@ func containsEncoding(acceptEncoding, encoding string) bool {
 			return true
 		}
 		if len(part) > len(encodingQ) && part[:len(encodingQ)] == encodingQ {
-			// 检查 q 值
-			q := part[len(encodingQ):]
-			if len(q) > 0 && q[0] > '0' {
+			// 检查 q 值，q=0 表示明确拒绝
+			q, err := strconv.ParseFloat(part[len(encodingQ):], 64)
+			if err == nil && q > 0 {
 				return true
 			}
 		}
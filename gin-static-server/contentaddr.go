@@ -0,0 +1,51 @@
+package ginstatic
+
+import (
+	"encoding/hex"
+	pathpkg "path"
+	"strings"
+)
+
+// contentAddressedCacheControl 内容寻址路径的缓存控制头，内容随哈希变化，可永久缓存
+const contentAddressedCacheControl = "public, max-age=31536000, immutable"
+
+// isContentHash 检查路径段是否为 sha256 十六进制摘要
+func isContentHash(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// resolveContentAddressed 将内容寻址路径（如 "assets/<sha256>/app.js"）还原为真实路径
+// 返回真实路径、是否为内容寻址请求，以及哈希是否与 ContentAddressed 清单一致；
+// 未启用或父目录段不是哈希时原样返回
+func (e *StaticEngine) resolveContentAddressed(path string) (string, bool, bool) {
+	if len(e.config.ContentAddressed) == 0 {
+		return path, false, true
+	}
+	dir, name := pathpkg.Split(strings.TrimPrefix(path, "/"))
+	parent, hash := pathpkg.Split(strings.TrimSuffix(dir, "/"))
+	if !isContentHash(hash) {
+		return path, false, true
+	}
+	real := parent + name
+	want, ok := e.config.ContentAddressed["/"+real]
+	if !ok || !strings.EqualFold(want, hash) {
+		return real, true, false
+	}
+	return real, true, true
+}
+
+// ContentAddressedPath 返回文件的内容寻址路径（如 "/assets/app.js" -> "/assets/<sha256>/app.js"）
+// 路径不在 ContentAddressed 清单中时返回 false；返回值不含路由前缀
+func (e *StaticEngine) ContentAddressedPath(relPath string) (string, bool) {
+	relPath = "/" + strings.TrimPrefix(relPath, "/")
+	hash, ok := e.config.ContentAddressed[relPath]
+	if !ok {
+		return "", false
+	}
+	dir, name := pathpkg.Split(relPath)
+	return dir + strings.ToLower(hash) + "/" + name, true
+}
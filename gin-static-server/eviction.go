@@ -0,0 +1,198 @@
+package ginstatic
+
+import (
+	"container/list"
+	"sync"
+)
+
+// EvictionPolicy 缓存淘汰策略
+// Cache 在条目写入时调用 Add，命中时调用 RecordAccess，需要腾出空间时调用 Evict 取得淘汰的键；
+// 命中发生在读锁下，实现须支持并发调用。实现若还提供 Remove(key string)，
+// Cache 在条目被删除或清空时调用它，否则已删除的键会在 Evict 返回时被跳过
+type EvictionPolicy interface {
+	RecordAccess(key string)
+	Add(key string, size int64)
+	Evict() (key string, ok bool)
+}
+
+// evictionRemover 可选接口：条目被 Delete/Clear 移除时同步清理策略状态
+type evictionRemover interface {
+	Remove(key string)
+}
+
+// listPolicy 基于双向链表的淘汰策略，链表头部最先淘汰
+// LRU 在访问时把键移到尾部，FIFO 只按写入顺序
+type listPolicy struct {
+	mu           sync.Mutex
+	order        *list.List
+	items        map[string]*list.Element
+	moveOnAccess bool
+}
+
+// NewLRUPolicy 创建最近最少使用淘汰策略，淘汰最久未被访问的条目
+func NewLRUPolicy() EvictionPolicy {
+	return &listPolicy{order: list.New(), items: make(map[string]*list.Element), moveOnAccess: true}
+}
+
+// NewFIFOPolicy 创建先进先出淘汰策略，按写入顺序淘汰，访问不影响顺序
+// 已存在的键重新写入时视为新条目
+func NewFIFOPolicy() EvictionPolicy {
+	return &listPolicy{order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (p *listPolicy) RecordAccess(key string) {
+	if !p.moveOnAccess {
+		return
+	}
+	p.mu.Lock()
+	if el, ok := p.items[key]; ok {
+		p.order.MoveToBack(el)
+	}
+	p.mu.Unlock()
+}
+
+func (p *listPolicy) Add(key string, _ int64) {
+	p.mu.Lock()
+	if el, ok := p.items[key]; ok {
+		p.order.MoveToBack(el)
+	} else {
+		p.items[key] = p.order.PushBack(key)
+	}
+	p.mu.Unlock()
+}
+
+func (p *listPolicy) Evict() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	el := p.order.Front()
+	if el == nil {
+		return "", false
+	}
+	key := p.order.Remove(el).(string)
+	delete(p.items, key)
+	return key, true
+}
+
+func (p *listPolicy) Remove(key string) {
+	p.mu.Lock()
+	if el, ok := p.items[key]; ok {
+		p.order.Remove(el)
+		delete(p.items, key)
+	}
+	p.mu.Unlock()
+}
+
+// lfuPolicy 最不经常使用淘汰策略
+// 按访问次数分桶，每个桶内按最近访问排序，淘汰次数最少的桶中最久未访问的键，各操作均为 O(1)
+type lfuPolicy struct {
+	mu      sync.Mutex
+	items   map[string]*lfuItem
+	buckets map[uint64]*list.List // 访问次数 -> 键链表，头部最久未访问
+	minFreq uint64
+}
+
+// lfuItem LFU 策略中单个键的状态
+type lfuItem struct {
+	key  string
+	freq uint64
+	el   *list.Element
+}
+
+// NewLFUPolicy 创建最不经常使用淘汰策略，淘汰访问次数最少的条目，次数相同时淘汰最久未访问的
+// 已存在的键重新写入时保留访问次数
+func NewLFUPolicy() EvictionPolicy {
+	return &lfuPolicy{items: make(map[string]*lfuItem), buckets: make(map[uint64]*list.List)}
+}
+
+func (p *lfuPolicy) RecordAccess(key string) {
+	p.mu.Lock()
+	if item, ok := p.items[key]; ok {
+		p.touch(item)
+	}
+	p.mu.Unlock()
+}
+
+func (p *lfuPolicy) Add(key string, _ int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.items[key]; ok {
+		return
+	}
+	item := &lfuItem{key: key, freq: 1}
+	item.el = p.bucket(1).PushBack(item)
+	p.items[key] = item
+	p.minFreq = 1
+}
+
+func (p *lfuPolicy) Evict() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.items) == 0 {
+		return "", false
+	}
+	b := p.buckets[p.minFreq]
+	item := b.Remove(b.Front()).(*lfuItem)
+	p.dropIfEmpty(item.freq)
+	delete(p.items, item.key)
+	p.resetMinFreq()
+	return item.key, true
+}
+
+func (p *lfuPolicy) Remove(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+	p.buckets[item.freq].Remove(item.el)
+	p.dropIfEmpty(item.freq)
+	delete(p.items, key)
+	if item.freq == p.minFreq {
+		p.resetMinFreq()
+	}
+}
+
+// touch 将键移到访问次数加一的桶，调用时须持有锁
+func (p *lfuPolicy) touch(item *lfuItem) {
+	p.buckets[item.freq].Remove(item.el)
+	if p.dropIfEmpty(item.freq) && p.minFreq == item.freq {
+		p.minFreq++
+	}
+	item.freq++
+	item.el = p.bucket(item.freq).PushBack(item)
+}
+
+// bucket 返回指定访问次数的桶，不存在时创建
+func (p *lfuPolicy) bucket(freq uint64) *list.List {
+	b, ok := p.buckets[freq]
+	if !ok {
+		b = list.New()
+		p.buckets[freq] = b
+	}
+	return b
+}
+
+// dropIfEmpty 删除空桶，返回是否删除
+func (p *lfuPolicy) dropIfEmpty(freq uint64) bool {
+	if p.buckets[freq].Len() > 0 {
+		return false
+	}
+	delete(p.buckets, freq)
+	return true
+}
+
+// resetMinFreq 淘汰或删除后重新确定最小访问次数
+// 只在最小桶被清空时遍历剩余的桶，桶数远小于键数
+func (p *lfuPolicy) resetMinFreq() {
+	if _, ok := p.buckets[p.minFreq]; ok || len(p.buckets) == 0 {
+		return
+	}
+	first := true
+	for freq := range p.buckets {
+		if first || freq < p.minFreq {
+			p.minFreq = freq
+			first = false
+		}
+	}
+}
@@ -1,16 +1,38 @@
 package ginstatic
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-// Example1 基础用法示例
-func Example1() {
+// ExampleNew 基础用法示例
+func ExampleNew() {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
 
@@ -21,8 +43,8 @@ func Example1() {
 	// r.Run(":8080")
 }
 
-// Example2 完整配置示例
-func Example2() {
+// ExampleNew_fullConfig 完整配置示例
+func ExampleNew_fullConfig() {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
 
@@ -37,8 +59,8 @@ func Example2() {
 	)
 }
 
-// Example3 禁用缓存和 Gzip
-func Example3() {
+// ExampleNew_noCacheNoGzip 禁用缓存和 Gzip
+func ExampleNew_noCacheNoGzip() {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
 
@@ -48,8 +70,8 @@ func Example3() {
 	)
 }
 
-// Example4 SPA 应用
-func Example4() {
+// ExampleNew_spa SPA 应用
+func ExampleNew_spa() {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
 
@@ -60,8 +82,8 @@ func Example4() {
 	)
 }
 
-// Example5 自定义 MIME 类型
-func Example5() {
+// ExampleNew_mimeTypes 自定义 MIME 类型
+func ExampleNew_mimeTypes() {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
 
@@ -99,9 +121,9 @@ func TestNewWithConfig(t *testing.T) {
 	r := gin.New()
 
 	cfg := &Config{
-		Root:       "./testdata",
-		Prefix:     "/static",
-		EnableGzip: true,
+		Root:        "./testdata",
+		Prefix:      "/static",
+		EnableGzip:  true,
 		EnableCache: true,
 	}
 
@@ -224,6 +246,141 @@ func TestStaticEngineGzip(t *testing.T) {
 	}
 }
 
+// TestStaticEngineBytesServed 测试已服务字节数统计
+func TestStaticEngineBytesServed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	tmpDir := t.TempDir()
+	content := make([]byte, 2048)
+	for i := range content {
+		content[i] = 'x'
+	}
+	if err := os.WriteFile(tmpDir+"/large.txt", content, 0644); err != nil {
+		t.Fatalf("failed to create large file: %v", err)
+	}
+
+	engine := New(r, tmpDir, WithGzip(6))
+
+	// 不压缩：原始字节数与传输字节数相同
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/large.txt", nil)
+	r.ServeHTTP(w, req)
+
+	raw, wire := engine.BytesServed()
+	if raw != 2048 || wire != 2048 {
+		t.Fatalf("expected raw=2048 wire=2048, got raw=%d wire=%d", raw, wire)
+	}
+
+	// Gzip：原始字节数按文件大小累加，传输字节数按压缩后大小累加
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/large.txt", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	r.ServeHTTP(w, req)
+
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip response, got '%s'", w.Header().Get("Content-Encoding"))
+	}
+
+	raw, wire = engine.BytesServed()
+	wantWire := int64(2048 + w.Body.Len())
+	if raw != 4096 {
+		t.Errorf("expected raw=4096, got %d", raw)
+	}
+	if wire != wantWire {
+		t.Errorf("expected wire=%d, got %d", wantWire, wire)
+	}
+
+	// 404 不计入
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/missing.txt", nil)
+	r.ServeHTTP(w, req)
+
+	if raw2, wire2 := engine.BytesServed(); raw2 != raw || wire2 != wire {
+		t.Errorf("expected counters unchanged after 404, got raw=%d wire=%d", raw2, wire2)
+	}
+}
+
+// TestStaticEngineWasmStreaming 测试 WASM 流式编译支持
+func TestStaticEngineWasmStreaming(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	tmpDir := t.TempDir()
+	// WASM 魔数 + 版本号，后接可压缩的填充数据
+	content := append([]byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}, make([]byte, 4096)...)
+	if err := os.WriteFile(tmpDir+"/app.wasm", content, 0644); err != nil {
+		t.Fatalf("failed to create wasm file: %v", err)
+	}
+
+	_ = New(r, tmpDir,
+		WithWasmStreaming(true),
+		WithMimeTypes(map[string]string{"wasm": "application/octet-stream"}),
+		WithContentSniffing(),
+	)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/app.wasm", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/wasm" {
+		t.Errorf("expected Content-Type 'application/wasm', got '%s'", ct)
+	}
+	if enc := w.Header().Get("Content-Encoding"); enc != "" {
+		t.Errorf("expected no Content-Encoding, got '%s'", enc)
+	}
+	if !bytes.Equal(w.Body.Bytes(), content) {
+		t.Error("expected wasm body to be served unmodified")
+	}
+	if got := w.Header().Get("Cross-Origin-Opener-Policy"); got != "same-origin" {
+		t.Errorf("expected COOP 'same-origin', got '%s'", got)
+	}
+	if got := w.Header().Get("Cross-Origin-Embedder-Policy"); got != "require-corp" {
+		t.Errorf("expected COEP 'require-corp', got '%s'", got)
+	}
+}
+
+// TestStaticEngineMaxAge 测试 WithMaxAge 生成缓存控制头
+func TestStaticEngineMaxAge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(tmpDir+"/test.txt", []byte("test"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		maxAge    time.Duration
+		immutable bool
+		want      string
+	}{
+		{"一小时", time.Hour, false, "public, max-age=3600"},
+		{"一年不可变", 365 * 24 * time.Hour, true, "public, max-age=31536000, immutable"},
+		{"不足一秒", 500 * time.Millisecond, false, "public, max-age=0"},
+		{"零时长", 0, false, "public"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			_ = New(r, tmpDir, WithMaxAge(tt.maxAge, tt.immutable))
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/test.txt", nil)
+			r.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Cache-Control"); got != tt.want {
+				t.Errorf("expected Cache-Control %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
 // TestStaticEngineETag 测试 ETag
 func TestStaticEngineETag(t *testing.T) {
 	gin.SetMode(gin.TestMode)
@@ -356,3 +513,3887 @@ func TestCacheOperations(t *testing.T) {
 		t.Errorf("expected <= 2 files, got %d", cache.FileCount())
 	}
 }
+
+// TestStaticEngineContentSniffing 测试无扩展名文件的内容嗅探
+func TestStaticEngineContentSniffing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tmpDir := t.TempDir()
+	html := []byte("<!DOCTYPE html><html><body>hello</body></html>")
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	if err := os.WriteFile(tmpDir+"/page", html, 0644); err != nil {
+		t.Fatalf("failed to create html file: %v", err)
+	}
+	if err := os.WriteFile(tmpDir+"/image", png, 0644); err != nil {
+		t.Fatalf("failed to create png file: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+		path string
+		want string
+	}{
+		{"默认关闭嗅探", nil, "/page", "application/octet-stream"},
+		{"嗅探 HTML", []Option{WithContentSniffing()}, "/page", "text/html; charset=utf-8"},
+		{"嗅探 PNG", []Option{WithContentSniffing()}, "/image", "image/png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			_ = New(r, tmpDir, tt.opts...)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.want {
+				t.Errorf("expected Content-Type %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestStaticEngineReloadEmbed 测试 embed 缓存重新加载
+func TestStaticEngineReloadEmbed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	assets := fstest.MapFS{
+		"dist/index.html": {Data: []byte("<h1>v1</h1>")},
+		"dist/js/app.js":  {Data: []byte("console.log('v1')")},
+	}
+	engine := NewEmbed(r, assets, WithEmbedRoot("dist"))
+
+	if err := engine.ReloadEmbed(); err != nil {
+		t.Fatalf("ReloadEmbed failed: %v", err)
+	}
+	if got := engine.Cache().FileCount(); got != 2 {
+		t.Fatalf("expected 2 cached files, got %d", got)
+	}
+	if _, ok := engine.Cache().Get("/js/app.js"); !ok {
+		t.Error("expected /js/app.js to be cached under request path key")
+	}
+
+	// 修改虚拟文件后重新加载，应返回新内容
+	assets["dist/js/app.js"] = &fstest.MapFile{Data: []byte("console.log('v2')")}
+	if err := engine.ReloadCache(); err != nil {
+		t.Fatalf("ReloadCache failed: %v", err)
+	}
+	if got := engine.Cache().FileCount(); got != 2 {
+		t.Fatalf("expected 2 cached files after reload, got %d", got)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/js/app.js", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Body.String() != "console.log('v2')" {
+		t.Errorf("expected reloaded content, got %q", w.Body.String())
+	}
+
+	// 非 embed 后端调用 ReloadEmbed 返回错误
+	if err := New(gin.New(), t.TempDir()).ReloadEmbed(); err == nil {
+		t.Error("expected error for non-embed engine")
+	}
+}
+
+// TestStaticEnginePrecompressed 测试预压缩文件与缓存协作
+func TestStaticEnginePrecompressed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	tmpDir := t.TempDir()
+	content := bytes.Repeat([]byte("console.log('hello');\n"), 100)
+	if err := os.WriteFile(tmpDir+"/app.js", content, 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	// 预压缩文件带有 Name 头，实时压缩不会产生相同字节
+	var buf bytes.Buffer
+	gw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	gw.Name = "app.js"
+	gw.Write(content)
+	gw.Close()
+	precompressed := buf.Bytes()
+	if err := os.WriteFile(tmpDir+"/app.js.gz", precompressed, 0644); err != nil {
+		t.Fatalf("failed to create precompressed file: %v", err)
+	}
+
+	engine := New(r, tmpDir, WithPrecompressed())
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/app.js", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		r.ServeHTTP(w, req)
+
+		if enc := w.Header().Get("Content-Encoding"); enc != "gzip" {
+			t.Fatalf("request %d: expected Content-Encoding 'gzip', got '%s'", i, enc)
+		}
+		if !bytes.Equal(w.Body.Bytes(), precompressed) {
+			t.Fatalf("request %d: expected body to equal precompressed file", i)
+		}
+	}
+
+	entry, ok := engine.Cache().Get("/app.js")
+	if !ok {
+		t.Fatal("expected /app.js to be cached")
+	}
+	if !bytes.Equal(entry.Gzipped, precompressed) {
+		t.Error("expected cached gzip variant to equal precompressed file bytes")
+	}
+
+	// 不接受 gzip 时返回原始内容
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/app.js", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Header().Get("Content-Encoding") != "" || !bytes.Equal(w.Body.Bytes(), content) {
+		t.Error("expected identity response without Accept-Encoding")
+	}
+}
+
+// TestStaticEnginePrecompressedEncodings 测试各类预压缩文件的 Content-Encoding 标注
+func TestStaticEnginePrecompressedEncodings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	content := bytes.Repeat([]byte("console.log('precompressed');\n"), 100)
+	gzData, err := GzipCompress(content, gzip.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zstdData, err := ZstdCompress(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 预压缩文件原样返回，.br 文件以字节一致校验代替解压
+	brData := []byte("brotli-precompressed-body")
+
+	decompress := map[string]func([]byte) ([]byte, error){
+		"gzip": GzipDecompress,
+		"zstd": ZstdDecompress,
+	}
+
+	tests := []struct {
+		name           string
+		files          map[string][]byte
+		opts           []Option
+		acceptEncoding string
+		wantEncoding   string
+		wantBody       []byte
+	}{
+		{"br 优先", map[string][]byte{".br": brData, ".zst": zstdData, ".gz": gzData}, nil, "gzip, zstd, br", "br", brData},
+		{".zst 标注为 zstd", map[string][]byte{".br": brData, ".zst": zstdData, ".gz": gzData}, nil, "gzip, zstd", "zstd", zstdData},
+		{".gz 标注为 gzip", map[string][]byte{".br": brData, ".zst": zstdData, ".gz": gzData}, nil, "gzip", "gzip", gzData},
+		{"q=0 拒绝 br", map[string][]byte{".br": brData, ".gz": gzData}, nil, "br;q=0, gzip", "gzip", gzData},
+		{"缺少 .br 时回退 .zst", map[string][]byte{".zst": zstdData}, nil, "br, zstd", "zstd", zstdData},
+		{"不接受压缩", map[string][]byte{".br": brData, ".gz": gzData}, nil, "", "", content},
+		{"自定义扩展名", map[string][]byte{".brotli": brData}, []Option{WithContentEncodingForPrecompressed(".brotli", "br")}, "br", "br", brData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(dir+"/app.js", content, 0644); err != nil {
+				t.Fatal(err)
+			}
+			for ext, data := range tt.files {
+				if err := os.WriteFile(dir+"/app.js"+ext, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			r := gin.New()
+			New(r, dir, append([]Option{WithPrecompressed()}, tt.opts...)...)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/app.js", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if enc := w.Header().Get("Content-Encoding"); enc != tt.wantEncoding {
+				t.Fatalf("expected Content-Encoding %q, got %q", tt.wantEncoding, enc)
+			}
+			if !bytes.Equal(w.Body.Bytes(), tt.wantBody) {
+				t.Fatal("expected body to equal precompressed file")
+			}
+			if fn, ok := decompress[tt.wantEncoding]; ok {
+				decoded, err := fn(w.Body.Bytes())
+				if err != nil {
+					t.Fatalf("failed to decode %s body: %v", tt.wantEncoding, err)
+				}
+				if !bytes.Equal(decoded, content) {
+					t.Errorf("%s round-trip mismatch", tt.wantEncoding)
+				}
+			}
+		})
+	}
+}
+
+// TestStaticEngineManifest 测试资源清单生成
+func TestStaticEngineManifest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	tmpDir := t.TempDir()
+	files := map[string][]byte{
+		"index.html": []byte("<h1>hello</h1>"),
+		"js/app.js":  []byte("console.log('app')"),
+		"css/a.css":  []byte("body{}"),
+	}
+	os.MkdirAll(tmpDir+"/js", 0755)
+	os.MkdirAll(tmpDir+"/css", 0755)
+	for name, data := range files {
+		if err := os.WriteFile(tmpDir+"/"+name, data, 0644); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+	if err := os.WriteFile(tmpDir+"/.env", []byte("SECRET=1"), 0644); err != nil {
+		t.Fatalf("failed to create hidden file: %v", err)
+	}
+
+	engine := New(r, tmpDir)
+
+	// 预热一个文件，清单应复用缓存中的 ETag
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/js/app.js", nil)
+	r.ServeHTTP(w, req)
+	servedETag := w.Header().Get("ETag")
+
+	manifest, err := engine.Manifest()
+	if err != nil {
+		t.Fatalf("Manifest failed: %v", err)
+	}
+	if len(manifest) != len(files) {
+		t.Fatalf("expected %d entries, got %d: %v", len(files), len(manifest), manifest)
+	}
+	if _, ok := manifest["/.env"]; ok {
+		t.Error("expected hidden file to be excluded")
+	}
+
+	for name, data := range files {
+		entry, ok := manifest["/"+name]
+		if !ok {
+			t.Errorf("expected manifest entry for /%s", name)
+			continue
+		}
+		sum := sha512.Sum384(data)
+		wantSRI := "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+		if entry.SRI != wantSRI {
+			t.Errorf("/%s: expected SRI %s, got %s", name, wantSRI, entry.SRI)
+		}
+		if entry.Size != int64(len(data)) {
+			t.Errorf("/%s: expected size %d, got %d", name, len(data), entry.Size)
+		}
+		if entry.ContentType != GetMimeType(name, nil) {
+			t.Errorf("/%s: expected content type %s, got %s", name, GetMimeType(name, nil), entry.ContentType)
+		}
+	}
+
+	if manifest["/js/app.js"].ETag != servedETag {
+		t.Errorf("expected ETag %s to match served response, got %s", servedETag, manifest["/js/app.js"].ETag)
+	}
+}
+
+// TestStaticEngineMethodNotAllowed 测试非 GET 方法返回 405
+func TestStaticEngineMethodNotAllowed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(tmpDir+"/app.js", []byte("console.log(1)"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	_ = New(r, tmpDir, WithMethodNotAllowed())
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantAllow  string
+	}{
+		{"POST 已存在资源", http.MethodPost, "/app.js", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{"PUT 已存在资源", http.MethodPut, "/app.js", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{"POST 不存在资源", http.MethodPost, "/missing.js", http.StatusNotFound, ""},
+		{"GET 正常服务", http.MethodGet, "/app.js", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if got := w.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("expected Allow %q, got %q", tt.wantAllow, got)
+			}
+		})
+	}
+}
+
+// TestStaticEngineCompressionStats 测试压缩统计
+func TestStaticEngineCompressionStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	tmpDir := t.TempDir()
+	files := map[string][]byte{
+		"/a.js":  bytes.Repeat([]byte("function a() { return 1; }\n"), 100),
+		"/b.css": bytes.Repeat([]byte("body { margin: 0; }\n"), 200),
+		"/c.txt": []byte("small"), // 小于最小压缩大小，不计入
+	}
+	for name, data := range files {
+		if err := os.WriteFile(tmpDir+name, data, 0644); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+
+	engine := New(r, tmpDir, WithCompressionStats())
+
+	var raw, wire int64
+	for name, data := range files {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", name, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		r.ServeHTTP(w, req)
+
+		if w.Header().Get("Content-Encoding") == "gzip" {
+			raw += int64(len(data))
+			wire += int64(w.Body.Len())
+		}
+	}
+
+	stats := engine.CompressionStats()
+	if stats.Responses != 2 {
+		t.Fatalf("expected 2 compressed responses, got %d", stats.Responses)
+	}
+
+	gz, ok := stats.Encodings["gzip"]
+	if !ok {
+		t.Fatal("expected gzip stats")
+	}
+	if gz.RawBytes != raw || gz.CompressedBytes != wire {
+		t.Errorf("expected raw=%d compressed=%d, got raw=%d compressed=%d", raw, wire, gz.RawBytes, gz.CompressedBytes)
+	}
+	if stats.SavedBytes != raw-wire || gz.SavedBytes != raw-wire {
+		t.Errorf("expected saved=%d, got total=%d gzip=%d", raw-wire, stats.SavedBytes, gz.SavedBytes)
+	}
+	if gz.Ratio <= 0 || gz.Ratio >= 1 {
+		t.Errorf("expected ratio in (0, 1), got %f", gz.Ratio)
+	}
+
+	// 未启用时不统计
+	r2 := gin.New()
+	engine2 := New(r2, tmpDir)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/a.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	r2.ServeHTTP(w, req)
+	if engine2.CompressionStats().Responses != 0 {
+		t.Error("expected no stats when disabled")
+	}
+}
+
+// TestStaticEngineLanguageNegotiation 测试按 Accept-Language 选择语言版本
+func TestStaticEngineLanguageNegotiation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"index.html":    "default",
+		"index.en.html": "english",
+		"index.zh.html": "chinese",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(tmpDir+"/"+name, []byte(data), 0644); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+
+	_ = New(r, tmpDir, WithLanguageNegotiation("en", []string{"en", "zh"}))
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptLanguage string
+		wantBody       string
+		wantLang       string
+	}{
+		{"中文", "/index.html", "zh", "chinese", "zh"},
+		{"地区子标签", "/", "zh-CN,zh;q=0.9,en;q=0.8", "chinese", "zh"},
+		{"按 q 值排序", "/index.html", "fr;q=0.9, en;q=0.5, zh;q=0.1", "english", "en"},
+		{"不支持的语言回退默认", "/index.html", "fr", "english", "en"},
+		{"无请求头回退默认", "/index.html", "", "english", "en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			r.ServeHTTP(w, req)
+
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Language"); got != tt.wantLang {
+				t.Errorf("expected Content-Language %q, got %q", tt.wantLang, got)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept-Language" {
+				t.Errorf("expected Vary 'Accept-Language', got %q", got)
+			}
+		})
+	}
+}
+
+// TestStaticEngineNotFoundHandler 测试自定义 404 处理器
+func TestStaticEngineNotFoundHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(tmpDir+"/404.html", []byte("static 404"), 0644); err != nil {
+		t.Fatalf("failed to create 404 page: %v", err)
+	}
+
+	_ = New(r, tmpDir,
+		WithCustom404("404.html"),
+		WithNotFoundHandler(func(c *gin.Context) {
+			c.JSON(http.StatusNotFound, gin.H{"missing": c.Request.URL.Path})
+		}),
+	)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/missing.js", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+	if w.Body.String() != `{"missing":"/missing.js"}` {
+		t.Errorf("expected custom handler output, got %q", w.Body.String())
+	}
+}
+
+// buildTarGz 构建 tar.gz 测试数据
+func buildTarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), ModTime: time.Now(), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("failed to write tar header: %v", err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gw.Close()
+	return buf.Bytes()
+}
+
+// TestStaticEngineTarGz 测试从 tar.gz 归档提供服务
+func TestStaticEngineTarGz(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	data := buildTarGz(t, map[string]string{
+		"./dist/index.html":   "<h1>home</h1>",
+		"dist/js/app.js":      "console.log('app')",
+		"dist/css/deep/a.css": "body{}",
+		"../outside.txt":      "evil",
+	})
+
+	fsys, err := ReadTarGzFS(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadTarGzFS failed: %v", err)
+	}
+	if err := fstest.TestFS(fsys, "dist/index.html", "dist/js/app.js", "dist/css/deep/a.css"); err != nil {
+		t.Fatalf("invalid fs.FS: %v", err)
+	}
+	if _, err := fs.Stat(fsys, "outside.txt"); err == nil {
+		t.Error("expected entry with .. to be skipped")
+	}
+
+	archive := t.TempDir() + "/dist.tar.gz"
+	if err := os.WriteFile(archive, data, 0644); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+
+	engine, err := NewTarGzFS(r, archive, WithEmbedRoot("dist"))
+	if err != nil {
+		t.Fatalf("NewTarGzFS failed: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/", "<h1>home</h1>"},
+		{"/js/app.js", "console.log('app')"},
+		{"/css/deep/a.css", "body{}"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", tt.path, nil)
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || w.Body.String() != tt.want {
+			t.Errorf("%s: expected 200 %q, got %d %q", tt.path, tt.want, w.Code, w.Body.String())
+		}
+	}
+
+	if _, ok := engine.Cache().Get("/js/app.js"); !ok {
+		t.Error("expected served file to be cached")
+	}
+
+	if _, err := NewTarGzFS(gin.New(), t.TempDir()+"/missing.tar.gz"); err == nil {
+		t.Error("expected error for missing archive")
+	}
+}
+
+// TestReadTarGzFSPathConflict 测试同一路径既是文件又是目录时返回错误而不是 panic 或覆盖
+func TestReadTarGzFSPathConflict(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tar.Header
+	}{
+		{"文件在前", []tar.Header{
+			{Name: "a", Typeflag: tar.TypeReg, Mode: 0644},
+			{Name: "a/b", Typeflag: tar.TypeReg, Mode: 0644},
+		}},
+		{"目录在前", []tar.Header{
+			{Name: "a/", Typeflag: tar.TypeDir, Mode: 0755},
+			{Name: "a/b", Typeflag: tar.TypeReg, Mode: 0644},
+			{Name: "a", Typeflag: tar.TypeReg, Mode: 0644},
+		}},
+		{"文件后接同名目录", []tar.Header{
+			{Name: "a", Typeflag: tar.TypeReg, Mode: 0644},
+			{Name: "a/", Typeflag: tar.TypeDir, Mode: 0755},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			gw := gzip.NewWriter(&buf)
+			tw := tar.NewWriter(gw)
+			for _, hdr := range tt.entries {
+				hdr := hdr
+				if err := tw.WriteHeader(&hdr); err != nil {
+					t.Fatalf("failed to write tar header: %v", err)
+				}
+			}
+			tw.Close()
+			gw.Close()
+
+			if _, err := ReadTarGzFS(&buf); err == nil {
+				t.Error("expected error for conflicting file and directory entries")
+			}
+		})
+	}
+}
+
+// TestStaticEngineDefaultFiles 测试 robots.txt 与 favicon 内置回退
+func TestStaticEngineDefaultFiles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(tmpDir+"/index.html", []byte("<h1>spa</h1>"), 0644); err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+
+	icon := []byte{0x00, 0x00, 0x01, 0x00}
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"默认 robots", []Option{WithSPA("index.html"), WithDefaultRobots("User-agent: *\nDisallow:")}, "/robots.txt", http.StatusOK, "User-agent: *\nDisallow:"},
+		{"默认 favicon", []Option{WithSPA("index.html"), WithDefaultFavicon(icon)}, "/favicon.ico", http.StatusOK, string(icon)},
+		{"空 favicon 返回 204", []Option{WithDefaultFavicon(nil)}, "/favicon.ico", http.StatusNoContent, ""},
+		{"未配置时走 SPA 回退", []Option{WithSPA("index.html")}, "/robots.txt", http.StatusOK, "<h1>spa</h1>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			_ = New(r, tmpDir, tt.opts...)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+
+	// 文件存在时优先返回真实文件
+	if err := os.WriteFile(tmpDir+"/robots.txt", []byte("real"), 0644); err != nil {
+		t.Fatalf("failed to create robots.txt: %v", err)
+	}
+	r := gin.New()
+	_ = New(r, tmpDir, WithDefaultRobots("default"))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/robots.txt", nil)
+	r.ServeHTTP(w, req)
+	if w.Body.String() != "real" {
+		t.Errorf("expected real robots.txt, got %q", w.Body.String())
+	}
+}
+
+// TestStaticEngineMaxURILength 测试 URI 长度限制
+func TestStaticEngineMaxURILength(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(tmpDir+"/app.js", []byte("ok"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	_ = New(r, tmpDir, WithMaxURILength(64))
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{"正常路径", "/app.js", http.StatusOK},
+		{"超长路径", "/" + strings.Repeat("a", 100) + ".js", http.StatusRequestURITooLong},
+		{"超长查询串", "/app.js?q=" + strings.Repeat("a", 100), http.StatusRequestURITooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", tt.path, nil)
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+// TestStaticEngineRecompressEntry 测试重新计算单个文件的压缩版本
+func TestStaticEngineRecompressEntry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	tmpDir := t.TempDir()
+	v1 := bytes.Repeat([]byte("version one;\n"), 200)
+	v2 := bytes.Repeat([]byte("version two!\n"), 300)
+	if err := os.WriteFile(tmpDir+"/app.js", v1, 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	engine := New(r, tmpDir)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/app.js", nil)
+	r.ServeHTTP(w, req)
+
+	before, ok := engine.Cache().Get("/app.js")
+	if !ok || before.Gzipped == nil {
+		t.Fatal("expected cached gzip variant")
+	}
+
+	if err := os.WriteFile(tmpDir+"/app.js", v2, 0644); err != nil {
+		t.Fatalf("failed to update file: %v", err)
+	}
+	if err := engine.RecompressEntry("/app.js"); err != nil {
+		t.Fatalf("RecompressEntry failed: %v", err)
+	}
+
+	after, ok := engine.Cache().Get("/app.js")
+	if !ok {
+		t.Fatal("expected entry to remain cached")
+	}
+	if bytes.Equal(after.Gzipped, before.Gzipped) {
+		t.Error("expected gzip variant to change")
+	}
+	decoded, err := GzipDecompress(after.Gzipped)
+	if err != nil || !bytes.Equal(decoded, v2) {
+		t.Error("expected gzip variant to decode to new content")
+	}
+	if engine.Cache().FileCount() != 1 || engine.Cache().Size() != int64(len(v2)) {
+		t.Errorf("expected 1 file of %d bytes, got %d files of %d bytes", len(v2), engine.Cache().FileCount(), engine.Cache().Size())
+	}
+
+	if err := engine.RecompressEntry("/missing.js"); err == nil {
+		t.Error("expected error for uncached entry")
+	}
+}
+
+// TestStaticEngineEncodingETagSuffix 测试压缩响应的 ETag 编码后缀
+func TestStaticEngineEncodingETagSuffix(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(tmpDir+"/app.js", bytes.Repeat([]byte("let a = 1;\n"), 200), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	_ = New(r, tmpDir, WithEncodingETagSuffix())
+
+	fetch := func(acceptEncoding, ifNoneMatch string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/app.js", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	identity := fetch("", "").Header().Get("ETag")
+	gzipped := fetch("gzip", "").Header().Get("ETag")
+
+	if identity == gzipped {
+		t.Fatalf("expected distinct ETags, both %s", identity)
+	}
+	if gzipped != strings.TrimSuffix(identity, `"`)+`-gzip"` {
+		t.Errorf("expected gzip ETag with suffix, got %s (identity %s)", gzipped, identity)
+	}
+
+	if w := fetch("", identity); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for identity ETag, got %d", w.Code)
+	}
+	if w := fetch("gzip", gzipped); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for gzip ETag, got %d", w.Code)
+	}
+}
+
+// TestStaticEngineDirectoryIndex 测试非 SPA 模式下以 / 结尾的目录请求服务目录 index
+func TestStaticEngineDirectoryIndex(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(tmpDir+"/subdir", 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(tmpDir+"/subdir/index.html", []byte("<html>subdir</html>"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	_ = New(r, tmpDir)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"目录带斜杠", "/subdir/", http.StatusOK, "<html>subdir</html>"},
+		{"不存在的目录", "/missing/", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestStaticEngineMount 测试同一引擎在不同前缀下挂载不同目录
+func TestStaticEngineMount(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	appDir := t.TempDir()
+	docsDir := t.TempDir()
+	if err := os.WriteFile(appDir+"/readme.txt", []byte("app"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := os.WriteFile(docsDir+"/readme.txt", []byte("docs"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	engine := New(r, appDir, WithPrefix("/app"), WithCache(1024*1024, 100))
+	docs := engine.Mount(r, "/docs", docsDir, WithCacheControl("no-cache"))
+
+	if docs.Cache() != engine.Cache() {
+		t.Error("expected mount to share the engine cache")
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		wantBody     string
+		cacheControl string
+	}{
+		{"主前缀", "/app/readme.txt", "app", engine.Config().CacheControl},
+		{"挂载前缀", "/docs/readme.txt", "docs", "no-cache"},
+		{"主前缀（缓存命中）", "/app/readme.txt", "app", engine.Config().CacheControl},
+		{"挂载前缀（缓存命中）", "/docs/readme.txt", "docs", "no-cache"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, w.Body.String())
+			}
+			if got := w.Header().Get("Cache-Control"); got != tt.cacheControl {
+				t.Errorf("expected Cache-Control %q, got %q", tt.cacheControl, got)
+			}
+		})
+	}
+}
+
+// TestStaticEngineZstdDictionary 测试声明 zstd-dict 的客户端获得字典压缩响应
+func TestStaticEngineZstdDictionary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	dict := buildTestZstdDict(t)
+	data := testJSONRecord(42)
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(tmpDir+"/user.json", data, 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	_ = New(r, tmpDir, WithZstdDictionary(dict), WithCompressMinSize(0))
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		wantDict       bool
+	}{
+		{"声明 zstd-dict", "gzip, zstd-dict", true},
+		{"未声明 zstd-dict", "gzip", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/user.json", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			gotDict := w.Header().Get("Content-Encoding") == ZstdDictEncoding
+			if gotDict != tt.wantDict {
+				t.Fatalf("expected dictionary encoding %v, got Content-Encoding %q", tt.wantDict, w.Header().Get("Content-Encoding"))
+			}
+			if !gotDict {
+				return
+			}
+
+			body, err := ZstdDecompressDict(w.Body.Bytes(), dict)
+			if err != nil {
+				t.Fatalf("failed to decompress: %v", err)
+			}
+			if !bytes.Equal(body, data) {
+				t.Errorf("decompressed body mismatch")
+			}
+		})
+	}
+}
+
+// TestStaticEngineNoCacheHTML 测试 HTML 响应强制 no-cache
+func TestStaticEngineNoCacheHTML(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	tmpDir := t.TempDir()
+	os.WriteFile(tmpDir+"/index.html", []byte("<html></html>"), 0644)
+	os.WriteFile(tmpDir+"/app.3f2a1b.js", []byte("console.log(1)"), 0644)
+
+	_ = New(r, tmpDir, WithMaxAge(365*24*time.Hour, true), WithNoCacheHTML())
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"HTML 入口", "/index.html", "no-cache"},
+		{"根路径", "/", "no-cache"},
+		{"哈希资源", "/app.3f2a1b.js", "public, max-age=31536000, immutable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if got := w.Header().Get("Cache-Control"); got != tt.want {
+				t.Errorf("expected Cache-Control %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestStaticEngineBeforeServe 测试写入前回调修改响应
+func TestStaticEngineBeforeServe(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	tmpDir := t.TempDir()
+	os.WriteFile(tmpDir+"/app.js", []byte("console.log(1)"), 0644)
+	os.WriteFile(tmpDir+"/broken.js", []byte("console.log(2)"), 0644)
+
+	var gotStat FileStat
+	_ = New(r, tmpDir, WithBeforeServe(func(c *gin.Context, stat *FileStat) error {
+		if stat.Path == "/broken.js" {
+			return errors.New("hook failed")
+		}
+		gotStat = *stat
+		c.Header("X-Variant", "B")
+		c.Header("Cache-Control", "private")
+		return nil
+	}))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/app.js", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if w.Header().Get("X-Variant") != "B" {
+		t.Errorf("expected X-Variant header set by hook, got %q", w.Header().Get("X-Variant"))
+	}
+	if w.Header().Get("Cache-Control") != "private" {
+		t.Errorf("expected hook to override Cache-Control, got %q", w.Header().Get("Cache-Control"))
+	}
+	if w.Body.String() != "console.log(1)" {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+	if gotStat.Path != "/app.js" || gotStat.Size != 14 || gotStat.ETag == "" || !strings.HasPrefix(gotStat.ContentType, "application/javascript") {
+		t.Errorf("unexpected file stat %+v", gotStat)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/broken.js", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500 when hook fails, got %d", w.Code)
+	}
+}
+
+// TestStaticEngineUpgradeRejected 测试静态路由拒绝协议升级请求
+func TestStaticEngineUpgradeRejected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tmpDir := t.TempDir()
+	os.WriteFile(tmpDir+"/app.js", []byte("console.log(1)"), 0644)
+
+	tests := []struct {
+		name       string
+		spa        bool
+		connection string
+		wantStatus int
+	}{
+		{"WebSocket 升级", false, "Upgrade", http.StatusBadRequest},
+		{"多值 Connection", false, "keep-alive, upgrade", http.StatusBadRequest},
+		{"SPA 模式升级", true, "Upgrade", http.StatusBadRequest},
+		{"普通请求", false, "keep-alive", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			opts := []Option{}
+			if tt.spa {
+				opts = append(opts, WithSPA("index.html"))
+			}
+			_ = New(r, tmpDir, opts...)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/app.js", nil)
+			req.Header.Set("Connection", tt.connection)
+			if tt.wantStatus != http.StatusOK {
+				req.Header.Set("Upgrade", "websocket")
+			}
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+// slowFS 每次打开文件前等待 delay，模拟慢速存储
+type slowFS struct {
+	fs.FS
+	delay time.Duration
+}
+
+func (s slowFS) Open(name string) (fs.File, error) {
+	time.Sleep(s.delay)
+	return s.FS.Open(name)
+}
+
+// TestStaticEngineRequestTimeout 测试请求整体超时
+func TestStaticEngineRequestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	assets := slowFS{
+		FS:    fstest.MapFS{"app.js": {Data: []byte("console.log(1)")}},
+		delay: 100 * time.Millisecond,
+	}
+
+	tests := []struct {
+		name       string
+		timeout    time.Duration
+		serveHTTP  bool
+		wantStatus int
+	}{
+		{"读取超时", 10 * time.Millisecond, false, http.StatusServiceUnavailable},
+		{"未超时", time.Second, false, http.StatusOK},
+		{"ServeHTTP 读取超时", 10 * time.Millisecond, true, http.StatusServiceUnavailable},
+		{"ServeHTTP 未超时", time.Second, true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			engine := NewEmbed(r, assets, WithRequestTimeout(tt.timeout))
+
+			start := time.Now()
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/app.js", nil)
+			if tt.serveHTTP {
+				engine.ServeHTTP(w, req)
+			} else {
+				r.ServeHTTP(w, req)
+			}
+			elapsed := time.Since(start)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantStatus == http.StatusServiceUnavailable && elapsed >= assets.delay {
+				t.Errorf("expected response before slow read finished, took %v", elapsed)
+			}
+		})
+	}
+}
+
+// TestStaticEngineRequestCanceled 测试客户端断开时直接中止，不响应 503
+func TestStaticEngineRequestCanceled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	assets := fstest.MapFS{"app.js": {Data: []byte("console.log(1)")}}
+
+	for _, timeout := range []time.Duration{0, time.Second} {
+		for _, serveHTTP := range []bool{false, true} {
+			r := gin.New()
+			engine := NewEmbed(r, assets, WithRequestTimeout(timeout))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequestWithContext(ctx, "GET", "/app.js", nil)
+			if serveHTTP {
+				engine.ServeHTTP(w, req)
+			} else {
+				r.ServeHTTP(w, req)
+			}
+
+			if w.Code == http.StatusServiceUnavailable {
+				t.Errorf("timeout=%v serveHTTP=%v: expected no 503 for canceled request", timeout, serveHTTP)
+			}
+			if w.Body.Len() != 0 {
+				t.Errorf("timeout=%v serveHTTP=%v: expected empty body, got %q", timeout, serveHTTP, w.Body.String())
+			}
+		}
+	}
+}
+
+// TestStaticEnginePrecacheEndpoint 测试 Service Worker 预缓存清单
+func TestStaticEnginePrecacheEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	files := map[string]string{
+		"index.html":   "<html></html>",
+		"js/app.js":    "console.log(1)",
+		"css/site.css": "body{}",
+	}
+	tmpDir := t.TempDir()
+	os.MkdirAll(tmpDir+"/js", 0755)
+	os.MkdirAll(tmpDir+"/css", 0755)
+	for name, content := range files {
+		os.WriteFile(tmpDir+"/"+name, []byte(content), 0644)
+	}
+
+	tests := []struct {
+		name     string
+		prefix   string
+		endpoint string
+	}{
+		{"前缀内端点", "", "/precache-manifest.json"},
+		{"前缀外端点", "/static", "/sw/precache.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			_ = New(r, tmpDir, WithPrefix(tt.prefix), WithPrecacheEndpoint(tt.endpoint))
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.endpoint, nil)
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+
+			var entries []struct {
+				URL      string `json:"url"`
+				Revision string `json:"revision"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if len(entries) != len(files) {
+				t.Fatalf("expected %d entries, got %d", len(files), len(entries))
+			}
+
+			for _, entry := range entries {
+				name := strings.TrimPrefix(entry.URL, tt.prefix+"/")
+				content, ok := files[name]
+				if !ok {
+					t.Errorf("unexpected url %q", entry.URL)
+					continue
+				}
+				sum := sha512.Sum384([]byte(content))
+				if entry.Revision != hex.EncodeToString(sum[:]) {
+					t.Errorf("revision mismatch for %s: %s", entry.URL, entry.Revision)
+				}
+			}
+
+			// 静态资源仍正常服务
+			w = httptest.NewRecorder()
+			req, _ = http.NewRequest("GET", tt.prefix+"/js/app.js", nil)
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Errorf("expected asset status 200, got %d", w.Code)
+			}
+		})
+	}
+}
+
+// TestStaticEngineMaxBackgroundGoroutines 测试预加载并发受后台 goroutine 上限约束
+func TestStaticEngineMaxBackgroundGoroutines(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const fileCount = 200
+	dir := t.TempDir()
+	for i := 0; i < fileCount; i++ {
+		if err := os.WriteFile(fmt.Sprintf("%s/file-%03d.txt", dir, i), bytes.Repeat([]byte("x"), 4096), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+	}{
+		{"上限为 1 时顺序执行", 1},
+		{"上限为 4", 4},
+		{"上限为 8", 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := New(gin.New(), dir, WithMaxBackgroundGoroutines(tt.limit))
+
+			if err := engine.ReloadCache(); err != nil {
+				t.Fatalf("ReloadCache failed: %v", err)
+			}
+			if got := engine.Cache().FileCount(); got != fileCount {
+				t.Fatalf("expected %d cached files, got %d", fileCount, got)
+			}
+
+			peak := atomic.LoadInt32(&engine.bgPeak)
+			if peak < 1 || peak > int32(tt.limit) {
+				t.Errorf("expected background goroutine peak in [1, %d], got %d", tt.limit, peak)
+			}
+			if active := atomic.LoadInt32(&engine.bgActive); active != 0 {
+				t.Errorf("expected no active background goroutines after preload, got %d", active)
+			}
+
+			// 预加载使用与请求相同的缓存键
+			if _, ok := engine.Cache().Get("/file-000.txt"); !ok {
+				t.Error("expected preloaded file to be cached under request path")
+			}
+		})
+	}
+
+	// 启动时预加载同样受上限约束
+	engine := New(gin.New(), dir, WithPreloadOnStart(), WithMaxBackgroundGoroutines(4))
+	deadline := time.Now().Add(5 * time.Second)
+	for engine.Cache().FileCount() < fileCount && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := engine.Cache().FileCount(); got != fileCount {
+		t.Fatalf("expected %d cached files after startup preload, got %d", fileCount, got)
+	}
+	if peak := atomic.LoadInt32(&engine.bgPeak); peak > 4 {
+		t.Errorf("expected startup preload peak <= 4, got %d", peak)
+	}
+}
+
+// TestStaticEngineServerTiming 测试 Server-Timing 诊断头
+func TestStaticEngineServerTiming(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/app.js", bytes.Repeat([]byte("console.log('timing');\n"), 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wellFormed := regexp.MustCompile(`^cache;dur=\d+\.\d{3}, read;dur=\d+\.\d{3}, compress;dur=\d+\.\d{3}$`)
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		serveHTTP  bool
+		wantHeader bool
+	}{
+		{"默认关闭", nil, false, false},
+		{"gin 路由", []Option{WithServerTiming()}, false, true},
+		{"ServeHTTP", []Option{WithServerTiming()}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			engine := New(r, dir, tt.opts...)
+
+			// 首次请求未命中缓存
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/app.js", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			if tt.serveHTTP {
+				engine.ServeHTTP(w, req)
+			} else {
+				r.ServeHTTP(w, req)
+			}
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			header := w.Header().Get("Server-Timing")
+			if !tt.wantHeader {
+				if header != "" {
+					t.Errorf("expected no Server-Timing header, got %q", header)
+				}
+				return
+			}
+			if !wellFormed.MatchString(header) {
+				t.Errorf("malformed Server-Timing header: %q", header)
+			}
+		})
+	}
+}
+
+// truncatingWriter 写入 limit 字节后返回错误，模拟客户端中途断开
+type truncatingWriter struct {
+	*httptest.ResponseRecorder
+	limit int
+}
+
+func (w *truncatingWriter) Write(p []byte) (int, error) {
+	remain := w.limit - w.Body.Len()
+	if remain >= len(p) {
+		return w.ResponseRecorder.Write(p)
+	}
+	n, _ := w.ResponseRecorder.Write(p[:remain])
+	return n, errors.New("broken pipe")
+}
+
+// TestStaticEngineTruncatedWrite 测试客户端中途断开时停止服务并报告截断
+func TestStaticEngineTruncatedWrite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	if err := os.WriteFile(dir+"/data.bin", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		serveHTTP bool
+		limit     int
+		wantErr   bool
+	}{
+		{"gin 路由完整写入", false, len(content), false},
+		{"gin 路由中途断开", false, 4096, true},
+		{"ServeHTTP 完整写入", true, len(content), false},
+		{"ServeHTTP 中途断开", true, 4096, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var infos []*ResponseInfo
+			var aborted bool
+			var ginErrs int
+
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				c.Next()
+				aborted = c.IsAborted()
+				ginErrs = len(c.Errors)
+			})
+			engine := New(r, dir, WithOnResponse(func(info *ResponseInfo) {
+				infos = append(infos, info)
+			}))
+
+			w := &truncatingWriter{ResponseRecorder: httptest.NewRecorder(), limit: tt.limit}
+			req, _ := http.NewRequest("GET", "/data.bin", nil)
+			if tt.serveHTTP {
+				engine.ServeHTTP(w, req)
+			} else {
+				r.ServeHTTP(w, req)
+			}
+
+			if !tt.serveHTTP && (aborted != tt.wantErr || (ginErrs > 0) != tt.wantErr) {
+				t.Errorf("expected aborted=%v with error recorded, got aborted=%v errors=%d", tt.wantErr, aborted, ginErrs)
+			}
+			if len(infos) != 1 {
+				t.Fatalf("expected 1 OnResponse call, got %d", len(infos))
+			}
+			info := infos[0]
+			if info.Path != "/data.bin" {
+				t.Errorf("expected path /data.bin, got %q", info.Path)
+			}
+			if info.Size != len(content) {
+				t.Errorf("expected size %d, got %d", len(content), info.Size)
+			}
+			if info.Written != tt.limit {
+				t.Errorf("expected %d bytes written, got %d", tt.limit, info.Written)
+			}
+			if info.Truncated() != tt.wantErr || (info.Err != nil) != tt.wantErr {
+				t.Errorf("expected truncated=%v, got truncated=%v err=%v", tt.wantErr, info.Truncated(), info.Err)
+			}
+
+			wantServed := int64(len(content))
+			if tt.wantErr {
+				wantServed = 0
+			}
+			if got := atomic.LoadInt64(&engine.rawBytesServed); got != wantServed {
+				t.Errorf("expected %d raw bytes recorded, got %d", wantServed, got)
+			}
+		})
+	}
+}
+
+// countingFS 统计 Open 调用次数
+type countingFS struct {
+	fs.FS
+	opens int32
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	atomic.AddInt32(&c.opens, 1)
+	return c.FS.Open(name)
+}
+
+// TestStaticEnginePreloadETag 测试启动预加载完成后首个请求即携带 ETag，条件请求不再访问源
+func TestStaticEnginePreloadETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	content := bytes.Repeat([]byte("body { color: red; }\n"), 100)
+
+	tests := []struct {
+		name  string
+		embed bool
+	}{
+		{"磁盘", false},
+		{"embed", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			var engine *StaticEngine
+			var src *countingFS
+			dir := t.TempDir()
+			if tt.embed {
+				src = &countingFS{FS: fstest.MapFS{
+					"css/app.css": {Data: content},
+					"index.html":  {Data: []byte("<html></html>")},
+				}}
+				engine = NewEmbed(r, src, WithPreloadOnStart())
+			} else {
+				if err := os.MkdirAll(dir+"/css", 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(dir+"/css/app.css", content, 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(dir+"/index.html", []byte("<html></html>"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				engine = New(r, dir, WithPreloadOnStart())
+			}
+
+			if err := engine.WaitPreload(); err != nil {
+				t.Fatalf("preload failed: %v", err)
+			}
+
+			// 预加载完成后源不应再被访问：磁盘删除文件，embed 统计 Open 次数
+			if tt.embed {
+				atomic.StoreInt32(&src.opens, 0)
+			} else if err := os.RemoveAll(dir); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, path := range []string{"/css/app.css", "/index.html"} {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", path, nil)
+				req.Header.Set("Accept-Encoding", "gzip")
+				r.ServeHTTP(w, req)
+				if w.Code != http.StatusOK {
+					t.Fatalf("%s: expected status 200, got %d", path, w.Code)
+				}
+				etag := w.Header().Get("ETag")
+				if etag == "" {
+					t.Fatalf("%s: expected ETag on first request after preload", path)
+				}
+
+				w = httptest.NewRecorder()
+				req, _ = http.NewRequest("GET", path, nil)
+				req.Header.Set("If-None-Match", etag)
+				r.ServeHTTP(w, req)
+				if w.Code != http.StatusNotModified {
+					t.Errorf("%s: expected status 304, got %d", path, w.Code)
+				}
+			}
+
+			if tt.embed {
+				if n := atomic.LoadInt32(&src.opens); n != 0 {
+					t.Errorf("expected no source access after preload, got %d opens", n)
+				}
+			}
+		})
+	}
+}
+
+// TestStaticEngineMaxRanges 测试 Range 区间数超出上限时返回 416
+func TestStaticEngineMaxRanges(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	if err := os.WriteFile(dir+"/data.bin", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	multiRange := func(n int) string {
+		parts := make([]string, n)
+		for i := range parts {
+			parts[i] = fmt.Sprintf("%d-%d", i*10, i*10+1)
+		}
+		return "bytes=" + strings.Join(parts, ",")
+	}
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		serveHTTP  bool
+		ranges     int
+		wantReject bool
+	}{
+		{"默认上限内", nil, false, DefaultMaxRanges, false},
+		{"默认上限超出", nil, false, DefaultMaxRanges + 1, true},
+		{"自定义上限超出", []Option{WithMaxRanges(2)}, false, 3, true},
+		{"不限制", []Option{WithMaxRanges(0)}, false, 50, false},
+		{"ServeHTTP 超出", []Option{WithMaxRanges(2)}, true, 3, true},
+		{"ServeHTTP 上限内", []Option{WithMaxRanges(2)}, true, 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			engine := New(r, dir, tt.opts...)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/data.bin", nil)
+			req.Header.Set("Range", multiRange(tt.ranges))
+			if tt.serveHTTP {
+				engine.ServeHTTP(w, req)
+			} else {
+				r.ServeHTTP(w, req)
+			}
+
+			if tt.wantReject {
+				if w.Code != http.StatusRequestedRangeNotSatisfiable {
+					t.Fatalf("expected status 416, got %d", w.Code)
+				}
+				if got, want := w.Header().Get("Content-Range"), fmt.Sprintf("bytes */%d", len(content)); got != want {
+					t.Errorf("expected Content-Range %q, got %q", want, got)
+				}
+				if w.Body.Len() != 0 {
+					t.Errorf("expected empty body, got %d bytes", w.Body.Len())
+				}
+				return
+			}
+			if w.Code/100 != 2 {
+				t.Errorf("expected success, got status %d", w.Code)
+			}
+		})
+	}
+}
+
+// TestStaticEngineExternalBasePath 测试目录重定向指向对外基础路径
+func TestStaticEngineExternalBasePath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(dir+"/docs", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/docs/index.html", []byte("<html>docs</html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir+"/empty", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		opts         []Option
+		serveHTTP    bool
+		path         string
+		wantStatus   int
+		wantLocation string
+	}{
+		{"默认内部路径", nil, false, "/docs", http.StatusMovedPermanently, "/docs/"},
+		{"对外基础路径", []Option{WithExternalBasePath("/ui/")}, false, "/docs", http.StatusMovedPermanently, "/ui/docs/"},
+		{"保留查询串", []Option{WithExternalBasePath("ui")}, false, "/docs?v=1", http.StatusMovedPermanently, "/ui/docs/?v=1"},
+		{"带前缀", []Option{WithPrefix("/static"), WithExternalBasePath("/ui")}, false, "/static/docs", http.StatusMovedPermanently, "/ui/static/docs/"},
+		{"ServeHTTP", []Option{WithExternalBasePath("/ui")}, true, "/docs", http.StatusMovedPermanently, "/ui/docs/"},
+		{"带斜杠直接服务", []Option{WithExternalBasePath("/ui")}, false, "/docs/", http.StatusOK, ""},
+		{"无索引文件的目录", []Option{WithExternalBasePath("/ui")}, false, "/empty", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			engine := New(r, dir, tt.opts...)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			if tt.serveHTTP {
+				engine.ServeHTTP(w, req)
+			} else {
+				r.ServeHTTP(w, req)
+			}
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if got := w.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("expected Location %q, got %q", tt.wantLocation, got)
+			}
+		})
+	}
+}
+
+// TestStaticEngineImageNegotiation 测试按 Accept 返回 AVIF/WebP 版本
+func TestStaticEngineImageNegotiation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"photo.jpg":  "jpeg",
+		"photo.avif": "avif",
+		"photo.webp": "webp",
+		"logo.png":   "png",
+		"logo.webp":  "webp logo",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(tmpDir+"/"+name, []byte(data), 0644); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		disabled  bool
+		serveHTTP bool
+		path      string
+		accept    string
+		wantBody  string
+		wantType  string
+		wantVary  bool
+	}{
+		{"支持 avif", false, false, "/photo.jpg", "image/avif,image/webp,image/*,*/*;q=0.8", "avif", "image/avif", true},
+		{"仅支持 webp", false, false, "/photo.jpg", "image/webp,*/*", "webp", "image/webp", true},
+		{"旧客户端", false, false, "/photo.jpg", "image/*,*/*;q=0.8", "jpeg", "image/jpeg", true},
+		{"q=0 视为不支持", false, false, "/photo.jpg", "image/avif;q=0, image/webp", "webp", "image/webp", true},
+		{"缺少 avif 同名文件", false, false, "/logo.png", "image/avif,image/webp", "webp logo", "image/webp", true},
+		{"ServeHTTP", false, true, "/photo.jpg", "image/avif", "avif", "image/avif", true},
+		{"未启用", true, false, "/photo.jpg", "image/avif", "jpeg", "image/jpeg", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if !tt.disabled {
+				opts = append(opts, WithImageNegotiation())
+			}
+			r := gin.New()
+			engine := New(r, tmpDir, opts...)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			req.Header.Set("Accept", tt.accept)
+			if tt.serveHTTP {
+				engine.ServeHTTP(w, req)
+			} else {
+				r.ServeHTTP(w, req)
+			}
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("expected Content-Type %q, got %q", tt.wantType, got)
+			}
+			if got := w.Header().Get("Vary") == "Accept"; got != tt.wantVary {
+				t.Errorf("expected Vary: Accept %v, got %q", tt.wantVary, w.Header().Get("Vary"))
+			}
+		})
+	}
+}
+
+// TestStaticEngineChecksums 测试加载时校验文件完整性，拒绝服务被篡改的文件
+func TestStaticEngineChecksums(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tmpDir := t.TempDir()
+	good := []byte("console.log('good');")
+	bad := []byte("console.log('tampered');")
+	if err := os.WriteFile(tmpDir+"/good.js", good, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tmpDir+"/bad.js", bad, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tmpDir+"/other.js", []byte("unlisted"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	goodSum := sha256.Sum256(good)
+	origSum := sha256.Sum256([]byte("console.log('original');"))
+	r := gin.New()
+	engine := New(r, tmpDir, WithChecksums(map[string]string{
+		"/good.js": hex.EncodeToString(goodSum[:]),
+		"/bad.js":  hex.EncodeToString(origSum[:]),
+	}))
+
+	tests := []struct {
+		name     string
+		path     string
+		wantOK   bool
+		wantBody string
+	}{
+		{"校验通过", "/good.js", true, string(good)},
+		{"校验失败", "/bad.js", false, ""},
+		{"清单外文件", "/other.js", true, "unlisted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			r.ServeHTTP(w, req)
+
+			if (w.Code == http.StatusOK) != tt.wantOK {
+				t.Fatalf("expected ok=%v, got status %d", tt.wantOK, w.Code)
+			}
+			if tt.wantOK && w.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, w.Body.String())
+			}
+			if !tt.wantOK && strings.Contains(w.Body.String(), "tampered") {
+				t.Error("expected corrupt content not to be served")
+			}
+		})
+	}
+
+	if _, _, _, err := engine.getFile("/bad.js"); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected ErrChecksumMismatch, got %v", err)
+	}
+	if _, ok := engine.cache.Get(engine.cacheKey("/bad.js")); ok {
+		t.Error("expected corrupt file not to be cached")
+	}
+}
+
+// errorFS 打开指定文件时返回错误，模拟权限不足等读取失败
+type errorFS struct {
+	fs.FS
+	errs map[string]error
+}
+
+func (f errorFS) Open(name string) (fs.File, error) {
+	if err, ok := f.errs[name]; ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return f.FS.Open(name)
+}
+
+// TestStaticEngineOnError 测试读取失败时触发 OnError 并返回 500，文件不存在仍为 404
+func TestStaticEngineOnError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	assets := errorFS{
+		FS: fstest.MapFS{
+			"app.js":     {Data: []byte("console.log('ok');")},
+			"secret.txt": {Data: []byte("secret")},
+		},
+		errs: map[string]error{"secret.txt": fs.ErrPermission},
+	}
+
+	type call struct {
+		path, op string
+		err      error
+	}
+
+	tests := []struct {
+		name       string
+		serveHTTP  bool
+		path       string
+		wantStatus int
+		wantCall   bool
+	}{
+		{"正常文件", false, "/app.js", http.StatusOK, false},
+		{"文件不存在", false, "/missing.js", http.StatusNotFound, false},
+		{"权限不足", false, "/secret.txt", http.StatusInternalServerError, true},
+		{"ServeHTTP 权限不足", true, "/secret.txt", http.StatusInternalServerError, true},
+		{"ServeHTTP 文件不存在", true, "/missing.js", http.StatusNotFound, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []call
+			r := gin.New()
+			engine := NewEmbed(r, assets, WithOnError(func(path, op string, err error) {
+				calls = append(calls, call{path, op, err})
+			}))
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			if tt.serveHTTP {
+				engine.ServeHTTP(w, req)
+			} else {
+				r.ServeHTTP(w, req)
+			}
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if !tt.wantCall {
+				if len(calls) != 0 {
+					t.Errorf("expected no OnError calls, got %+v", calls)
+				}
+				return
+			}
+			if len(calls) != 1 {
+				t.Fatalf("expected 1 OnError call, got %d", len(calls))
+			}
+			got := calls[0]
+			if got.path != tt.path || got.op != ErrorOpRead || !errors.Is(got.err, fs.ErrPermission) {
+				t.Errorf("unexpected OnError call: path=%q op=%q err=%v", got.path, got.op, got.err)
+			}
+		})
+	}
+}
+
+// TestStaticEngineOnErrorUnreadableFile 测试磁盘上无读权限的文件返回 500
+func TestStaticEngineOnErrorUnreadableFile(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root ignores file permissions")
+	}
+	gin.SetMode(gin.TestMode)
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(tmpDir+"/secret.txt", []byte("secret"), 0000); err != nil {
+		t.Fatal(err)
+	}
+
+	var op string
+	r := gin.New()
+	_ = New(r, tmpDir, WithOnError(func(path, o string, err error) {
+		op = o
+	}))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/secret.txt", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", w.Code)
+	}
+	if op != ErrorOpRead {
+		t.Errorf("expected OnError op %q, got %q", ErrorOpRead, op)
+	}
+}
+
+// TestStaticEngineReadErrorStatus 测试文件不存在返回 404、读取失败返回 500
+func TestStaticEngineReadErrorStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	assets := errorFS{
+		FS: fstest.MapFS{
+			"secret.txt": {Data: []byte("secret")},
+			"flaky.txt":  {Data: []byte("flaky")},
+		},
+		errs: map[string]error{
+			"secret.txt": fs.ErrPermission,
+			"flaky.txt":  errors.New("input/output error"),
+		},
+	}
+	notFound := func(c *gin.Context) {
+		c.String(http.StatusNotFound, "custom 404")
+	}
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"文件不存在", nil, "/missing.txt", http.StatusNotFound, ""},
+		{"目录", nil, "/", http.StatusNotFound, ""},
+		{"权限不足", nil, "/secret.txt", http.StatusInternalServerError, ""},
+		{"IO 错误", nil, "/flaky.txt", http.StatusInternalServerError, ""},
+		{"读取失败按 404 处理", []Option{WithReadErrorStatus(http.StatusNotFound), WithNotFoundHandler(notFound)}, "/secret.txt", http.StatusNotFound, "custom 404"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			_ = NewEmbed(r, assets, tt.opts...)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestStaticEngineSourceMaps 测试 source map 与 JSON 的 Content-Type 配置及隐藏 source map
+func TestStaticEngineSourceMaps(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"index.html": "<html></html>",
+		"app.js.map": `{"version":3}`,
+		"data.json":  `{"ok":true}`,
+		"app.js":     "console.log(1);",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(tmpDir+"/"+name, []byte(data), 0644); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		serveHTTP  bool
+		path       string
+		wantStatus int
+		wantType   string
+	}{
+		{"默认 source map", nil, false, "/app.js.map", http.StatusOK, "application/json"},
+		{"自定义 source map 类型", []Option{WithSourceMapContentType("application/octet-stream")}, false, "/app.js.map", http.StatusOK, "application/octet-stream"},
+		{"默认 JSON", nil, false, "/data.json", http.StatusOK, "application/json; charset=utf-8"},
+		{"自定义 JSON 类型", []Option{WithJSONContentType("application/json")}, false, "/data.json", http.StatusOK, "application/json"},
+		{"隐藏 source map", []Option{WithHideSourceMaps()}, false, "/app.js.map", http.StatusNotFound, ""},
+		{"隐藏 source map 不影响其他文件", []Option{WithHideSourceMaps()}, false, "/app.js", http.StatusOK, "application/javascript; charset=utf-8"},
+		{"SPA 模式隐藏 source map", []Option{WithHideSourceMaps(), WithSPA("index.html")}, false, "/app.js.map", http.StatusNotFound, ""},
+		{"ServeHTTP 隐藏 source map", []Option{WithHideSourceMaps()}, true, "/app.js.map", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			engine := New(r, tmpDir, tt.opts...)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			if tt.serveHTTP {
+				engine.ServeHTTP(w, req)
+			} else {
+				r.ServeHTTP(w, req)
+			}
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantType != "" && w.Header().Get("Content-Type") != tt.wantType {
+				t.Errorf("expected Content-Type %q, got %q", tt.wantType, w.Header().Get("Content-Type"))
+			}
+			if tt.wantStatus == http.StatusNotFound && strings.Contains(w.Body.String(), "version") {
+				t.Error("expected source map content not to be served")
+			}
+		})
+	}
+
+	// 隐藏后预缓存清单不包含 source map
+	engine := New(gin.New(), tmpDir, WithHideSourceMaps())
+	manifest, err := engine.Manifest()
+	if err != nil {
+		t.Fatalf("Manifest failed: %v", err)
+	}
+	if _, ok := manifest["/app.js.map"]; ok {
+		t.Error("expected hidden source map to be excluded from manifest")
+	}
+}
+
+// TestStaticEngineQueryStringCacheKey 测试带不同缓存破坏查询串的请求命中同一缓存条目
+func TestStaticEngineQueryStringCacheKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(tmpDir+"/app.js", []byte("console.log('v');"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, serveHTTP := range []bool{false, true} {
+		r := gin.New()
+		engine := New(r, tmpDir)
+
+		var etags []string
+		for _, target := range []string{"/app.js?v=1", "/app.js?v=2", "/app.js"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", target, nil)
+			if serveHTTP {
+				engine.ServeHTTP(w, req)
+			} else {
+				r.ServeHTTP(w, req)
+			}
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s: expected status 200, got %d", target, w.Code)
+			}
+			etags = append(etags, w.Header().Get("ETag"))
+		}
+
+		if n := engine.Cache().FileCount(); n != 1 {
+			t.Errorf("serveHTTP=%v: expected 1 cache entry, got %d", serveHTTP, n)
+		}
+		if _, ok := engine.Cache().Get(engine.cacheKey("/app.js")); !ok {
+			t.Errorf("serveHTTP=%v: expected entry keyed by clean path", serveHTTP)
+		}
+		if etags[0] != etags[1] || etags[1] != etags[2] {
+			t.Errorf("serveHTTP=%v: expected identical ETags, got %v", serveHTTP, etags)
+		}
+	}
+}
+
+// TestStaticEngineCachePersistence 测试缓存导出、导入，以及按源文件修改时间丢弃过期条目
+func TestStaticEngineCachePersistence(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tmpDir := t.TempDir()
+	cacheDir := t.TempDir()
+	appJS := strings.Repeat("console.log('app');", 100)
+	if err := os.WriteFile(tmpDir+"/app.js", []byte(appJS), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tmpDir+"/old.js", []byte("console.log('old');"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	first := New(gin.New(), tmpDir, WithCachePersistence(cacheDir))
+	for _, path := range []string{"/app.js", "/old.js"} {
+		if _, _, _, err := first.getFile(path); err != nil {
+			t.Fatalf("getFile %s failed: %v", path, err)
+		}
+	}
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(cacheDir + "/index.json"); err != nil {
+		t.Fatalf("expected index.json to be exported: %v", err)
+	}
+
+	// 修改 old.js 的修改时间，重新导入时应被丢弃
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(tmpDir+"/old.js", later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	second := New(r, tmpDir, WithCachePersistence(cacheDir))
+	restored, ok := second.Cache().Get(second.cacheKey("/app.js"))
+	if !ok {
+		t.Fatal("expected /app.js to be restored from persisted cache")
+	}
+	if string(restored.Data) != appJS {
+		t.Error("expected restored data to match source")
+	}
+	if restored.Gzipped == nil {
+		t.Error("expected restored entry to keep gzip version")
+	}
+	if _, ok := second.Cache().Get(second.cacheKey("/old.js")); ok {
+		t.Error("expected stale /old.js to be dropped on import")
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/app.js", nil)
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != appJS {
+		t.Errorf("expected restored file to be served, got %d", w.Code)
+	}
+
+	// Cache 级别的往返：Import 不校验源文件
+	cache := NewCache(1<<20, 10, nil)
+	if err := cache.Import(cacheDir); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if cache.FileCount() != 2 {
+		t.Errorf("expected 2 imported entries, got %d", cache.FileCount())
+	}
+	if err := NewCache(1<<20, 10, nil).Import(t.TempDir()); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist for empty dir, got %v", err)
+	}
+}
+
+// TestStaticEngineEncodingPriority 测试按客户端 q 值与服务端优先级协商预压缩编码
+func TestStaticEngineEncodingPriority(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	content := bytes.Repeat([]byte("console.log('priority');\n"), 100)
+	zstdData, err := ZstdCompress(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzData, err := GzipCompress(content, gzip.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	files := map[string][]byte{"": content, ".br": []byte("brotli-body"), ".zst": zstdData, ".gz": gzData}
+	for ext, data := range files {
+		if err := os.WriteFile(dir+"/app.js"+ext, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		opts           []Option
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		{"q 值相同按默认优先级", nil, "gzip, zstd, br", "br"},
+		{"客户端 q 值优先", nil, "br;q=0.5, zstd;q=0.8, gzip;q=1", "gzip"},
+		{"自定义优先级", []Option{WithEncodingPriority("zstd", "br", "gzip")}, "gzip, zstd, br", "zstd"},
+		{"优先级未列出的编码不使用", []Option{WithEncodingPriority("gzip")}, "br, zstd", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			New(r, dir, append([]Option{WithPrecompressed()}, tt.opts...)...)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/app.js", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if enc := w.Header().Get("Content-Encoding"); enc != tt.wantEncoding {
+				t.Errorf("expected Content-Encoding %q, got %q", tt.wantEncoding, enc)
+			}
+		})
+	}
+}
+
+// TestStaticEngineRedirectStatus 测试目录重定向使用配置的状态码
+func TestStaticEngineRedirectStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(dir+"/docs", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/docs/index.html", []byte("<html>docs</html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		serveHTTP  bool
+		wantStatus int
+	}{
+		{"默认 301", nil, false, http.StatusMovedPermanently},
+		{"308 保留方法", []Option{WithRedirectStatus(http.StatusPermanentRedirect)}, false, http.StatusPermanentRedirect},
+		{"302 临时重定向", []Option{WithRedirectStatus(http.StatusFound)}, false, http.StatusFound},
+		{"ServeHTTP 使用相同状态码", []Option{WithRedirectStatus(http.StatusTemporaryRedirect)}, true, http.StatusTemporaryRedirect},
+		{"非 3xx 忽略", []Option{WithRedirectStatus(http.StatusOK)}, false, http.StatusMovedPermanently},
+		{"304 不是重定向", []Option{WithRedirectStatus(http.StatusNotModified)}, false, http.StatusMovedPermanently},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			engine := New(r, dir, tt.opts...)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/docs", nil)
+			if tt.serveHTTP {
+				engine.ServeHTTP(w, req)
+			} else {
+				r.ServeHTTP(w, req)
+			}
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if got := w.Header().Get("Location"); got != "/docs/" {
+				t.Errorf("expected Location %q, got %q", "/docs/", got)
+			}
+		})
+	}
+}
+
+// TestStaticEnginePrivateCacheWhen 测试已认证请求的 Cache-Control 使用 private
+func TestStaticEnginePrivateCacheWhen(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(tmpDir+"/app.js", []byte("console.log('auth');"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	authenticated := func(c *gin.Context) bool {
+		return c.GetHeader("Authorization") != ""
+	}
+
+	tests := []struct {
+		name      string
+		auth      string
+		serveHTTP bool
+		want      string
+	}{
+		{"匿名请求", "", false, "public, max-age=3600"},
+		{"已认证请求", "Bearer token", false, "private, max-age=3600"},
+		{"ServeHTTP 匿名请求", "", true, "public, max-age=3600"},
+		{"ServeHTTP 已认证请求", "Bearer token", true, "private, max-age=3600"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			engine := New(r, tmpDir, WithCacheControl("public, max-age=3600"), WithPrivateCacheWhen(authenticated))
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/app.js", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			if tt.serveHTTP {
+				engine.ServeHTTP(w, req)
+			} else {
+				r.ServeHTTP(w, req)
+			}
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if got := w.Header().Get("Cache-Control"); got != tt.want {
+				t.Errorf("expected Cache-Control %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStaticEngineTransform(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/index.html", []byte(`<script nonce="{{nonce}}"></script>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/app.js", []byte("console.log('{{nonce}}')"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 按请求头注入 nonce，分两次写入以验证流式分块
+	transform := func(c *gin.Context, path string, data []byte, w io.Writer) error {
+		parts := bytes.SplitN(data, []byte("{{nonce}}"), 2)
+		if _, err := w.Write(parts[0]); err != nil {
+			return err
+		}
+		if len(parts) == 2 {
+			if _, err := io.WriteString(w, c.GetHeader("X-Nonce")); err != nil {
+				return err
+			}
+			if _, err := w.Write(parts[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	want := `<script nonce="abc123"></script>`
+
+	t.Run("缓冲输出带 Content-Length", func(t *testing.T) {
+		r := gin.New()
+		New(r, dir, WithTransform(transform))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/index.html", nil)
+		req.Header.Set("X-Nonce", "abc123")
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		if w.Body.String() != want {
+			t.Errorf("expected body %q, got %q", want, w.Body.String())
+		}
+		if got := w.Header().Get("Content-Length"); got != fmt.Sprint(len(want)) {
+			t.Errorf("expected Content-Length %d, got %q", len(want), got)
+		}
+		if w.Header().Get("ETag") != "" {
+			t.Errorf("expected no ETag on transformed response, got %q", w.Header().Get("ETag"))
+		}
+		if got := w.Header().Get("Cache-Control"); got != "no-cache" {
+			t.Errorf("expected Cache-Control no-cache, got %q", got)
+		}
+	})
+
+	t.Run("未匹配扩展名不改写", func(t *testing.T) {
+		r := gin.New()
+		New(r, dir, WithTransform(transform))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/app.js", nil)
+		req.Header.Set("X-Nonce", "abc123")
+		r.ServeHTTP(w, req)
+
+		if got := w.Body.String(); got != "console.log('{{nonce}}')" {
+			t.Errorf("expected untouched body, got %q", got)
+		}
+	})
+
+	t.Run("流式输出为 chunked", func(t *testing.T) {
+		r := gin.New()
+		New(r, dir, WithTransform(transform), WithStreamingTransform())
+		srv := httptest.NewServer(r)
+		defer srv.Close()
+
+		req, _ := http.NewRequest("GET", srv.URL+"/index.html", nil)
+		req.Header.Set("X-Nonce", "abc123")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+		if string(body) != want {
+			t.Errorf("expected body %q, got %q", want, body)
+		}
+		if resp.ContentLength != -1 {
+			t.Errorf("expected unknown Content-Length, got %d", resp.ContentLength)
+		}
+		if len(resp.TransferEncoding) == 0 || resp.TransferEncoding[0] != "chunked" {
+			t.Errorf("expected chunked transfer encoding, got %v", resp.TransferEncoding)
+		}
+	})
+
+	t.Run("改写失败返回 500", func(t *testing.T) {
+		var gotOp string
+		r := gin.New()
+		New(r, dir,
+			WithTransform(func(c *gin.Context, path string, data []byte, w io.Writer) error {
+				return errors.New("boom")
+			}),
+			WithOnError(func(path, op string, err error) { gotOp = op }),
+		)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/index.html", nil)
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status 500, got %d", w.Code)
+		}
+		if gotOp != ErrorOpTransform {
+			t.Errorf("expected OnError op %q, got %q", ErrorOpTransform, gotOp)
+		}
+	})
+}
+
+func TestStaticEngineMinimalHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/app.js", []byte(strings.Repeat("console.log('minimal');", 100)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		gzip      bool
+		serveHTTP bool
+		want      []string
+	}{
+		{"未压缩", false, false, []string{"Content-Length", "Content-Type"}},
+		{"压缩时带 Content-Encoding", true, false, []string{"Content-Encoding", "Content-Length", "Content-Type"}},
+		{"ServeHTTP 同样精简", true, true, []string{"Content-Encoding", "Content-Length", "Content-Type"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			engine := New(r, dir, WithMinimalHeaders(), WithGzip(gzip.DefaultCompression))
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/app.js", nil)
+			if tt.gzip {
+				req.Header.Set("Accept-Encoding", "gzip")
+			}
+			if tt.serveHTTP {
+				engine.ServeHTTP(w, req)
+			} else {
+				r.ServeHTTP(w, req)
+			}
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			var got []string
+			for k := range w.Header() {
+				got = append(got, k)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected headers %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStaticEngineRegisterMimeType(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	RegisterMimeType(".glb", "model/gltf-binary")
+	t.Cleanup(func() {
+		registeredMimeTypesMu.Lock()
+		delete(registeredMimeTypes, "glb")
+		registeredMimeTypesMu.Unlock()
+	})
+
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/scene.glb", []byte("glTF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(r *gin.Engine) string {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/scene.glb", nil)
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		return w.Header().Get("Content-Type")
+	}
+
+	t.Run("引擎使用注册类型", func(t *testing.T) {
+		r := gin.New()
+		New(r, dir)
+		if got := serve(r); got != "model/gltf-binary" {
+			t.Errorf("expected Content-Type model/gltf-binary, got %q", got)
+		}
+	})
+
+	t.Run("中间件使用注册类型", func(t *testing.T) {
+		r := gin.New()
+		r.Use(StaticFileExtsMiddleware(dir, WithMiddlewareStaticExts([]string{".glb"})))
+		if got := serve(r); got != "model/gltf-binary" {
+			t.Errorf("expected Content-Type model/gltf-binary, got %q", got)
+		}
+	})
+
+	t.Run("WithMimeTypes 优先", func(t *testing.T) {
+		r := gin.New()
+		New(r, dir, WithMimeTypes(map[string]string{"glb": "application/octet-stream"}))
+		if got := serve(r); got != "application/octet-stream" {
+			t.Errorf("expected Content-Type application/octet-stream, got %q", got)
+		}
+	})
+
+	t.Run("覆盖内置类型", func(t *testing.T) {
+		RegisterMimeType("md", "text/x-markdown")
+		t.Cleanup(func() {
+			registeredMimeTypesMu.Lock()
+			delete(registeredMimeTypes, "md")
+			registeredMimeTypesMu.Unlock()
+		})
+		if got := GetMimeType("README.md", nil); got != "text/x-markdown" {
+			t.Errorf("expected registered type to override builtin, got %q", got)
+		}
+	})
+}
+
+func TestStaticEngineServedFrom(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/app.js", []byte("console.log('disk')"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	assets := fstest.MapFS{"app.js": {Data: []byte("console.log('embed')")}}
+
+	tests := []struct {
+		name       string
+		embed      bool
+		path       string
+		opts       []Option
+		wantSource string
+		wantHeader string
+	}{
+		{"磁盘", false, "/app.js", []Option{WithDebugHeaders()}, SourceDisk, SourceDisk},
+		{"embed", true, "/app.js", []Option{WithDebugHeaders()}, SourceEmbed, SourceEmbed},
+		{"内置回退", false, "/robots.txt", []Option{WithDebugHeaders(), WithDefaultRobots("User-agent: *")}, SourceDefault, SourceDefault},
+		{"未开启调试头", false, "/app.js", nil, SourceDisk, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stat *FileStat
+			var info *ResponseInfo
+			opts := append([]Option{
+				WithBeforeServe(func(c *gin.Context, s *FileStat) error { stat = s; return nil }),
+				WithOnResponse(func(ri *ResponseInfo) { info = ri }),
+			}, tt.opts...)
+
+			r := gin.New()
+			if tt.embed {
+				NewEmbed(r, assets, opts...)
+			} else {
+				New(r, dir, opts...)
+			}
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if got := w.Header().Get("X-Served-From"); got != tt.wantHeader {
+				t.Errorf("expected X-Served-From %q, got %q", tt.wantHeader, got)
+			}
+			if info == nil || info.Source != tt.wantSource {
+				t.Errorf("expected ResponseInfo.Source %q, got %+v", tt.wantSource, info)
+			}
+			// 内置回退内容不经过 BeforeServe
+			if tt.wantSource != SourceDefault && (stat == nil || stat.Source != tt.wantSource) {
+				t.Errorf("expected FileStat.Source %q, got %+v", tt.wantSource, stat)
+			}
+		})
+	}
+}
+
+func TestStaticEngineETagProvider(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/app.js", []byte("console.log('cdn')"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/other.js", []byte("console.log('local')"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var asked []string
+	provider := func(relPath string) (string, bool) {
+		asked = append(asked, relPath)
+		if relPath == "/app.js" {
+			return "cdn-v42", true
+		}
+		return "", false
+	}
+
+	for _, serveHTTP := range []bool{false, true} {
+		t.Run(fmt.Sprintf("serveHTTP=%v", serveHTTP), func(t *testing.T) {
+			asked = nil
+			r := gin.New()
+			engine := New(r, dir, WithETagProvider(provider))
+			serve := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", path, nil)
+				if ifNoneMatch != "" {
+					req.Header.Set("If-None-Match", ifNoneMatch)
+				}
+				if serveHTTP {
+					engine.ServeHTTP(w, req)
+				} else {
+					r.ServeHTTP(w, req)
+				}
+				return w
+			}
+
+			w := serve("/app.js", "")
+			if got := w.Header().Get("ETag"); got != `"cdn-v42"` {
+				t.Fatalf("expected provided ETag %q, got %q", `"cdn-v42"`, got)
+			}
+			if len(asked) == 0 || asked[0] != "/app.js" {
+				t.Errorf("expected provider called with /app.js, got %v", asked)
+			}
+
+			if w := serve("/app.js", `"cdn-v42"`); w.Code != http.StatusNotModified {
+				t.Errorf("expected 304 for provided ETag, got %d", w.Code)
+			}
+			if w := serve("/app.js", `"stale"`); w.Code != http.StatusOK {
+				t.Errorf("expected 200 for mismatched ETag, got %d", w.Code)
+			}
+
+			// 提供方返回 false 时回退到内置计算
+			w = serve("/other.js", "")
+			builtin := w.Header().Get("ETag")
+			if builtin == "" || builtin == `"cdn-v42"` {
+				t.Fatalf("expected builtin ETag for other.js, got %q", builtin)
+			}
+			if w := serve("/other.js", builtin); w.Code != http.StatusNotModified {
+				t.Errorf("expected 304 for builtin ETag, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestStaticEngineContentAddressed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(dir+"/assets", 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("console.log('v1')")
+	if err := os.WriteFile(dir+"/assets/app.js", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/index.html", []byte("<html></html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	tampered := strings.Repeat("0", 64)
+
+	for _, spa := range []bool{false, true} {
+		t.Run(fmt.Sprintf("spa=%v", spa), func(t *testing.T) {
+			r := gin.New()
+			opts := []Option{WithContentAddressed(map[string]string{"/assets/app.js": hash})}
+			if spa {
+				opts = append(opts, WithSPA("index.html"))
+			}
+			engine := New(r, dir, opts...)
+
+			hashedPath, ok := engine.ContentAddressedPath("/assets/app.js")
+			if !ok || hashedPath != "/assets/"+hash+"/app.js" {
+				t.Fatalf("expected hashed path, got %q, %v", hashedPath, ok)
+			}
+
+			serve := func(path string) *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", path, nil)
+				r.ServeHTTP(w, req)
+				return w
+			}
+
+			w := serve(hashedPath)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if w.Body.String() != string(content) {
+				t.Errorf("expected body %q, got %q", content, w.Body.String())
+			}
+			if got := w.Header().Get("Cache-Control"); got != "public, max-age=31536000, immutable" {
+				t.Errorf("expected immutable Cache-Control, got %q", got)
+			}
+
+			if w := serve("/assets/" + tampered + "/app.js"); w.Code != http.StatusNotFound {
+				t.Errorf("expected 404 for tampered hash, got %d", w.Code)
+			}
+
+			// 原路径按常规缓存策略服务
+			w = serve("/assets/app.js")
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200 for plain path, got %d", w.Code)
+			}
+			if got := w.Header().Get("Cache-Control"); strings.Contains(got, "immutable") {
+				t.Errorf("expected regular Cache-Control for plain path, got %q", got)
+			}
+		})
+	}
+
+	t.Run("文件内容与清单不一致", func(t *testing.T) {
+		r := gin.New()
+		New(r, dir, WithContentAddressed(map[string]string{"/assets/app.js": tampered}))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/assets/"+tampered+"/app.js", nil)
+		r.ServeHTTP(w, req)
+
+		if w.Code == http.StatusOK {
+			t.Errorf("expected content mismatch to be rejected, got %d", w.Code)
+		}
+	})
+}
+
+// gatedFS 统计 Open 次数，gate 不为 nil 时 Open 阻塞直到 gate 关闭
+type gatedFS struct {
+	fs.FS
+	opens int32
+	gate  chan struct{}
+}
+
+func (g *gatedFS) Open(name string) (fs.File, error) {
+	atomic.AddInt32(&g.opens, 1)
+	if g.gate != nil {
+		<-g.gate
+	}
+	return g.FS.Open(name)
+}
+
+// TestStaticEngineStaleWhileRevalidate 测试缓存重置后刷新期间返回旧内容，且每个文件只刷新一次
+func TestStaticEngineStaleWhileRevalidate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	files := fstest.MapFS{"app.js": {Data: []byte("console.log('v1')")}}
+	src := &gatedFS{FS: files}
+	r := gin.New()
+	engine := NewEmbed(r, src, WithStaleWhileRevalidate(time.Minute))
+
+	get := func() string {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/app.js", nil)
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d", w.Code)
+		}
+		return w.Body.String()
+	}
+
+	if got := get(); got != "console.log('v1')" {
+		t.Fatalf("expected v1, got %q", got)
+	}
+	opensPerLoad := atomic.LoadInt32(&src.opens)
+
+	// 模拟部署：内容更新后重置缓存，刷新阻塞在 gate 上
+	files["app.js"] = &fstest.MapFile{Data: []byte("console.log('v2-updated')")}
+	src.gate = make(chan struct{})
+	engine.ResetCache()
+
+	var wg sync.WaitGroup
+	var stale int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if get() == "console.log('v1')" {
+				atomic.AddInt32(&stale, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if stale != 50 {
+		t.Errorf("expected all 50 requests served stale content during refresh, got %d", stale)
+	}
+
+	close(src.gate)
+	deadline := time.Now().Add(5 * time.Second)
+	for get() != "console.log('v2-updated')" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected refreshed content after revalidation")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if refreshes := (atomic.LoadInt32(&src.opens) - opensPerLoad) / opensPerLoad; refreshes != 1 {
+		t.Errorf("expected exactly one refresh, got %d (per load %d opens)", refreshes, opensPerLoad)
+	}
+}
+
+func TestStaticEngineStripBOM(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	html := "<html><body>" + strings.Repeat("bom", 500) + "</body></html>"
+	bom := "\xEF\xBB\xBF"
+	if err := os.WriteFile(dir+"/index.html", []byte(bom+html), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/data.bin", []byte(bom+"binary"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	New(r, dir, WithStripBOM(), WithGzip(gzip.DefaultCompression))
+
+	t.Run("HTML 去除 BOM", func(t *testing.T) {
+		// 第二次请求命中缓存，结果应一致
+		for i := 0; i < 2; i++ {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/index.html", nil)
+			r.ServeHTTP(w, req)
+
+			if w.Body.String() != html {
+				t.Fatalf("expected body without BOM, got prefix %q", w.Body.String()[:3])
+			}
+			if got := w.Header().Get("Content-Length"); got != strconv.Itoa(len(html)) {
+				t.Errorf("expected Content-Length %d, got %s", len(html), got)
+			}
+		}
+	})
+
+	t.Run("压缩内容同样去除 BOM", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/index.html", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		r.ServeHTTP(w, req)
+
+		if w.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("expected gzip response, got %q", w.Header().Get("Content-Encoding"))
+		}
+		data, err := GzipDecompress(w.Body.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != html {
+			t.Errorf("expected decompressed body without BOM")
+		}
+	})
+
+	t.Run("非文本类型保持不变", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/data.bin", nil)
+		r.ServeHTTP(w, req)
+
+		if w.Body.String() != bom+"binary" {
+			t.Errorf("expected binary content unchanged, got %q", w.Body.String())
+		}
+	})
+}
+
+func TestStaticEngineRangeOverEncoded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	content := strings.Repeat("range over encoded bytes; ", 200)
+	if err := os.WriteFile(dir+"/app.js", []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	New(r, dir, WithGzip(gzip.DefaultCompression), WithRangeOverEncoded(), WithEncodingETagSuffix())
+
+	get := func(header map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/app.js", nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	full := get(map[string]string{"Accept-Encoding": "gzip"})
+	if full.Code != http.StatusOK || full.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected full gzip response, got %d %q", full.Code, full.Header().Get("Content-Encoding"))
+	}
+	if full.Header().Get("Accept-Ranges") != "bytes" {
+		t.Errorf("expected Accept-Ranges bytes, got %q", full.Header().Get("Accept-Ranges"))
+	}
+	blob := full.Body.Bytes()
+	etag := full.Header().Get("ETag")
+
+	t.Run("区间偏移对应压缩内容", func(t *testing.T) {
+		var joined []byte
+		for _, spec := range []string{"0-9", "10-"} {
+			w := get(map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=" + spec, "If-Range": etag})
+			if w.Code != http.StatusPartialContent {
+				t.Fatalf("expected status 206 for %s, got %d", spec, w.Code)
+			}
+			if w.Header().Get("Content-Encoding") != "gzip" {
+				t.Errorf("expected Content-Encoding gzip, got %q", w.Header().Get("Content-Encoding"))
+			}
+			joined = append(joined, w.Body.Bytes()...)
+		}
+		if !bytes.Equal(joined, blob) {
+			t.Fatal("expected concatenated ranges to equal the gzip blob")
+		}
+		data, err := GzipDecompress(joined)
+		if err != nil || string(data) != content {
+			t.Errorf("expected concatenated ranges to decode to original content, err %v", err)
+		}
+	})
+
+	t.Run("Content-Range 以压缩长度为全长", func(t *testing.T) {
+		w := get(map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=-5"})
+		want := fmt.Sprintf("bytes %d-%d/%d", len(blob)-5, len(blob)-1, len(blob))
+		if got := w.Header().Get("Content-Range"); got != want {
+			t.Errorf("expected Content-Range %q, got %q", want, got)
+		}
+		if !bytes.Equal(w.Body.Bytes(), blob[len(blob)-5:]) {
+			t.Error("expected suffix range of gzip blob")
+		}
+		if got := w.Header().Get("Content-Length"); got != "5" {
+			t.Errorf("expected Content-Length 5, got %s", got)
+		}
+	})
+
+	t.Run("超出压缩长度返回 416", func(t *testing.T) {
+		w := get(map[string]string{"Accept-Encoding": "gzip", "Range": fmt.Sprintf("bytes=%d-", len(blob))})
+		if w.Code != http.StatusRequestedRangeNotSatisfiable {
+			t.Fatalf("expected status 416, got %d", w.Code)
+		}
+		if got := w.Header().Get("Content-Range"); got != fmt.Sprintf("bytes */%d", len(blob)) {
+			t.Errorf("expected unsatisfied Content-Range, got %q", got)
+		}
+	})
+
+	t.Run("If-Range 不匹配返回完整内容", func(t *testing.T) {
+		w := get(map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-9", "If-Range": `"stale"`})
+		if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), blob) {
+			t.Errorf("expected full response, got %d", w.Code)
+		}
+	})
+
+	t.Run("多区间返回完整内容", func(t *testing.T) {
+		w := get(map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-1,5-6"})
+		if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), blob) {
+			t.Errorf("expected full response, got %d", w.Code)
+		}
+	})
+
+	t.Run("未编码的响应按原始字节", func(t *testing.T) {
+		w := get(map[string]string{"Range": "bytes=0-9"})
+		if w.Code != http.StatusPartialContent || w.Body.String() != content[:10] {
+			t.Errorf("expected identity range response, got %d", w.Code)
+		}
+		if got := w.Header().Get("Content-Range"); got != fmt.Sprintf("bytes 0-9/%d", len(content)) {
+			t.Errorf("expected Content-Range over raw length, got %q", got)
+		}
+	})
+}
+
+func TestStaticEngineBrotli(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	content := strings.Repeat("function brotli() { return 'compressed'; }\n", 100)
+	if err := os.WriteFile(dir+"/app.js", []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/small.js", []byte("var a = 1;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		opts           []Option
+		path           string
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		{"优先使用 br", []Option{WithBrotli(DefaultBrotliLevel)}, "/app.js", "gzip, deflate, br", "br"},
+		{"客户端不支持 br 时回退 gzip", []Option{WithBrotli(DefaultBrotliLevel)}, "/app.js", "gzip", "gzip"},
+		{"小文件不压缩", []Option{WithBrotli(DefaultBrotliLevel), DisableGzip()}, "/small.js", "br", ""},
+		{"无缓存时实时压缩", []Option{WithBrotli(11), DisableCache()}, "/app.js", "br", "br"},
+		{"未启用时不使用 br", nil, "/app.js", "br", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			engine := New(r, dir, tt.opts...)
+
+			// 第二次请求命中缓存中的 .br 版本
+			for i := 0; i < 2; i++ {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", tt.path, nil)
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+				r.ServeHTTP(w, req)
+
+				if got := w.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+					t.Fatalf("expected Content-Encoding %q, got %q", tt.wantEncoding, got)
+				}
+				if tt.wantEncoding != "br" {
+					continue
+				}
+				data, err := BrotliDecompress(w.Body.Bytes())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != content {
+					t.Error("expected decompressed body to match original")
+				}
+			}
+
+			if tt.wantEncoding == "br" && engine.config.EnableCache {
+				entry, ok := engine.cache.Get(engine.cacheKey("/app.js"))
+				if !ok || entry.Brotli == nil {
+					t.Error("expected cached brotli variant")
+				}
+			}
+		})
+	}
+}
+
+// TestStaticEnginePrecompressedSidecarCache 测试 .gz/.br 预压缩文件直接返回并存入缓存
+func TestStaticEnginePrecompressedSidecarCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	content := bytes.Repeat([]byte("console.log('sidecar');\n"), 100)
+	gzData, err := GzipCompress(content, gzip.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	brData, err := BrotliCompress(content, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("磁盘", func(t *testing.T) {
+		dir := t.TempDir()
+		for ext, data := range map[string][]byte{"": content, ".gz": gzData, ".br": brData} {
+			if err := os.WriteFile(dir+"/app.js"+ext, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		r := gin.New()
+		// 未启用 Brotli 实时压缩，br 只能来自预压缩文件
+		New(r, dir, WithPrecompressed())
+
+		get := func(acceptEncoding string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/app.js", nil)
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+			r.ServeHTTP(w, req)
+			return w
+		}
+
+		// 首次请求载入缓存，之后删除预压缩文件仍返回缓存中的版本
+		get("br")
+		for _, ext := range []string{".gz", ".br"} {
+			if err := os.Remove(dir + "/app.js" + ext); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for enc, want := range map[string][]byte{"br": brData, "gzip": gzData} {
+			w := get(enc)
+			if got := w.Header().Get("Content-Encoding"); got != enc {
+				t.Fatalf("expected Content-Encoding %q, got %q", enc, got)
+			}
+			if !bytes.Equal(w.Body.Bytes(), want) {
+				t.Errorf("expected %s sidecar bytes from cache", enc)
+			}
+		}
+	})
+
+	t.Run("embed", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"app.js":    {Data: content},
+			"app.js.br": {Data: brData},
+		}
+		r := gin.New()
+		NewEmbed(r, fsys, WithPrecompressed())
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/app.js", nil)
+		req.Header.Set("Accept-Encoding", "br")
+		r.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Content-Encoding"); got != "br" {
+			t.Fatalf("expected Content-Encoding br, got %q", got)
+		}
+		data, err := BrotliDecompress(w.Body.Bytes())
+		if err != nil || !bytes.Equal(data, content) {
+			t.Errorf("expected brotli sidecar to decode to original, err %v", err)
+		}
+	})
+}
+
+func TestStaticEngineRange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/video.mp4", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"video.mp4": {Data: content}}
+
+	tests := []struct {
+		name      string
+		rangeSpec string
+		ifRange   string
+		status    int
+		want      []byte
+		wantRange string
+	}{
+		{"无 Range 返回完整内容", "", "", http.StatusOK, content, ""},
+		{"单区间", "bytes=10-19", "", http.StatusPartialContent, content[10:20], "bytes 10-19/1000"},
+		{"开放区间", "bytes=990-", "", http.StatusPartialContent, content[990:], "bytes 990-999/1000"},
+		{"后缀区间", "bytes=-5", "", http.StatusPartialContent, content[995:], "bytes 995-999/1000"},
+		{"结束位置超出截断", "bytes=995-5000", "", http.StatusPartialContent, content[995:], "bytes 995-999/1000"},
+		{"起点超出返回 416", "bytes=1000-", "", http.StatusRequestedRangeNotSatisfiable, nil, "bytes */1000"},
+		{"多区间返回完整内容", "bytes=0-1,5-6", "", http.StatusOK, content, ""},
+		{"If-Range 不匹配返回完整内容", "bytes=0-9", `"stale"`, http.StatusOK, content, ""},
+	}
+
+	backends := []struct {
+		name      string
+		newEngine func(r *gin.Engine) *StaticEngine
+		serveHTTP bool
+	}{
+		{"磁盘", func(r *gin.Engine) *StaticEngine { return New(r, dir) }, false},
+		{"embed", func(r *gin.Engine) *StaticEngine { return NewEmbed(r, fsys) }, false},
+		{"ServeHTTP", func(r *gin.Engine) *StaticEngine { return New(r, dir) }, true},
+	}
+
+	for _, b := range backends {
+		for _, tt := range tests {
+			t.Run(b.name+"/"+tt.name, func(t *testing.T) {
+				r := gin.New()
+				engine := b.newEngine(r)
+
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", "/video.mp4", nil)
+				if tt.rangeSpec != "" {
+					req.Header.Set("Range", tt.rangeSpec)
+				}
+				if tt.ifRange != "" {
+					req.Header.Set("If-Range", tt.ifRange)
+				}
+				if b.serveHTTP {
+					engine.ServeHTTP(w, req)
+				} else {
+					r.ServeHTTP(w, req)
+				}
+
+				if w.Code != tt.status {
+					t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+				}
+				if got := w.Header().Get("Content-Range"); got != tt.wantRange {
+					t.Errorf("expected Content-Range %q, got %q", tt.wantRange, got)
+				}
+				if !bytes.Equal(w.Body.Bytes(), tt.want) {
+					t.Errorf("expected %d body bytes, got %d", len(tt.want), w.Body.Len())
+				}
+				if tt.status != http.StatusRequestedRangeNotSatisfiable {
+					if got := w.Header().Get("Accept-Ranges"); got != "bytes" {
+						t.Errorf("expected Accept-Ranges bytes, got %q", got)
+					}
+					if got := w.Header().Get("Content-Length"); got != strconv.Itoa(len(tt.want)) {
+						t.Errorf("expected Content-Length %d, got %s", len(tt.want), got)
+					}
+				}
+			})
+		}
+	}
+
+	t.Run("If-Range 与 ETag 匹配", func(t *testing.T) {
+		r := gin.New()
+		New(r, dir)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/video.mp4", nil)
+		r.ServeHTTP(w, req)
+		etag := w.Header().Get("ETag")
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/video.mp4", nil)
+		req.Header.Set("Range", "bytes=0-9")
+		req.Header.Set("If-Range", etag)
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusPartialContent || !bytes.Equal(w.Body.Bytes(), content[:10]) {
+			t.Errorf("expected 206 with first 10 bytes, got %d", w.Code)
+		}
+	})
+}
+
+func TestStaticEngineHead(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	content := strings.Repeat("body { color: red; }\n", 100)
+	if err := os.WriteFile(dir+"/app.css", []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	engine := New(r, dir, WithGzip(gzip.DefaultCompression))
+
+	do := func(method string, header map[string]string, serveHTTP bool) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/app.css", nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		if serveHTTP {
+			engine.ServeHTTP(w, req)
+		} else {
+			r.ServeHTTP(w, req)
+		}
+		return w
+	}
+
+	for _, serveHTTP := range []bool{false, true} {
+		for _, acceptEncoding := range []string{"", "gzip"} {
+			name := fmt.Sprintf("serveHTTP=%v/Accept-Encoding=%q", serveHTTP, acceptEncoding)
+			t.Run(name, func(t *testing.T) {
+				header := map[string]string{"Accept-Encoding": acceptEncoding}
+				get := do(http.MethodGet, header, serveHTTP)
+				head := do(http.MethodHead, header, serveHTTP)
+
+				if head.Code != http.StatusOK {
+					t.Fatalf("expected status 200, got %d", head.Code)
+				}
+				if head.Body.Len() != 0 {
+					t.Errorf("expected empty body, got %d bytes", head.Body.Len())
+				}
+				for _, key := range []string{"Content-Length", "Content-Type", "Content-Encoding", "ETag", "Last-Modified"} {
+					if got, want := head.Header().Get(key), get.Header().Get(key); got != want {
+						t.Errorf("expected %s %q, got %q", key, want, got)
+					}
+				}
+				if head.Header().Get("Content-Length") != strconv.Itoa(get.Body.Len()) {
+					t.Errorf("expected Content-Length %d, got %s", get.Body.Len(), head.Header().Get("Content-Length"))
+				}
+			})
+		}
+	}
+
+	t.Run("条件请求返回 304", func(t *testing.T) {
+		etag := do(http.MethodGet, nil, false).Header().Get("ETag")
+		w := do(http.MethodHead, map[string]string{"If-None-Match": etag}, false)
+		if w.Code != http.StatusNotModified {
+			t.Errorf("expected status 304, got %d", w.Code)
+		}
+	})
+
+	t.Run("不存在的文件返回 404", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodHead, "/missing.css", nil)
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", w.Code)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("expected empty body, got %d bytes", w.Body.Len())
+		}
+	})
+}
+
+func TestStaticEngineStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	big := strings.Repeat("0123456789", 1000)
+	if err := os.WriteFile(dir+"/big.txt", []byte(big), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/small.txt", []byte("small"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	engine := New(r, dir, WithStreamThreshold(4096))
+
+	do := func(method, path string, header map[string]string, serveHTTP bool) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		if serveHTTP {
+			engine.ServeHTTP(w, req)
+		} else {
+			r.ServeHTTP(w, req)
+		}
+		return w
+	}
+
+	for _, serveHTTP := range []bool{false, true} {
+		t.Run(fmt.Sprintf("serveHTTP=%v", serveHTTP), func(t *testing.T) {
+			w := do(http.MethodGet, "/big.txt", map[string]string{"Accept-Encoding": "gzip"}, serveHTTP)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if w.Body.String() != big {
+				t.Errorf("expected full body, got %d bytes", w.Body.Len())
+			}
+			if w.Header().Get("Content-Encoding") != "" {
+				t.Errorf("expected no Content-Encoding, got %q", w.Header().Get("Content-Encoding"))
+			}
+			if w.Header().Get("ETag") == "" {
+				t.Error("expected ETag header")
+			}
+
+			w = do(http.MethodGet, "/big.txt", map[string]string{"Range": "bytes=10-19"}, serveHTTP)
+			if w.Code != http.StatusPartialContent {
+				t.Fatalf("expected status 206, got %d", w.Code)
+			}
+			if w.Body.String() != big[10:20] {
+				t.Errorf("expected range body %q, got %q", big[10:20], w.Body.String())
+			}
+			if got := w.Header().Get("Content-Range"); got != "bytes 10-19/10000" {
+				t.Errorf("expected Content-Range bytes 10-19/10000, got %q", got)
+			}
+
+			w = do(http.MethodHead, "/big.txt", nil, serveHTTP)
+			if w.Code != http.StatusOK || w.Body.Len() != 0 {
+				t.Errorf("expected HEAD 200 with empty body, got %d with %d bytes", w.Code, w.Body.Len())
+			}
+			if w.Header().Get("Content-Length") != "10000" {
+				t.Errorf("expected Content-Length 10000, got %q", w.Header().Get("Content-Length"))
+			}
+		})
+	}
+
+	t.Run("条件请求返回 304", func(t *testing.T) {
+		etag := do(http.MethodGet, "/big.txt", nil, false).Header().Get("ETag")
+		w := do(http.MethodGet, "/big.txt", map[string]string{"If-None-Match": etag}, false)
+		if w.Code != http.StatusNotModified {
+			t.Errorf("expected status 304, got %d", w.Code)
+		}
+	})
+
+	t.Run("大文件不进入缓存", func(t *testing.T) {
+		if _, ok := engine.cache.Get(engine.cacheKey("/big.txt")); ok {
+			t.Error("expected streamed file not to be cached")
+		}
+		if w := do(http.MethodGet, "/small.txt", nil, false); w.Body.String() != "small" {
+			t.Fatalf("expected small body, got %q", w.Body.String())
+		}
+		if _, ok := engine.cache.Get(engine.cacheKey("/small.txt")); !ok {
+			t.Error("expected small file to be cached")
+		}
+	})
+
+	t.Run("回调、统计与协商响应头", func(t *testing.T) {
+		if err := os.WriteFile(dir+"/photo.png", []byte(big), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		var stat *FileStat
+		var info *ResponseInfo
+		r := gin.New()
+		engine := New(r, dir,
+			WithStreamThreshold(4096),
+			WithReadBufferSize(512),
+			WithImageNegotiation(),
+			WithBeforeServe(func(c *gin.Context, s *FileStat) error {
+				stat = s
+				return nil
+			}),
+			WithOnResponse(func(i *ResponseInfo) {
+				info = i
+			}),
+		)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/photo.png", nil)
+		req.Header.Set("Accept", "image/avif")
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || w.Body.String() != big {
+			t.Fatalf("expected full streamed body, got %d with %d bytes", w.Code, w.Body.Len())
+		}
+		if !strings.Contains(strings.Join(w.Header().Values("Vary"), ","), "Accept") {
+			t.Errorf("expected Vary: Accept, got %v", w.Header().Values("Vary"))
+		}
+		if stat == nil || stat.Size != int64(len(big)) || stat.Source != SourceDisk {
+			t.Errorf("expected BeforeServe with disk stat of %d bytes, got %+v", len(big), stat)
+		}
+		if info == nil || info.Written != len(big) || info.Size != len(big) || info.Status != http.StatusOK || info.Err != nil {
+			t.Errorf("expected OnResponse with %d bytes written, got %+v", len(big), info)
+		}
+		if raw, wire := engine.BytesServed(); raw != int64(len(big)) || wire != int64(len(big)) {
+			t.Errorf("expected %d bytes served, got raw=%d wire=%d", len(big), raw, wire)
+		}
+	})
+
+	t.Run("BeforeServe 返回错误", func(t *testing.T) {
+		r := gin.New()
+		_ = New(r, dir, WithStreamThreshold(4096), WithBeforeServe(func(c *gin.Context, s *FileStat) error {
+			return errors.New("denied")
+		}))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/big.txt", nil)
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusInternalServerError || w.Body.Len() != 0 {
+			t.Errorf("expected 500 with empty body, got %d with %d bytes", w.Code, w.Body.Len())
+		}
+	})
+}
+
+func TestStaticEngineCacheTTL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	setup := func(t *testing.T, opts ...Option) (string, func() *httptest.ResponseRecorder) {
+		dir := t.TempDir()
+		if err := os.WriteFile(dir+"/app.js", []byte("v1"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		r := gin.New()
+		New(r, dir, opts...)
+		return dir, func() *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/app.js", nil)
+			r.ServeHTTP(w, req)
+			return w
+		}
+	}
+	// modify 改写文件内容，并把修改时间推后一秒，避免文件系统时间精度导致修改时间不变
+	modify := func(t *testing.T, dir, content string) {
+		path := dir + "/app.js"
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := info.ModTime().Add(time.Second)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("未配置时修改不生效", func(t *testing.T) {
+		dir, get := setup(t)
+		get()
+		modify(t, dir, "v2")
+		if body := get().Body.String(); body != "v1" {
+			t.Errorf("expected cached v1, got %q", body)
+		}
+	})
+
+	t.Run("TTL 过期后重新加载", func(t *testing.T) {
+		dir, get := setup(t, WithCacheTTL(50*time.Millisecond))
+		get()
+		modify(t, dir, "v2")
+		if body := get().Body.String(); body != "v1" {
+			t.Errorf("expected cached v1 within TTL, got %q", body)
+		}
+		time.Sleep(60 * time.Millisecond)
+		if body := get().Body.String(); body != "v2" {
+			t.Errorf("expected v2 after TTL, got %q", body)
+		}
+	})
+
+	t.Run("重新校验发现修改", func(t *testing.T) {
+		dir, get := setup(t, WithCacheRevalidate())
+		get()
+		modify(t, dir, "v2")
+		if body := get().Body.String(); body != "v2" {
+			t.Errorf("expected v2, got %q", body)
+		}
+		if err := os.Remove(dir + "/app.js"); err != nil {
+			t.Fatal(err)
+		}
+		if w := get(); w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404 after delete, got %d", w.Code)
+		}
+	})
+
+	t.Run("TTL 内不做校验", func(t *testing.T) {
+		dir, get := setup(t, WithCacheTTL(time.Hour), WithCacheRevalidate())
+		get()
+		modify(t, dir, "v2")
+		if body := get().Body.String(); body != "v1" {
+			t.Errorf("expected cached v1 within TTL, got %q", body)
+		}
+	})
+
+	t.Run("TTL 过期且文件未变化时续期", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(dir+"/app.js", []byte("v1"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		r := gin.New()
+		engine := New(r, dir, WithCacheTTL(10*time.Millisecond), WithCacheRevalidate())
+		get := func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/app.js", nil)
+			r.ServeHTTP(w, req)
+		}
+		get()
+		entry, ok := engine.cache.Get(engine.cacheKey("/app.js"))
+		if !ok {
+			t.Fatal("expected app.js cached")
+		}
+		time.Sleep(20 * time.Millisecond)
+		get()
+		if renewed, _ := engine.cache.Get(engine.cacheKey("/app.js")); renewed != entry {
+			t.Error("expected unchanged entry to be kept")
+		}
+	})
+}
+
+func TestStaticEngineWatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/app.js", []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	engine := New(r, dir, WithWatch(), WithWatchDebounce(20*time.Millisecond), WithPrecompressed())
+	defer engine.Close()
+
+	get := func(path string) string {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		r.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	// waitEvicted 等待防抖结束后缓存条目被淘汰
+	waitEvicted := func(t *testing.T, path string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if _, ok := engine.cache.Get(engine.cacheKey(path)); !ok {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("expected %s evicted after change", path)
+	}
+
+	t.Run("修改文件后淘汰缓存", func(t *testing.T) {
+		if body := get("/app.js"); body != "v1" {
+			t.Fatalf("expected v1, got %q", body)
+		}
+		if err := os.WriteFile(dir+"/app.js", []byte("v2"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		waitEvicted(t, "/app.js")
+		if body := get("/app.js"); body != "v2" {
+			t.Errorf("expected v2, got %q", body)
+		}
+	})
+
+	t.Run("新建目录中的文件", func(t *testing.T) {
+		if err := os.MkdirAll(dir+"/sub", 0755); err != nil {
+			t.Fatal(err)
+		}
+		// 等待新目录加入监听
+		time.Sleep(50 * time.Millisecond)
+		if err := os.WriteFile(dir+"/sub/page.css", []byte("a"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+		if body := get("/sub/page.css"); body != "a" {
+			t.Fatalf("expected a, got %q", body)
+		}
+		if err := os.WriteFile(dir+"/sub/page.css", []byte("b"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		waitEvicted(t, "/sub/page.css")
+		if body := get("/sub/page.css"); body != "b" {
+			t.Errorf("expected b, got %q", body)
+		}
+	})
+
+	t.Run("删除目录淘汰其中的条目", func(t *testing.T) {
+		get("/sub/page.css")
+		if err := os.RemoveAll(dir + "/sub"); err != nil {
+			t.Fatal(err)
+		}
+		waitEvicted(t, "/sub/page.css")
+	})
+
+	t.Run("预压缩文件变化淘汰源文件", func(t *testing.T) {
+		get("/app.js")
+		if err := os.WriteFile(dir+"/app.js.gz", []byte("gz"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		waitEvicted(t, "/app.js")
+	})
+
+	t.Run("Close 停止监听", func(t *testing.T) {
+		if err := engine.Close(); err != nil {
+			t.Fatalf("close failed: %v", err)
+		}
+		get("/app.js")
+		if err := os.WriteFile(dir+"/app.js", []byte("v3"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if body := get("/app.js"); body != "v2" {
+			t.Errorf("expected cached v2 after close, got %q", body)
+		}
+	})
+}
+
+func TestStaticEngineCollector(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/app.js", []byte(strings.Repeat("console.log(1);\n", 200)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	engine := New(r, dir, WithCompressionStats(), WithCache(1024*1024, 1))
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(engine.Collector())
+
+	for _, path := range []string{"/app.js", "/app.js", "/missing.js"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		r.ServeHTTP(w, req)
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/app.js", nil)
+	engine.ServeHTTP(w, req)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("gather failed: %v", err)
+	}
+	// value 返回指标值，labels 为空时取第一个样本，直方图返回样本数
+	value := func(name string, labels ...string) float64 {
+		t.Helper()
+		for _, mf := range families {
+			if mf.GetName() != name {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				matched := true
+				for i := 0; i+1 < len(labels); i += 2 {
+					found := false
+					for _, lp := range m.GetLabel() {
+						if lp.GetName() == labels[i] && lp.GetValue() == labels[i+1] {
+							found = true
+						}
+					}
+					matched = matched && found
+				}
+				if !matched {
+					continue
+				}
+				switch {
+				case m.GetCounter() != nil:
+					return m.GetCounter().GetValue()
+				case m.GetGauge() != nil:
+					return m.GetGauge().GetValue()
+				case m.GetHistogram() != nil:
+					return float64(m.GetHistogram().GetSampleCount())
+				}
+			}
+		}
+		t.Fatalf("metric %s %v not found", name, labels)
+		return 0
+	}
+
+	tests := []struct {
+		name   string
+		metric string
+		labels []string
+		want   float64
+	}{
+		{"200 请求数", "ginstatic_requests_total", []string{"code", "200"}, 3},
+		{"404 请求数", "ginstatic_requests_total", []string{"code", "404"}, 1},
+		{"耗时直方图样本数", "ginstatic_request_duration_seconds", nil, 4},
+		{"缓存命中", "ginstatic_cache_hits_total", nil, 2},
+		// 首次读取 app.js；不存在的文件还会查找同名目录下的 index.html
+		{"缓存未命中", "ginstatic_cache_misses_total", nil, 3},
+		{"缓存文件数", "ginstatic_cache_files", nil, 1},
+		{"gzip 压缩响应数", "ginstatic_compressed_responses_total", []string{"encoding", "gzip"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := value(tt.metric, tt.labels...); got != tt.want {
+				t.Errorf("expected %s = %v, got %v", tt.metric, tt.want, got)
+			}
+		})
+	}
+
+	t.Run("字节数与压缩比", func(t *testing.T) {
+		raw, wire := engine.BytesServed()
+		if got := value("ginstatic_bytes_served_total", "kind", "raw"); got != float64(raw) {
+			t.Errorf("expected raw bytes %d, got %v", raw, got)
+		}
+		if got := value("ginstatic_bytes_served_total", "kind", "wire"); got != float64(wire) {
+			t.Errorf("expected wire bytes %d, got %v", wire, got)
+		}
+		if ratio := value("ginstatic_compression_ratio", "encoding", "gzip"); ratio <= 0 || ratio >= 1 {
+			t.Errorf("expected gzip ratio in (0, 1), got %v", ratio)
+		}
+	})
+}
+
+func TestStaticEngineAdminRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{"app.js": "console.log(1);", "app.css": "body{}"} {
+		if err := os.WriteFile(dir+"/"+name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := gin.New()
+	engine := New(r, dir)
+	// 静态文件挂在根路径时通配路由与管理接口冲突，管理接口使用独立的路由
+	admin := gin.New()
+	engine.RegisterAdminRoutes(admin.Group("/_admin"))
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		if strings.HasPrefix(path, "/_admin/") {
+			admin.ServeHTTP(w, req)
+		} else {
+			r.ServeHTTP(w, req)
+		}
+		return w
+	}
+	list := func(t *testing.T) []CacheEntryInfo {
+		t.Helper()
+		w := do(http.MethodGet, "/_admin/cache")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		var resp struct {
+			Files   int              `json:"files"`
+			Entries []CacheEntryInfo `json:"entries"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid json: %v", err)
+		}
+		if resp.Files != len(resp.Entries) {
+			t.Errorf("expected files %d, got %d", len(resp.Entries), resp.Files)
+		}
+		return resp.Entries
+	}
+
+	do(http.MethodGet, "/app.js")
+	do(http.MethodGet, "/app.js")
+	do(http.MethodGet, "/app.js")
+	do(http.MethodGet, "/app.css")
+
+	t.Run("列出缓存条目", func(t *testing.T) {
+		entries := list(t)
+		if len(entries) != 2 || entries[0].Path != "/app.css" || entries[1].Path != "/app.js" {
+			t.Fatalf("expected [/app.css /app.js], got %+v", entries)
+		}
+		if entries[1].Hits != 2 {
+			t.Errorf("expected 2 hits for /app.js, got %d", entries[1].Hits)
+		}
+		if entries[1].Size != int64(len("console.log(1);")) {
+			t.Errorf("expected size %d, got %d", len("console.log(1);"), entries[1].Size)
+		}
+		if entries[1].LastAccess.IsZero() || entries[1].ETag == "" {
+			t.Errorf("expected last access and etag, got %+v", entries[1])
+		}
+	})
+
+	t.Run("淘汰单个路径", func(t *testing.T) {
+		if w := do(http.MethodDelete, "/_admin/cache/entry?path=/app.js"); w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		if entries := list(t); len(entries) != 1 || entries[0].Path != "/app.css" {
+			t.Errorf("expected only /app.css left, got %+v", entries)
+		}
+		if w := do(http.MethodDelete, "/_admin/cache/entry?path=/app.js"); w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404 for uncached path, got %d", w.Code)
+		}
+		if w := do(http.MethodDelete, "/_admin/cache/entry"); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 without path, got %d", w.Code)
+		}
+	})
+
+	t.Run("清空缓存", func(t *testing.T) {
+		if w := do(http.MethodPost, "/_admin/cache/clear"); w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		if entries := list(t); len(entries) != 0 {
+			t.Errorf("expected empty cache, got %+v", entries)
+		}
+	})
+
+	t.Run("重新加载缓存", func(t *testing.T) {
+		if w := do(http.MethodPost, "/_admin/cache/reload"); w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if entries := list(t); len(entries) != 2 {
+			t.Errorf("expected 2 preloaded entries, got %+v", entries)
+		}
+	})
+
+	t.Run("挂载点只列出自身条目", func(t *testing.T) {
+		subDir := t.TempDir()
+		if err := os.WriteFile(subDir+"/doc.txt", []byte("doc"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mr := gin.New()
+		parent := New(mr, dir, WithPrefix("/static"))
+		sub := parent.Mount(mr, "/docs", subDir)
+		for _, path := range []string{"/static/app.js", "/docs/doc.txt"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, path, nil)
+			mr.ServeHTTP(w, req)
+		}
+		if entries := sub.CacheEntries(); len(entries) != 1 || entries[0].Path != "/doc.txt" {
+			t.Errorf("expected [/doc.txt], got %+v", entries)
+		}
+		if entries := parent.CacheEntries(); len(entries) != 1 || entries[0].Path != "/app.js" {
+			t.Errorf("expected [/app.js], got %+v", entries)
+		}
+		for _, entry := range parent.CacheEntries() {
+			if entry.Path == "/doc.txt" || strings.HasPrefix(entry.Path, "mount:") {
+				t.Errorf("expected mount entries excluded, got %s", entry.Path)
+			}
+		}
+	})
+}
+
+// TestStaticEngineServeHTTPParity 测试 ServeHTTP 与 gin 路由走同一处理流程（回退文件、回调等）
+func TestStaticEngineServeHTTPParity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/index.html", []byte("<html>index</html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/app.js", []byte("console.log('app');"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		opts   []Option
+		path   string
+		status int
+		body   string
+	}{
+		{"内置回退文件", []Option{WithDefaultFavicon([]byte("icon"))}, "/favicon.ico", http.StatusOK, "icon"},
+		{"NotFoundHandler", []Option{WithNotFoundHandler(func(c *gin.Context) {
+			c.String(http.StatusNotFound, "custom not found")
+		})}, "/missing.js", http.StatusNotFound, "custom not found"},
+		{"BeforeServe 拒绝", []Option{WithBeforeServe(func(c *gin.Context, stat *FileStat) error {
+			return errors.New("denied")
+		})}, "/app.js", http.StatusInternalServerError, ""},
+		{"OnRequest 拒绝", []Option{WithOnRequest(func(path string) bool {
+			return !strings.HasSuffix(path, ".js")
+		})}, "/app.js", http.StatusForbidden, `{"error":"forbidden"}`},
+		{"SPA 回退", []Option{WithSPA("index.html")}, "/users/42", http.StatusOK, "<html>index</html>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			engine := New(r, dir, tt.opts...)
+
+			for _, serveHTTP := range []bool{false, true} {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", tt.path, nil)
+				if serveHTTP {
+					engine.ServeHTTP(w, req)
+				} else {
+					r.ServeHTTP(w, req)
+				}
+
+				if w.Code != tt.status {
+					t.Errorf("serveHTTP=%v: expected status %d, got %d", serveHTTP, tt.status, w.Code)
+				}
+				if w.Body.String() != tt.body {
+					t.Errorf("serveHTTP=%v: expected body %q, got %q", serveHTTP, tt.body, w.Body.String())
+				}
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -48,6 +49,105 @@ func BenchmarkCacheSet(b *testing.B) {
 	}
 }
 
+// BenchmarkCacheChurn 高淘汰率（小缓存、大量文件轮换）基准测试
+// 使用 -benchmem 对比池化条目与每次新建条目的分配次数
+func BenchmarkCacheChurn(b *testing.B) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("dir/file%d.txt", i)
+	}
+	data := make([]byte, 1024)
+
+	benchmarks := []struct {
+		name     string
+		newEntry func() *cacheEntry
+	}{
+		{"pooled", newPooledEntry},
+		{"unpooled", func() *cacheEntry { return &cacheEntry{} }},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			cache := NewCache(100*1024*1024, 16, nil)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := keys[i%len(keys)]
+				if entry, ok := cache.acquire(key); ok {
+					cache.release(entry)
+					continue
+				}
+
+				entry := bm.newEntry()
+				entry.Data = data
+				entry.Size = int64(len(data))
+				cache.Set(key, entry)
+			}
+		})
+	}
+}
+
+// BenchmarkCacheEviction 缓存已满时每次写入都触发一次淘汰的基准测试
+// 淘汰耗时应与缓存条目数无关
+func BenchmarkCacheEviction(b *testing.B) {
+	policies := []struct {
+		name      string
+		newPolicy func() EvictionPolicy
+	}{
+		{"LRU", NewLRUPolicy},
+		{"LFU", NewLFUPolicy},
+		{"FIFO", NewFIFOPolicy},
+	}
+
+	for _, size := range []int{1000, 10000, 100000} {
+		keys := make([]string, size*2)
+		for i := range keys {
+			keys[i] = fmt.Sprintf("dir/file%d.txt", i)
+		}
+		data := make([]byte, 16)
+
+		for _, p := range policies {
+			b.Run(fmt.Sprintf("%s/%d", p.name, size), func(b *testing.B) {
+				cache := NewCacheWithPolicy(1<<40, size, nil, p.newPolicy())
+				for _, key := range keys[:size] {
+					cache.Set(key, &cacheEntry{Data: data, Size: int64(len(data))})
+				}
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					cache.Set(keys[i%len(keys)], &cacheEntry{Data: data, Size: int64(len(data))})
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkConcurrentCacheSetGet 并发读写且持续淘汰的基准测试
+func BenchmarkConcurrentCacheSetGet(b *testing.B) {
+	keys := make([]string, 4096)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("dir/file%d.txt", i)
+	}
+	data := make([]byte, 1024)
+	cache := NewCache(100*1024*1024, 1024, nil)
+
+	var n uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := atomic.AddUint64(&n, 1)
+			key := keys[i%uint64(len(keys))]
+			if entry, ok := cache.acquire(key); ok {
+				cache.release(entry)
+				continue
+			}
+			cache.Set(key, &cacheEntry{Data: data, Size: int64(len(data))})
+		}
+	})
+}
+
 // BenchmarkGzipCompress Gzip 压缩基准测试
 func BenchmarkGzipCompress(b *testing.B) {
 	data := make([]byte, 10*1024) // 10KB
@@ -244,3 +344,48 @@ func BenchmarkConcurrentCacheGet(b *testing.B) {
 		}
 	})
 }
+
+// BenchmarkServeLargeFileReadBuffer 不同读取缓冲区大小下服务大文件的基准测试
+// 禁用缓存使每次请求都从磁盘读取
+func BenchmarkServeLargeFileReadBuffer(b *testing.B) {
+	gin.SetMode(gin.TestMode)
+
+	tmpDir := b.TempDir()
+	data := make([]byte, 8*1024*1024) // 8MB
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	if err := os.WriteFile(tmpDir+"/video.bin", data, 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	benchmarks := []struct {
+		name string
+		size int
+	}{
+		{"4KB", 4 * 1024},
+		{"32KB", 32 * 1024},
+		{"256KB", 256 * 1024},
+		{"1MB", 1024 * 1024},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			r := gin.New()
+			New(r, tmpDir, DisableCache(), DisableGzip(), WithReadBufferSize(bm.size))
+
+			req, _ := http.NewRequest("GET", "/video.bin", nil)
+
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				if w.Code != http.StatusOK {
+					b.Fatalf("unexpected status %d", w.Code)
+				}
+			}
+		})
+	}
+}
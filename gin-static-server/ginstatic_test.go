@@ -1,11 +1,19 @@
 package ginstatic
 
 import (
+	"bytes"
 	"compress/gzip"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
 	"time"
+
+	"github.com/aiqoder/my-go-tools/gin-static-server/internal/compress"
+	"github.com/klauspost/compress/zstd"
 )
 
 func TestCache(t *testing.T) {
@@ -85,6 +93,207 @@ func TestCacheEviction(t *testing.T) {
 	}
 }
 
+// TestCacheReplaceNoEviction 替换同名条目时扣除旧条目占用，不淘汰其他条目
+func TestCacheReplaceNoEviction(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxSize  int64
+		maxFiles int
+	}{
+		{"文件数已满", 1024 * 1024, 2},
+		{"大小已满", 10, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewCache(tt.maxSize, tt.maxFiles, nil)
+			cache.Set("a.txt", &cacheEntry{Data: []byte("aaaaa"), Size: 5})
+			cache.Set("b.txt", &cacheEntry{Data: []byte("bbbbb"), Size: 5})
+
+			cache.Set("a.txt", &cacheEntry{Data: []byte("AAAAA"), Size: 5})
+
+			if n := cache.EvictCount(); n != 0 {
+				t.Errorf("expected no eviction, got %d", n)
+			}
+			if _, ok := cache.Get("b.txt"); !ok {
+				t.Error("expected b.txt to stay cached")
+			}
+			got, ok := cache.Get("a.txt")
+			if !ok {
+				t.Fatal("expected a.txt to be cached")
+			}
+			if string(got.Data) != "AAAAA" {
+				t.Errorf("expected replaced data 'AAAAA', got '%s'", string(got.Data))
+			}
+			if cache.FileCount() != 2 || cache.Size() != 10 {
+				t.Errorf("expected 2 files and size 10, got %d files and size %d", cache.FileCount(), cache.Size())
+			}
+		})
+	}
+}
+
+// TestCacheEvictionPolicy 相同访问序列下各淘汰策略的淘汰顺序
+func TestCacheEvictionPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy EvictionPolicy
+		want   []string
+	}{
+		// 写入顺序 a、b、c，访问序列 c、c、b、a，随后写入 x、y 触发两次淘汰
+		// LRU：最久未访问依次为 c、b；LFU：b 与 a 次数最少且 b 较早访问，之后新写入的 x 次数最少；FIFO：a、b
+		{"LRU 淘汰最久未访问", NewLRUPolicy(), []string{"c", "b"}},
+		{"LFU 淘汰访问次数最少", NewLFUPolicy(), []string{"b", "x"}},
+		{"FIFO 按写入顺序淘汰", NewFIFOPolicy(), []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var evicted []string
+			cache := NewCacheWithPolicy(1024*1024, 3, func(key string) {
+				evicted = append(evicted, key)
+			}, tt.policy)
+
+			for _, key := range []string{"a", "b", "c"} {
+				cache.Set(key, &cacheEntry{Data: []byte(key), Size: 1})
+			}
+			for _, key := range []string{"c", "c", "b", "a"} {
+				if _, ok := cache.acquire(key); !ok {
+					t.Fatalf("expected %s cached", key)
+				}
+			}
+			for _, key := range []string{"x", "y"} {
+				cache.Set(key, &cacheEntry{Data: []byte(key), Size: 1})
+			}
+
+			if fmt.Sprint(evicted) != fmt.Sprint(tt.want) {
+				t.Errorf("expected eviction order %v, got %v", tt.want, evicted)
+			}
+			if cache.FileCount() != 3 {
+				t.Errorf("expected file count 3, got %d", cache.FileCount())
+			}
+		})
+	}
+
+	t.Run("删除的键不再参与淘汰", func(t *testing.T) {
+		cache := NewCacheWithPolicy(1024*1024, 2, nil, NewFIFOPolicy())
+		cache.Set("a", &cacheEntry{Data: []byte("a"), Size: 1})
+		cache.Set("b", &cacheEntry{Data: []byte("b"), Size: 1})
+		cache.Delete("a")
+		cache.Set("c", &cacheEntry{Data: []byte("c"), Size: 1})
+		cache.Set("d", &cacheEntry{Data: []byte("d"), Size: 1})
+
+		if _, ok := cache.acquire("b"); ok {
+			t.Error("expected b evicted first")
+		}
+		for _, key := range []string{"c", "d"} {
+			if _, ok := cache.acquire(key); !ok {
+				t.Errorf("expected %s cached", key)
+			}
+		}
+	})
+}
+
+func TestCacheConcurrentSet(t *testing.T) {
+	const maxFiles = 16
+	const maxSize = 64
+	cache := NewCache(maxSize, maxFiles, nil)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("g%d/file%d", g, i)
+				cache.Set(key, &cacheEntry{Data: []byte(key), Size: 3})
+				if entry, ok := cache.acquire(fmt.Sprintf("g%d/file%d", g, i/2)); ok {
+					cache.release(entry)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if cache.FileCount() > maxFiles {
+		t.Errorf("expected at most %d files, got %d", maxFiles, cache.FileCount())
+	}
+	if cache.Size() > maxSize {
+		t.Errorf("expected size at most %d, got %d", maxSize, cache.Size())
+	}
+
+	var files int
+	var size int64
+	cache.entries.Range(func(_, value interface{}) bool {
+		files++
+		size += value.(*cacheEntry).Size
+		return true
+	})
+	if files != cache.FileCount() || size != cache.Size() {
+		t.Errorf("expected counters %d files / %d bytes, got %d / %d", files, size, cache.FileCount(), cache.Size())
+	}
+}
+
+func TestCacheDefaultLRU(t *testing.T) {
+	var evicted []string
+	cache := NewCache(1024*1024, 2, func(key string) {
+		evicted = append(evicted, key)
+	})
+	cache.Set("a", &cacheEntry{Data: []byte("a"), Size: 1})
+	cache.Set("b", &cacheEntry{Data: []byte("b"), Size: 1})
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("expected a cached")
+	}
+	cache.Set("c", &cacheEntry{Data: []byte("c"), Size: 1})
+
+	if fmt.Sprint(evicted) != "[b]" {
+		t.Errorf("expected b evicted, got %v", evicted)
+	}
+}
+
+// TestCachePooledEntryRace 并发读取与淘汰池化条目，读者持有期间条目不得被复用
+// 使用 go test -race 运行
+func TestCachePooledEntryRace(t *testing.T) {
+	cache := NewCache(1024*1024, 8, nil)
+
+	keys := make([]string, 64)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("file%d.txt", i)
+	}
+
+	var wg sync.WaitGroup
+	var mismatches int32
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				key := keys[(i*7+g)%len(keys)]
+				if entry, ok := cache.acquire(key); ok {
+					if entry.Path != key || string(entry.Data) != key {
+						atomic.AddInt32(&mismatches, 1)
+					}
+					cache.release(entry)
+					continue
+				}
+
+				entry := newPooledEntry()
+				entry.Data = []byte(key)
+				entry.Size = int64(len(key))
+				entry.Path = key
+				cache.Set(key, entry)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if mismatches != 0 {
+		t.Errorf("expected no reused entries while held, got %d mismatches", mismatches)
+	}
+	if cache.FileCount() > 8 {
+		t.Errorf("expected file count <= 8, got %d", cache.FileCount())
+	}
+}
+
 func TestCacheLoadFile(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -123,6 +332,74 @@ func TestCacheLoadFile(t *testing.T) {
 	}
 }
 
+func TestPrecompressFS(t *testing.T) {
+	large := bytes.Repeat([]byte("console.log('precompress');\n"), 100)
+	assets := fstest.MapFS{
+		"dist/js/app.js":    {Data: large},
+		"dist/css/app.css":  {Data: bytes.Repeat([]byte("body{margin:0}\n"), 100)},
+		"dist/robots.txt":   {Data: []byte("User-agent: *")},
+		"outside/ignore.js": {Data: large},
+	}
+
+	cache, err := PrecompressFS(assets, "dist", gzip.BestCompression)
+	if err != nil {
+		t.Fatalf("PrecompressFS failed: %v", err)
+	}
+	if cache.FileCount() != 3 {
+		t.Errorf("expected 3 cached files, got %d", cache.FileCount())
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		wantGzip bool
+	}{
+		{"大文件带 Gzip 版本", "/js/app.js", true},
+		{"嵌套目录", "/css/app.css", true},
+		{"小文件不压缩", "/robots.txt", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, ok := cache.Get(tt.key)
+			if !ok {
+				t.Fatalf("expected %s to be cached", tt.key)
+			}
+			want := assets["dist"+tt.key].Data
+			if !bytes.Equal(entry.Data, want) {
+				t.Errorf("data mismatch for %s", tt.key)
+			}
+			if entry.ETag == "" {
+				t.Error("expected ETag to be set")
+			}
+			if !tt.wantGzip {
+				if entry.Gzipped != nil {
+					t.Errorf("expected no gzip variant for %s", tt.key)
+				}
+				return
+			}
+			if entry.Gzipped == nil {
+				t.Fatalf("expected gzip variant for %s", tt.key)
+			}
+			decompressed, err := GzipDecompress(entry.Gzipped)
+			if err != nil {
+				t.Fatalf("failed to decompress: %v", err)
+			}
+			if !bytes.Equal(decompressed, want) {
+				t.Errorf("gzip round-trip mismatch for %s", tt.key)
+			}
+		})
+	}
+
+	if _, ok := cache.Get("/ignore.js"); ok {
+		t.Error("expected files outside root to be skipped")
+	}
+
+	if _, err := PrecompressFS(assets, "missing", gzip.BestSpeed); err == nil {
+		t.Error("expected error for missing root")
+	}
+}
+
 func TestGzipCompress(t *testing.T) {
 	data := []byte("Hello, World! This is a test message for gzip compression.")
 
@@ -173,6 +450,152 @@ func TestZstdCompress(t *testing.T) {
 	}
 }
 
+func TestBrotliCompress(t *testing.T) {
+	data := bytes.Repeat([]byte("Hello, World! This is a test message for brotli compression. "), 20)
+
+	for _, level := range []int{-1, 0, DefaultBrotliLevel, 11, 12} {
+		compressed, err := BrotliCompress(data, level)
+		if err != nil {
+			t.Fatalf("level %d: failed to compress: %v", level, err)
+		}
+		if len(compressed) >= len(data) {
+			t.Errorf("level %d: compressed data should be smaller than original, got %d >= %d", level, len(compressed), len(data))
+		}
+
+		decompressed, err := BrotliDecompress(compressed)
+		if err != nil {
+			t.Fatalf("level %d: failed to decompress: %v", level, err)
+		}
+		if !bytes.Equal(decompressed, data) {
+			t.Errorf("level %d: decompressed data mismatch", level)
+		}
+	}
+}
+
+func TestZstdCompressConcurrent(t *testing.T) {
+	const workers = 32
+	const iterations = 50
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				data := bytes.Repeat([]byte(fmt.Sprintf("worker-%d-iteration-%d;", w, i)), 64+i)
+				compressed, err := ZstdCompress(data)
+				if err != nil {
+					t.Errorf("worker %d: failed to compress: %v", w, err)
+					return
+				}
+				decompressed, err := ZstdDecompress(compressed)
+				if err != nil {
+					t.Errorf("worker %d: failed to decompress: %v", w, err)
+					return
+				}
+				if !bytes.Equal(decompressed, data) {
+					t.Errorf("worker %d: round-trip mismatch at iteration %d", w, i)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+// testJSONRecord 生成结构相似的小 JSON 文件内容
+func testJSONRecord(i int) []byte {
+	return []byte(fmt.Sprintf(`{"id":%d,"name":"user-%d","email":"user%d@example.com","role":"member","active":true}`, i, i, i))
+}
+
+// buildTestZstdDict 用相似的 JSON 样本训练 zstd 字典
+func buildTestZstdDict(t *testing.T) []byte {
+	t.Helper()
+
+	samples := make([][]byte, 0, 300)
+	for i := 0; i < 300; i++ {
+		samples = append(samples, testJSONRecord(i))
+	}
+	dict, err := zstd.BuildDict(zstd.BuildDictOptions{
+		ID:       1,
+		Contents: samples,
+		History:  bytes.Repeat(testJSONRecord(0), 20),
+		Offsets:  [3]int{1, 4, 8},
+	})
+	if err != nil {
+		t.Fatalf("failed to build dictionary: %v", err)
+	}
+	return dict
+}
+
+func TestZstdCompressDict(t *testing.T) {
+	dict := buildTestZstdDict(t)
+	data := testJSONRecord(1234)
+
+	compressed, err := ZstdCompressDict(data, dict)
+	if err != nil {
+		t.Fatalf("failed to compress: %v", err)
+	}
+
+	plain, err := ZstdCompress(data)
+	if err != nil {
+		t.Fatalf("failed to compress: %v", err)
+	}
+
+	// 字典压缩应明显优于无字典压缩
+	if len(compressed) >= len(plain) {
+		t.Errorf("dictionary output should be smaller, got %d >= %d", len(compressed), len(plain))
+	}
+
+	decompressed, err := ZstdDecompressDict(compressed, dict)
+	if err != nil {
+		t.Fatalf("failed to decompress: %v", err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Errorf("decompressed data mismatch")
+	}
+
+	// 没有字典无法解压
+	if _, err := ZstdDecompress(compressed); err == nil {
+		t.Error("expected error decompressing without dictionary")
+	}
+}
+
+// TestCompressReExports 测试转发到 internal/compress 的压缩函数与共享实现互通
+func TestCompressReExports(t *testing.T) {
+	data := bytes.Repeat([]byte("shared compress primitives "), 50)
+
+	gzData, err := GzipCompress(data, 6)
+	if err != nil {
+		t.Fatalf("GzipCompress failed: %v", err)
+	}
+	if decoded, err := compress.GzipDecompress(gzData); err != nil || !bytes.Equal(decoded, data) {
+		t.Errorf("expected GzipCompress output to decode with internal/compress, err=%v", err)
+	}
+	sharedGz, err := compress.GzipCompress(data, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err := GzipDecompress(sharedGz); err != nil || !bytes.Equal(decoded, data) {
+		t.Errorf("expected GzipDecompress to decode internal/compress output, err=%v", err)
+	}
+
+	zstdData, err := ZstdCompress(data)
+	if err != nil {
+		t.Fatalf("ZstdCompress failed: %v", err)
+	}
+	if decoded, err := compress.ZstdDecompress(zstdData); err != nil || !bytes.Equal(decoded, data) {
+		t.Errorf("expected ZstdCompress output to decode with internal/compress, err=%v", err)
+	}
+	sharedZstd, err := compress.ZstdCompress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err := ZstdDecompress(sharedZstd); err != nil || !bytes.Equal(decoded, data) {
+		t.Errorf("expected ZstdDecompress to decode internal/compress output, err=%v", err)
+	}
+}
+
 func TestGetCompressedData(t *testing.T) {
 	data := []byte("Hello, World!")
 	gzData := []byte{0x1f, 0x8b} // 简化的 gzip 数据
@@ -228,6 +651,63 @@ func TestContainsEncoding(t *testing.T) {
 	}
 }
 
+func TestNegotiateEncoding(t *testing.T) {
+	priority := []string{"br", "zstd", "gzip"}
+	tests := []struct {
+		accept string
+		want   []string
+	}{
+		{"", nil},
+		{"gzip", []string{"gzip"}},
+		{"gzip, zstd, br", []string{"br", "zstd", "gzip"}},
+		{"gzip;q=1, br;q=0.5", []string{"gzip", "br"}},
+		{"br;q=0, gzip", []string{"gzip"}},
+		{"zstd;q=0.8, gzip;q=0.8, br;q=0.9", []string{"br", "zstd", "gzip"}},
+		{"deflate", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			got := negotiateEncoding(tt.accept, priority)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("negotiateEncoding(%q) = %v, want %v", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchETag(t *testing.T) {
+	etag := `"abc123"`
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		etag        string
+		want        bool
+	}{
+		{"exact", `"abc123"`, etag, true},
+		{"multi value", `"a", "b", "abc123"`, etag, true},
+		{"multi value no space", `"a","abc123"`, etag, true},
+		{"multi value miss", `"a", "b"`, etag, false},
+		{"wildcard", "*", etag, true},
+		{"weak client tag", `W/"abc123"`, etag, true},
+		{"weak server tag", `"abc123"`, `W/"abc123"`, true},
+		{"encoding suffix", `"abc123-gzip"`, etag, true},
+		{"weak encoding suffix", `W/"abc123-zstd"`, etag, true},
+		{"empty header", "", etag, false},
+		{"empty etag", "*", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matchETag(tt.ifNoneMatch, tt.etag)
+			if got != tt.want {
+				t.Errorf("matchETag(%q, %q) = %v, want %v", tt.ifNoneMatch, tt.etag, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestIsPathTraversal(t *testing.T) {
 	root := "/var/www/static"
 
@@ -290,8 +770,16 @@ func TestIsHiddenPath(t *testing.T) {
 		{"css/style.css", false},
 		{"dir/.hidden/file.txt", true},
 		{"dir/file.txt", false},
-		{"./file.txt", true},
+		{"./file.txt", false},
 		{"..hidden/file.txt", true},
+		{".", false},
+		{"./a", false},
+		{"../a/b.txt", false},
+		{"a/../b.txt", false},
+		{"/static/.git/config", true},
+		{"a/b/.env", true},
+		{"a/b/c.txt", false},
+		{"", false},
 	}
 
 	for _, tt := range tests {
@@ -433,3 +921,43 @@ func TestGetGzipLevel(t *testing.T) {
 		})
 	}
 }
+
+func TestCountRanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"空值", "", 0},
+		{"单区间", "bytes=0-99", 1},
+		{"多区间", "bytes=0-9, 20-29,-5", 3},
+		{"忽略空区间", "bytes=0-9,,", 1},
+		{"非 bytes 单位", "items=0-9,10-19", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countRanges(tt.header); got != tt.want {
+				t.Errorf("countRanges(%q) = %d, want %d", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrivateCacheControl(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"public, max-age=60", "private, max-age=60"},
+		{"max-age=60, Public, immutable", "max-age=60, private, immutable"},
+		{"no-cache", "no-cache"},
+		{"public", "private"},
+	}
+
+	for _, tt := range tests {
+		if got := privateCacheControl(tt.in); got != tt.want {
+			t.Errorf("privateCacheControl(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -1,23 +1,79 @@
 package ginstatic
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"hash/fnv"
 	"io"
 	fs2 "io/fs"
+	"log"
+	"net"
 	"net/http"
 	"os"
+	pathpkg "path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
 )
 
+// ErrChecksumMismatch 文件内容与 Checksums 清单中的 sha256 不一致
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
 // StaticEngine 静态文件服务引擎
 type StaticEngine struct {
 	config *Config
 	cache  *Cache
+
+	// 缓存键前缀，Mount 挂载的子树以此与父引擎共享同一缓存而互不冲突
+	cacheNamespace string
+
+	// 已服务字节数统计
+	rawBytesServed  int64 // 原始（压缩前）字节数
+	wireBytesServed int64 // 实际传输（压缩后）字节数
+
+	// 请求与缓存命中统计，由 Collector 导出
+	requests    requestMetrics
+	cacheHits   uint64
+	cacheMisses uint64
+
+	// 压缩统计：编码名 -> *compressionCounter
+	compressionStats sync.Map
+
+	// 字典压缩编码器，首次使用时创建
+	zstdDictOnce    sync.Once
+	zstdDictEncoder *zstd.Encoder
+	zstdDictErr     error
+
+	// 磁盘读取缓冲区池，元素为 *[]byte
+	readBufPool sync.Pool
+
+	// 启动预加载完成信号，未启用 PreloadOnStart 时为 nil
+	preloadDone chan struct{}
+	preloadErr  error
+
+	// 正在后台刷新的过期条目：缓存键 -> struct{}
+	revalidating sync.Map
+
+	// 后台任务信号量，容量为 MaxBackgroundGoroutines，nil 表示不限制（顺序执行）
+	bgSemOnce sync.Once
+	bgSem     chan struct{}
+	bgActive  int32 // 当前占用的后台 goroutine 数
+	bgPeak    int32 // 后台 goroutine 数峰值
+
+	// 文件监听，未启用 EnableWatch 时为 nil
+	watcher *fileWatcher
 }
 
 // New 创建新的静态文件服务引擎
@@ -25,17 +81,25 @@ func New(router *gin.Engine, root string, opts ...Option) *StaticEngine {
 	cfg := applyConfig(root, opts)
 	engine := &StaticEngine{
 		config: cfg,
-		cache:  NewCache(cfg.MaxCacheSize, cfg.MaxCacheFiles, cfg.OnCacheEvict),
+		cache:  NewCacheWithPolicy(cfg.MaxCacheSize, cfg.MaxCacheFiles, cfg.OnCacheEvict, cfg.EvictionPolicy),
 	}
 
 	// 注册路由
 	engine.registerRoutes(router)
 
+	// 恢复持久化缓存（如启用），先于预加载执行，已恢复的文件不再重复读取
+	if cfg.EnableCache && cfg.CachePersistenceDir != "" {
+		engine.restoreCache()
+	}
+
 	// 预加载（如启用）
 	if cfg.PreloadOnStart {
-		go func() {
-			engine.cache.preloadDirectory(cfg.Root, cfg.EnableGzip, cfg.GzipLevel)
-		}()
+		engine.startPreload()
+	}
+
+	// 文件监听（如启用）
+	if cfg.EnableWatch && cfg.EnableCache && cfg.EmbedFS == nil {
+		engine.startWatch()
 	}
 
 	return engine
@@ -45,183 +109,576 @@ func New(router *gin.Engine, root string, opts ...Option) *StaticEngine {
 func NewWithConfig(router *gin.Engine, cfg *Config) *StaticEngine {
 	engine := &StaticEngine{
 		config: cfg,
-		cache:  NewCache(cfg.MaxCacheSize, cfg.MaxCacheFiles, cfg.OnCacheEvict),
+		cache:  NewCacheWithPolicy(cfg.MaxCacheSize, cfg.MaxCacheFiles, cfg.OnCacheEvict, cfg.EvictionPolicy),
 	}
 
 	// 注册路由
 	engine.registerRoutes(router)
 
+	// 恢复持久化缓存（如启用），先于预加载执行，已恢复的文件不再重复读取
+	if cfg.EnableCache && cfg.CachePersistenceDir != "" {
+		engine.restoreCache()
+	}
+
 	// 预加载（如启用）
 	if cfg.PreloadOnStart {
-		go func() {
-			engine.cache.preloadDirectory(cfg.Root, cfg.EnableGzip, cfg.GzipLevel)
-		}()
+		engine.startPreload()
+	}
+
+	// 文件监听（如启用）
+	if cfg.EnableWatch && cfg.EnableCache && cfg.EmbedFS == nil {
+		engine.startWatch()
 	}
 
 	return engine
 }
 
+// Mount 在同一 gin 引擎下以 prefix 挂载另一个文件系统目录
+// 挂载点继承当前引擎的配置，可通过 opts 单独覆盖；与当前引擎共享缓存（按挂载点隔离缓存键）
+// 注意：挂载点不执行 PreloadOnStart 预加载，也不启动 WithWatch 文件监听
+func (e *StaticEngine) Mount(router *gin.Engine, prefix, root string, opts ...Option) *StaticEngine {
+	cfg := *e.config
+	cfg.Root = root
+	cfg.EmbedFS = nil
+	cfg.EmbedRoot = ""
+	return e.mount(router, prefix, &cfg, opts)
+}
+
+// MountEmbed 在同一 gin 引擎下以 prefix 挂载一个 embed 文件系统
+// 语义与 Mount 相同，挂载点从 embedFS 读取文件
+func (e *StaticEngine) MountEmbed(router *gin.Engine, prefix string, embedFS any, opts ...Option) *StaticEngine {
+	cfg := *e.config
+	cfg.Root = ""
+	cfg.EmbedFS = embedFS
+	cfg.EmbedRoot = ""
+	return e.mount(router, prefix, &cfg, opts)
+}
+
+// mount 应用挂载点配置并注册路由
+func (e *StaticEngine) mount(router *gin.Engine, prefix string, cfg *Config, opts []Option) *StaticEngine {
+	cfg.Prefix = prefix
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.Root != "" {
+		cfg.Root = filepath.Clean(cfg.Root)
+	}
+
+	engine := &StaticEngine{
+		config:         cfg,
+		cache:          e.cache,
+		cacheNamespace: "mount:" + strings.TrimSuffix(prefix, "/") + ":",
+	}
+	engine.registerRoutes(router)
+	return engine
+}
+
+// cacheKey 返回路径在共享缓存中的键
+func (e *StaticEngine) cacheKey(path string) string {
+	return e.cacheNamespace + path
+}
+
 // registerRoutes 注册路由
 func (e *StaticEngine) registerRoutes(router *gin.Engine) {
 	prefix := strings.TrimSuffix(e.config.Prefix, "/")
 
-	// 如果启用了 SPA 回退，使用自定义处理
-	if e.config.EnableSPA && e.config.SPAFallback {
-		router.GET(prefix+"/*path", e.serveSPA())
-	} else {
-		router.GET(prefix+"/*path", e.serveStatic())
+	handler := e.withMetrics(e.withRequestTimeout(e.serveStatic()))
+
+	// 预缓存清单端点：位于前缀内时由静态处理器拦截，避免与通配路由冲突
+	if endpoint := e.config.PrecacheEndpoint; endpoint != "" {
+		if strings.HasPrefix(endpoint, prefix+"/") {
+			handler = e.withPrecacheEndpoint(endpoint, handler)
+		} else {
+			router.GET(endpoint, e.PrecacheHandler())
+		}
+	}
+
+	router.GET(prefix+"/*path", handler)
+	router.HEAD(prefix+"/*path", withHeadResponse(handler))
+
+	// 非 GET/HEAD/OPTIONS 方法显式返回 405
+	if e.config.EnableMethodNotAllowed {
+		for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+			router.Handle(method, prefix+"/*path", e.serveMethodNotAllowed())
+		}
 	}
 }
 
-// serveStatic 服务静态文件
-func (e *StaticEngine) serveStatic() gin.HandlerFunc {
+// serveMethodNotAllowed 对已存在的资源返回 405，否则返回 404
+func (e *StaticEngine) serveMethodNotAllowed() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		path := c.Param("path")
-		if path == "" || path == "/" {
-			path = "/" + e.config.IndexFile
+		path := strings.TrimPrefix(c.Param("path"), "/")
+		if path == "" {
+			path = e.config.IndexFile
 		}
 
-		// 移除前导斜杠
-		path = strings.TrimPrefix(path, "/")
-
-		// 安全检查
 		safe, cleanPath := IsPathTraversal(e.config.Root, path)
-		if !safe {
-			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
-			return
+		if safe && !(e.config.HideDotFiles && IsHiddenPath(cleanPath)) {
+			if _, _, _, err := e.getFile(cleanPath); err == nil {
+				c.Header("Allow", "GET, HEAD")
+				c.Status(http.StatusMethodNotAllowed)
+				return
+			}
 		}
 
-		// 检查隐藏文件
-		if e.config.HideDotFiles && IsHiddenPath(cleanPath) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		e.serveError(c, http.StatusNotFound)
+	}
+}
+
+// serveStatic 返回静态路由处理器
+func (e *StaticEngine) serveStatic() gin.HandlerFunc {
+	return e.serveFile
+}
+
+// serveFile 服务静态文件，gin 路由与 ServeHTTP 共用
+// 依次完成请求检查、文件查找（目录重定向、内置回退文件、SPA 回退）、内容协商与写入；
+// 启用 SPA 回退时目录请求与不存在的路径返回索引文件
+func (e *StaticEngine) serveFile(c *gin.Context) {
+	if isURITooLong(c.Request, e.config.MaxURILength) {
+		c.Status(http.StatusRequestURITooLong)
+		return
+	}
+
+	// 静态路由不处理协议升级（如 WebSocket）
+	if isUpgradeRequest(c.Request) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "upgrade not supported"})
+		return
+	}
+
+	spa := e.config.EnableSPA && e.config.SPAFallback
+	path := c.Param("path")
+	if !spa && (path == "" || strings.HasSuffix(path, "/")) {
+		// 根路径或以 / 结尾的目录请求，服务该目录下的 index 文件
+		path += e.config.IndexFile
+	}
+
+	// 移除前导斜杠
+	path = strings.TrimPrefix(path, "/")
+
+	// 安全检查
+	safe, cleanPath := IsPathTraversal(e.config.Root, path)
+	if !safe {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	// 检查隐藏文件
+	if e.config.HideDotFiles && IsHiddenPath(cleanPath) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	// 隐藏 source map（不回退到 index.html）
+	if e.isHiddenSourceMap(cleanPath) {
+		e.serveError(c, http.StatusNotFound)
+		return
+	}
+
+	// 内容寻址路径还原为真实路径，哈希与清单不一致时返回 404（不回退到 index.html）
+	cleanPath, hashed, ok := e.resolveContentAddressed(cleanPath)
+	if !ok {
+		e.serveError(c, http.StatusNotFound)
+		return
+	}
+
+	// 请求前回调
+	if e.config.OnRequest != nil && !e.config.OnRequest(cleanPath) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	// 语言与图片格式协商
+	cleanPath, lang := e.negotiateLanguage(c.GetHeader("Accept-Language"), cleanPath)
+	cleanPath, varyAccept := e.negotiateImage(c.GetHeader("Accept"), cleanPath)
+
+	// 大文件流式返回
+	if e.serveStream(c, cleanPath, hashed, lang, varyAccept) {
+		return
+	}
+
+	// 获取文件
+	timing := e.newServerTiming()
+	data, modTime, etag, err := e.getFileContext(c.Request.Context(), cleanPath, timing)
+	if err != nil && e.requestTimedOut(c) {
+		return
+	}
+	if err != nil && !isNotFound(err) {
+		e.serveReadError(c, cleanPath, err)
+		return
+	}
+
+	// 目录重定向与内置回退文件（如 robots.txt）优先于 SPA 回退
+	if err != nil && e.redirectDirectory(c.Writer, c.Request, cleanPath) {
+		return
+	}
+	if err != nil && e.serveDefaultFile(c, cleanPath) {
+		return
+	}
+
+	// SPA 模式下文件不存在或请求的是目录时返回 index.html
+	if spa && (err != nil || cleanPath == "") {
+		cleanPath, lang = e.negotiateLanguage(c.GetHeader("Accept-Language"), e.config.IndexFile)
+		data, modTime, etag, err = e.getFileContext(c.Request.Context(), cleanPath, timing)
+		if err != nil && e.requestTimedOut(c) {
 			return
 		}
-
-		// 请求前回调
-		if e.config.OnRequest != nil && !e.config.OnRequest(cleanPath) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		if err != nil && !isNotFound(err) {
+			e.serveReadError(c, cleanPath, err)
 			return
 		}
+	}
+	if err != nil {
+		e.serveError(c, http.StatusNotFound)
+		return
+	}
 
-		// 获取文件
-		data, modTime, etag, err := e.getFile(cleanPath)
-		if err != nil {
-			// 尝试 index.html（SPA 模式）
-			if e.config.EnableSPA && e.config.SPAFallback {
-				data, modTime, etag, err = e.getFile(e.config.IndexFile)
-				if err != nil {
-					e.serveError(c, http.StatusNotFound)
-					return
-				}
-			} else {
-				e.serveError(c, http.StatusNotFound)
-				return
-			}
-		}
+	// 按请求改写内容（如注入 CSP nonce）
+	if e.serveTransformed(c, c.Writer, cleanPath, data) {
+		return
+	}
 
-		// 条件请求检查
-		if e.checkNotModified(c, modTime, etag) {
-			return
-		}
+	// 条件请求检查
+	if e.checkNotModified(c, modTime, etag) {
+		return
+	}
+	if e.tooManyRanges(c.GetHeader("Range")) {
+		c.Header("Content-Range", fmt.Sprintf("bytes */%d", len(data)))
+		c.AbortWithStatus(http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
 
-		// 设置响应头
-		mimeType := GetMimeType(cleanPath, e.config.MimeTypes)
-		c.Header("Content-Type", mimeType)
+	// 设置响应头
+	mimeType := e.contentType(cleanPath, data)
+	c.Header("Content-Type", mimeType)
+	if !e.config.MinimalHeaders {
 		c.Header("Last-Modified", modTime.UTC().Format(http.TimeFormat))
 
 		if e.config.UseETag {
 			c.Header("ETag", etag)
 		}
 
-		if e.config.CacheControl != "" {
-			c.Header("Cache-Control", e.config.CacheControl)
+		if cacheControl := e.requestCacheControl(c, cleanPath, hashed); cacheControl != "" {
+			c.Header("Cache-Control", cacheControl)
+		}
+		e.setCrossOriginIsolation(c.Writer.Header())
+		e.setLanguageHeaders(c.Writer.Header(), cleanPath, lang)
+		if varyAccept {
+			c.Writer.Header().Add("Vary", "Accept")
 		}
+	}
 
-		// Gzip 压缩
-		data, encoding := e.getCompressedData(c, data, cleanPath)
-		if encoding != "" {
-			c.Header("Content-Encoding", encoding)
-			c.Header("Vary", "Accept-Encoding")
+	// Gzip 压缩
+	rawSize := len(data)
+	start := timing.start()
+	data, encoding := e.getCompressedData(c.GetHeader("Accept-Encoding"), data, cleanPath)
+	timing.stop(timingCompress, start)
+	if encoding != "" {
+		c.Header("Content-Encoding", encoding)
+		if !e.config.MinimalHeaders {
+			c.Writer.Header().Add("Vary", "Accept-Encoding")
+			if e.config.UseETag && e.config.EncodingETagSuffix {
+				c.Header("ETag", encodingETag(etag, encoding))
+			}
 		}
+	}
 
-		c.Header("Content-Length", fmt.Sprintf("%d", len(data)))
-		c.Data(http.StatusOK, mimeType, data)
+	// 处理 Range 请求
+	data, rawSize, status := e.applyRange(c.Request, c.Writer.Header(), data, rawSize, encoding)
+	if status == http.StatusRequestedRangeNotSatisfiable {
+		c.AbortWithStatus(status)
+		return
 	}
+	c.Status(status)
+
+	// 压缩后检查是否已超时
+	if e.requestTimedOut(c) {
+		return
+	}
+
+	// 写入前回调
+	if !e.beforeServe(c, &FileStat{
+		Path:        cleanPath,
+		Size:        int64(rawSize),
+		ModTime:     modTime,
+		ETag:        etag,
+		ContentType: mimeType,
+		Encoding:    encoding,
+		Source:      e.sourceName(),
+	}) {
+		return
+	}
+
+	if timing != nil {
+		c.Header("Server-Timing", timing.String())
+	}
+	if e.config.DebugHeaders {
+		c.Header("X-Served-From", e.sourceName())
+	}
+	c.Header("Content-Length", fmt.Sprintf("%d", len(data)))
+	e.writeData(c, cleanPath, mimeType, data, rawSize, encoding, e.sourceName())
 }
 
-// serveSPA 服务 SPA 应用
-func (e *StaticEngine) serveSPA() gin.HandlerFunc {
+// requestTimeoutKey 标记请求上下文由 withRequestTimeout 设置了截止时间
+type requestTimeoutKey struct{}
+
+// withRequestTimeout 为处理器设置整体截止时间（读取、压缩、写入）
+// 超时后进行中的加载被放弃，并响应 503
+func (e *StaticEngine) withRequestTimeout(h gin.HandlerFunc) gin.HandlerFunc {
+	if e.config.RequestTimeout <= 0 {
+		return h
+	}
 	return func(c *gin.Context) {
-		path := c.Param("path")
+		ctx, cancel := context.WithTimeout(c.Request.Context(), e.config.RequestTimeout)
+		defer cancel()
+		ctx = context.WithValue(ctx, requestTimeoutKey{}, true)
+		c.Request = c.Request.WithContext(ctx)
+
+		// 写入同样受截止时间约束，结束后清除以免影响同一连接上的后续请求
+		deadline, _ := ctx.Deadline()
+		rc := http.NewResponseController(c.Writer)
+		if rc.SetWriteDeadline(deadline) == nil {
+			defer rc.SetWriteDeadline(time.Time{})
+		}
+
+		h(c)
+	}
+}
 
-		// 移除前导斜杠，但保留空路径
-		path = strings.TrimPrefix(path, "/")
+// requestTimedOut 请求上下文已结束时返回 true：超过截止时间响应 503，客户端已断开时直接中止
+func (e *StaticEngine) requestTimedOut(c *gin.Context) bool {
+	err := c.Request.Context().Err()
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		c.Abort()
+		return true
+	}
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "request timeout"})
+	return true
+}
 
-		// 安全检查
-		safe, cleanPath := IsPathTraversal(e.config.Root, path)
-		if !safe {
-			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
-			return
-		}
+// timingPhase Server-Timing 记录的服务阶段
+type timingPhase int
 
-		// 请求前回调
-		if e.config.OnRequest != nil && !e.config.OnRequest(cleanPath) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
-			return
+const (
+	timingCache    timingPhase = iota // 缓存查找
+	timingRead                        // 读取源文件
+	timingCompress                    // 压缩
+)
+
+// serverTiming 单个请求各阶段的耗时，nil 表示未启用，所有方法均可在 nil 上调用
+type serverTiming struct {
+	dur [3]time.Duration
+}
+
+// newServerTiming 启用 ServerTiming 时为请求创建计时器
+func (e *StaticEngine) newServerTiming() *serverTiming {
+	if !e.config.ServerTiming {
+		return nil
+	}
+	return &serverTiming{}
+}
+
+// start 开始计时，未启用时不读取时钟
+func (st *serverTiming) start() time.Time {
+	if st == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// stop 将自 start 以来的耗时累加到 phase
+func (st *serverTiming) stop(phase timingPhase, start time.Time) {
+	if st == nil {
+		return
+	}
+	st.dur[phase] += time.Since(start)
+}
+
+// merge 累加另一计时器的耗时
+func (st *serverTiming) merge(other *serverTiming) {
+	if st == nil || other == nil {
+		return
+	}
+	for i := range st.dur {
+		st.dur[i] += other.dur[i]
+	}
+}
+
+// String 格式化为 Server-Timing 头，耗时单位为毫秒
+func (st *serverTiming) String() string {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return fmt.Sprintf("cache;dur=%.3f, read;dur=%.3f, compress;dur=%.3f",
+		ms(st.dur[timingCache]), ms(st.dur[timingRead]), ms(st.dur[timingCompress]))
+}
+
+// getFileContext 获取文件内容
+// 仅在 withRequestTimeout 设置了截止时间时于独立协程中加载，ctx 结束时放弃等待；否则直接同步加载
+func (e *StaticEngine) getFileContext(ctx context.Context, path string, st *serverTiming) ([]byte, time.Time, string, error) {
+	if ctx.Value(requestTimeoutKey{}) == nil {
+		return e.getFileTimed(path, st)
+	}
+
+	type result struct {
+		data    []byte
+		modTime time.Time
+		etag    string
+		err     error
+		timing  *serverTiming
+	}
+	done := make(chan result, 1)
+	go func() {
+		// 加载协程使用独立的计时器，放弃等待后不会与请求协程并发访问 st
+		var timing *serverTiming
+		if st != nil {
+			timing = &serverTiming{}
 		}
+		data, modTime, etag, err := e.getFileTimed(path, timing)
+		done <- result{data, modTime, etag, err, timing}
+	}()
+
+	select {
+	case r := <-done:
+		st.merge(r.timing)
+		return r.data, r.modTime, r.etag, r.err
+	case <-ctx.Done():
+		return nil, time.Time{}, "", ctx.Err()
+	}
+}
 
-		// 尝试获取文件
-		data, modTime, etag, err := e.getFile(cleanPath)
+// getFile 获取文件内容
+func (e *StaticEngine) getFile(path string) ([]byte, time.Time, string, error) {
+	return e.getFileTimed(path, nil)
+}
 
-		// 如果文件不存在或请求的是目录，返回 index.html
-		if err != nil || cleanPath == "" {
-			cleanPath = e.config.IndexFile
-			data, modTime, etag, err = e.getFile(cleanPath)
-			if err != nil {
-				e.serveError(c, http.StatusNotFound)
-				return
+// getFileTimed 获取文件内容，st 不为 nil 时记录缓存查找、读取和入缓存压缩的耗时
+func (e *StaticEngine) getFileTimed(path string, st *serverTiming) ([]byte, time.Time, string, error) {
+	// 尝试从缓存获取
+	if e.config.EnableCache {
+		start := st.start()
+		entry, ok := e.cache.acquire(e.cacheKey(path))
+		st.stop(timingCache, start)
+		if ok {
+			data, modTime, etag := entry.Data, entry.ModTime, entry.ETag
+			now := time.Now()
+			stale, servable := entry.staleState(now)
+			fresh := !stale && e.entryFresh(path, entry, now)
+			if fresh || servable {
+				atomic.AddUint64(&entry.hits, 1)
+				atomic.AddUint64(&e.cacheHits, 1)
+			}
+			e.cache.release(entry)
+			if fresh {
+				return data, modTime, etag, nil
+			}
+			// 过期窗口内返回旧内容并在后台刷新，超出窗口时同步加载
+			if servable {
+				e.revalidate(path)
+				return data, modTime, etag, nil
 			}
 		}
+		atomic.AddUint64(&e.cacheMisses, 1)
+	}
 
-		// 条件请求检查
-		if e.checkNotModified(c, modTime, etag) {
-			return
-		}
+	start := st.start()
+	data, modTime, etag, err := e.readSource(path)
+	st.stop(timingRead, start)
 
-		// 设置响应头
-		mimeType := GetMimeType(cleanPath, e.config.MimeTypes)
-		c.Header("Content-Type", mimeType)
-		c.Header("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	if err != nil {
+		return nil, time.Time{}, "", err
+	}
 
-		if e.config.UseETag {
-			c.Header("ETag", etag)
-		}
+	// 缓存（如启用）
+	if e.config.EnableCache {
+		start = st.start()
+		entry := e.newCacheEntry(path, data, modTime, etag)
+		st.stop(timingCompress, start)
+		e.cache.Set(e.cacheKey(path), entry)
+	}
 
-		if e.config.CacheControl != "" {
-			c.Header("Cache-Control", e.config.CacheControl)
+	return data, modTime, etag, nil
+}
+
+// newCacheEntry 创建缓存条目并计算已启用的压缩版本
+func (e *StaticEngine) newCacheEntry(path string, data []byte, modTime time.Time, etag string) *cacheEntry {
+	entry := newPooledEntry()
+	entry.Data = data
+	entry.ModTime = modTime
+	entry.Size = int64(len(data))
+	entry.ETag = etag
+
+	// 优先使用预压缩文件，避免重复压缩和存储
+	if e.config.EnablePrecompressed {
+		if gzData, err := e.getPrecompressedFile(path, "gzip"); err == nil {
+			entry.Gzipped = gzData
 		}
+	}
 
-		// Gzip 压缩
-		data, encoding := e.getCompressedData(c, data, cleanPath)
-		if encoding != "" {
-			c.Header("Content-Encoding", encoding)
-			c.Header("Vary", "Accept-Encoding")
+	// 压缩（如启用）
+	if entry.Gzipped == nil && e.config.EnableGzip && len(data) >= e.config.CompressMinSize {
+		gzData, err := GzipCompress(data, e.config.GzipLevel)
+		if err != nil {
+			e.reportError(path, ErrorOpCompress, err)
+		} else {
+			entry.Gzipped = gzData
 		}
+	}
 
-		c.Header("Content-Length", fmt.Sprintf("%d", len(data)))
-		c.Data(http.StatusOK, mimeType, data)
+	if e.config.EnableBrotli || e.config.EnablePrecompressed {
+		entry.Brotli = e.compressBrotli(path, data)
 	}
+
+	return entry
 }
 
-// getFile 获取文件内容
-func (e *StaticEngine) getFile(path string) ([]byte, time.Time, string, error) {
-	// 尝试从缓存获取
-	if e.config.EnableCache {
-		if entry, ok := e.cache.Get(path); ok {
-			return entry.Data, entry.ModTime, entry.ETag, nil
+// compressBrotli 返回 data 的 Brotli 版本，优先使用 .br 预压缩文件
+// 没有预压缩文件时仅在启用 Brotli 后实时压缩；小文件或压缩后不更小时返回 nil
+func (e *StaticEngine) compressBrotli(path string, data []byte) []byte {
+	if e.config.EnablePrecompressed {
+		if brData, err := e.getPrecompressedFile(path, "br"); err == nil {
+			return brData
 		}
 	}
+	if !e.config.EnableBrotli || len(data) < e.config.CompressMinSize {
+		return nil
+	}
+	brData, err := BrotliCompress(data, e.config.BrotliLevel)
+	if err != nil {
+		e.reportError(path, ErrorOpCompress, err)
+		return nil
+	}
+	if len(brData) >= len(data) {
+		return nil
+	}
+	return brData
+}
 
+// RecompressEntry 重新读取单个已缓存文件并重新计算其压缩版本
+// 新条目整体替换旧条目，并发请求只会看到旧版本或新版本，不会经历淘汰
+// relPath: 请求路径，如 "/js/app.js"
+func (e *StaticEngine) RecompressEntry(relPath string) error {
+	safe, cleanPath := IsPathTraversal(e.config.Root, strings.TrimPrefix(relPath, "/"))
+	if !safe {
+		return fmt.Errorf("invalid path: %s", relPath)
+	}
+	entry, ok := e.cache.acquire(e.cacheKey(cleanPath))
+	if !ok {
+		return fmt.Errorf("entry not cached: %s", cleanPath)
+	}
+	e.cache.release(entry)
+
+	data, modTime, etag, err := e.readSource(cleanPath)
+	if err != nil {
+		return err
+	}
+
+	e.cache.Set(e.cacheKey(cleanPath), e.newCacheEntry(cleanPath, data, modTime, etag))
+	return nil
+}
+
+// readSource 从 embed.FS 或文件系统读取文件，并按 Checksums 校验内容
+func (e *StaticEngine) readSource(path string) ([]byte, time.Time, string, error) {
 	var data []byte
 	var modTime time.Time
 	var etag string
@@ -229,98 +686,200 @@ func (e *StaticEngine) getFile(path string) ([]byte, time.Time, string, error) {
 
 	// 判断使用文件系统还是 embed
 	if e.config.EmbedFS != nil {
-		// 使用 embed.FS
 		data, modTime, etag, err = e.getEmbedFile(path)
 	} else {
-		// 使用文件系统
 		data, modTime, etag, err = e.getOSFile(path)
 	}
-
 	if err != nil {
 		return nil, time.Time{}, "", err
 	}
 
-	// 缓存（如启用）
-	if e.config.EnableCache {
-		entry := &cacheEntry{
-			Data:    data,
-			ModTime: modTime,
-			Size:    int64(len(data)),
-			ETag:    etag,
-		}
-
-		// 压缩（如启用）
-		if e.config.EnableGzip && len(data) >= e.config.CompressMinSize {
-			gzData, err := GzipCompress(data, e.config.GzipLevel)
-			if err == nil {
-				entry.Gzipped = gzData
-			}
-		}
+	if err := e.verifyChecksum(path, data); err != nil {
+		log.Printf("ginstatic: %v", err)
+		return nil, time.Time{}, "", err
+	}
+	return e.stripBOM(path, data), modTime, e.resolveETag(path, etag), nil
+}
 
-		e.cache.Set(path, entry)
+// utf8BOM UTF-8 字节顺序标记
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// stripBOM 启用 StripBOM 时去除文本类型文件开头的 UTF-8 BOM
+func (e *StaticEngine) stripBOM(path string, data []byte) []byte {
+	if !e.config.StripBOM || !bytes.HasPrefix(data, utf8BOM) || !isTextMimeType(e.contentType(path, data)) {
+		return data
 	}
+	return data[len(utf8BOM):]
+}
 
-	return data, modTime, etag, nil
+// isTextMimeType 检查 MIME 类型是否为文本内容
+func isTextMimeType(mimeType string) bool {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	mimeType = strings.TrimSpace(strings.ToLower(mimeType))
+	if strings.HasPrefix(mimeType, "text/") {
+		return true
+	}
+	switch mimeType {
+	case "application/javascript", "application/json", "application/xml", "image/svg+xml":
+		return true
+	}
+	return strings.HasSuffix(mimeType, "+json") || strings.HasSuffix(mimeType, "+xml")
+}
+
+// resolveETag 配置了 ETagProvider 且其返回 ETag 时使用提供的值，否则使用内置计算的 computed
+// 未加引号的值会补上双引号，W/ 前缀的弱 ETag 保持不变
+func (e *StaticEngine) resolveETag(path, computed string) string {
+	if e.config.ETagProvider == nil {
+		return computed
+	}
+	etag, ok := e.config.ETagProvider("/" + strings.TrimPrefix(path, "/"))
+	if !ok || etag == "" {
+		return computed
+	}
+	if !strings.HasSuffix(etag, `"`) {
+		etag = `"` + etag + `"`
+	}
+	return etag
+}
+
+// verifyChecksum 按 Checksums（其次 ContentAddressed）清单校验文件内容的 sha256，清单中没有该路径时视为通过
+func (e *StaticEngine) verifyChecksum(path string, data []byte) error {
+	if len(e.config.Checksums) == 0 && len(e.config.ContentAddressed) == 0 {
+		return nil
+	}
+	key := "/" + strings.TrimPrefix(path, "/")
+	want, ok := e.config.Checksums[key]
+	if !ok {
+		// 内容寻址清单同样是 sha256，一并用于校验内容
+		if want, ok = e.config.ContentAddressed[key]; !ok {
+			return nil
+		}
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, want) {
+		return fmt.Errorf("%w: %s: want %s, got %s", ErrChecksumMismatch, path, want, got)
+	}
+	return nil
 }
 
 // getOSFile 从文件系统读取文件
 func (e *StaticEngine) getOSFile(path string) ([]byte, time.Time, string, error) {
 	absPath := filepath.Join(e.config.Root, path)
-	data, err := os.ReadFile(absPath)
+	data, info, err := e.readOSFile(absPath)
 	if err != nil {
 		return nil, time.Time{}, "", err
 	}
 
-	info, err := os.Stat(absPath)
+	etag := generateETag(absPath, info)
+	return data, info.ModTime(), etag, nil
+}
+
+// readOSFile 使用 ReadBufferSize 大小的缓冲区分块读取磁盘文件
+func (e *StaticEngine) readOSFile(absPath string) ([]byte, os.FileInfo, error) {
+	f, err := os.Open(absPath)
 	if err != nil {
-		return nil, time.Time{}, "", err
+		return nil, nil, err
 	}
+	defer f.Close()
 
-	etag := generateETag(absPath, info)
-	return data, info.ModTime(), etag, nil
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		// 目录视为不存在，与 embed 后端一致
+		return nil, nil, &fs2.PathError{Op: "read", Path: absPath, Err: fs2.ErrNotExist}
+	}
+
+	buf := e.getReadBuffer()
+	defer e.readBufPool.Put(buf)
+
+	var out bytes.Buffer
+	out.Grow(int(info.Size()))
+	// 包装后 io.CopyBuffer 不会走 ReaderFrom/WriterTo 捷径，每次读取使用指定大小的缓冲区
+	if _, err := io.CopyBuffer(struct{ io.Writer }{&out}, struct{ io.Reader }{f}, *buf); err != nil {
+		return nil, nil, err
+	}
+	return out.Bytes(), info, nil
+}
+
+// getReadBuffer 从池中取出读取缓冲区，大小为 ReadBufferSize（未设置时为 DefaultReadBufferSize）
+func (e *StaticEngine) getReadBuffer() *[]byte {
+	if buf, ok := e.readBufPool.Get().(*[]byte); ok {
+		return buf
+	}
+	size := e.config.ReadBufferSize
+	if size <= 0 {
+		size = DefaultReadBufferSize
+	}
+	buf := make([]byte, size)
+	return &buf
 }
 
 // getEmbedFile 从 embed.FS 读取文件
 func (e *StaticEngine) getEmbedFile(path string) ([]byte, time.Time, string, error) {
-	// 移除前导斜杠
-	path = strings.TrimPrefix(path, "/")
+	data, info, name, err := readEmbedFile(e.config.EmbedFS, e.config.EmbedRoot, path)
+	if err != nil {
+		return nil, time.Time{}, "", err
+	}
 
-	// 加上 EmbedRoot 前缀
-	if e.config.EmbedRoot != "" {
-		path = e.config.EmbedRoot + "/" + path
+	etag := generateETagEmbed(name, info)
+	return data, info.ModTime(), etag, nil
+}
+
+// readEmbedFile 从 embed 文件系统读取 root 子目录下的文件
+// 优先按 io/fs.FS 处理并通过 fs.Sub 进入子目录，其次是 http.FileSystem 和仅实现 Open 的类型。
+// 返回的 name 为包含 root 的完整路径，用于生成 ETag；目录视为不存在
+func readEmbedFile(embedFS any, root, path string) ([]byte, os.FileInfo, string, error) {
+	// 移除前导斜杠
+	path = strings.TrimPrefix(pathpkg.Clean("/"+path), "/")
+	if path == "" {
+		path = "."
+	}
+	root = strings.Trim(root, "/")
+	if root != "" {
+		root = pathpkg.Clean(root)
+	}
+	name := path
+	if root != "" && root != "." {
+		name = pathpkg.Join(root, path)
 	}
 
 	var data []byte
 	var info os.FileInfo
 	var err error
 
-	// 尝试直接使用 embed.FS (io/fs.FS)
-	if embedFS, ok := e.config.EmbedFS.(fs2.FS); ok {
-		data, info, err = readFromFS(embedFS, path)
-	} else if httpFS, ok := e.config.EmbedFS.(http.FileSystem); ok {
-		data, info, err = readFromHTTPFS(httpFS, path)
+	if fsys, ok := embedFS.(fs2.FS); ok {
+		if root != "" && root != "." {
+			fsys, err = fs2.Sub(fsys, root)
+			if err != nil {
+				return nil, nil, "", err
+			}
+		}
+		data, info, err = readFromFS(fsys, path)
+	} else if httpFS, ok := embedFS.(http.FileSystem); ok {
+		data, info, err = readFromHTTPFS(httpFS, "/"+name)
 	} else {
-		// 尝试使用 embed.FS 的 Open 方法
+		// 尝试使用 Open 方法
 		type opener interface {
 			Open(name string) (fs2.File, error)
 		}
-		f, ok := e.config.EmbedFS.(opener)
+		f, ok := embedFS.(opener)
 		if !ok {
-			return nil, time.Time{}, "", fmt.Errorf("unsupported file system type")
+			return nil, nil, "", fmt.Errorf("unsupported file system type")
 		}
-		data, info, err = readFromFSOpener(f, path)
+		data, info, err = readFromFSOpener(f, name)
 	}
 
 	if err != nil {
-		return nil, time.Time{}, "", err
+		return nil, nil, "", err
 	}
 
 	if info.IsDir() {
-		return nil, time.Time{}, "", os.ErrNotExist
+		return nil, nil, "", os.ErrNotExist
 	}
 
-	etag := generateETagEmbed(path, info)
-	return data, info.ModTime(), etag, nil
+	return data, info, name, nil
 }
 
 // readFromFS 从 io/fs.FS 读取文件
@@ -329,112 +888,351 @@ func readFromFS(fsys fs2.FS, path string) ([]byte, os.FileInfo, error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	defer file.Close()
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, info, nil
+}
+
+// readFromHTTPFS 从 http.FileSystem 读取文件
+func readFromHTTPFS(fsys http.FileSystem, path string) ([]byte, os.FileInfo, error) {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, info, nil
+}
+
+// readFromFSOpener 使用 fs.FileOpener 接口读取文件
+func readFromFSOpener(opener interface {
+	Open(name string) (fs2.File, error)
+}, path string) ([]byte, os.FileInfo, error) {
+	file, err := opener.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, info, nil
+}
+
+// generateETagEmbed 为 embed 文件生成 ETag
+func generateETagEmbed(path string, info os.FileInfo) string {
+	h := fnv.New64a()
+	h.Write([]byte(fmt.Sprintf("%d-%d-%s", info.Size(), info.ModTime().UnixNano(), path)))
+	return fmt.Sprintf(`"%x"`, h.Sum(nil))
+}
+
+// getCompressedData 获取压缩后的数据
+// 依次尝试缓存中的压缩版本、预压缩文件，最后实时压缩
+func (e *StaticEngine) getCompressedData(acceptEncoding string, data []byte, path string) ([]byte, string) {
+	if !e.config.EnableGzip && !e.config.EnableBrotli && !e.config.EnablePrecompressed && e.config.ZstdDictionary == nil {
+		return data, ""
+	}
+
+	// WASM 流式编译要求原样返回
+	if e.config.WasmStreaming && isWasmPath(path) {
+		return data, ""
+	}
+
+	// 按协商顺序依次尝试，第一个可用的编码生效
+	for _, encoding := range negotiateEncoding(acceptEncoding, e.encodingPriority()) {
+		if compressed, ok := e.encodeData(encoding, data, path); ok {
+			return compressed, encoding
+		}
+	}
+
+	return data, ""
+}
+
+// encodingPriority 返回内容编码的服务端优先级
+// 未配置 EncodingPriority 时使用 DefaultEncodingPriority，并追加自定义预压缩对应关系中的其他编码
+func (e *StaticEngine) encodingPriority() []string {
+	if len(e.config.EncodingPriority) > 0 {
+		return e.config.EncodingPriority
+	}
+	if !e.config.EnablePrecompressed || len(e.config.PrecompressedEncodings) == 0 {
+		return DefaultEncodingPriority
+	}
+
+	priority := append([]string(nil), DefaultEncodingPriority...)
+	for _, pe := range e.config.PrecompressedEncodings {
+		if !slices.Contains(priority, pe.Encoding) {
+			priority = append(priority, pe.Encoding)
+		}
+	}
+	return priority
+}
+
+// encodeData 返回 data 的 encoding 编码版本，该编码当前不可用时返回 false
+func (e *StaticEngine) encodeData(encoding string, data []byte, path string) ([]byte, bool) {
+	switch encoding {
+	case ZstdDictEncoding:
+		// 字典压缩（仅客户端显式声明支持时）
+		if e.config.ZstdDictionary == nil || len(data) < e.config.CompressMinSize {
+			return nil, false
+		}
+		compressed, err := e.zstdDictCompress(data)
+		if err != nil {
+			e.reportError(path, ErrorOpCompress, err)
+			return nil, false
+		}
+		return compressed, len(compressed) < len(data)
+	case "gzip":
+		return e.gzipData(data, path)
+	case "br":
+		return e.brotliData(data, path)
+	default:
+		// 非 gzip 的预压缩文件（.br / .zst）直接返回，编码以对应关系为准
+		if !e.config.EnablePrecompressed {
+			return nil, false
+		}
+		compressed, err := e.getPrecompressedFile(path, encoding)
+		return compressed, err == nil
+	}
+}
+
+// gzipData 返回 data 的 gzip 版本
+// 依次尝试缓存中的压缩版本（可能来自预压缩文件）、预压缩文件，最后实时压缩
+func (e *StaticEngine) gzipData(data []byte, path string) ([]byte, bool) {
+	// 检查缓存（Gzipped 可能来自预压缩文件）
+	if e.config.EnableCache {
+		if entry, ok := e.cache.acquire(e.cacheKey(path)); ok {
+			gzData := entry.Gzipped
+			e.cache.release(entry)
+			if gzData != nil {
+				return gzData, true
+			}
+		}
+	} else if e.config.EnablePrecompressed {
+		// 未启用缓存时直接读取预压缩文件
+		if gzData, err := e.getPrecompressedFile(path, "gzip"); err == nil {
+			return gzData, true
+		}
+	}
+
+	// 小文件不压缩
+	if !e.config.EnableGzip || len(data) < e.config.CompressMinSize {
+		return nil, false
+	}
+
+	// 实时压缩
+	gzData, err := GzipCompress(data, e.config.GzipLevel)
+	if err != nil {
+		e.reportError(path, ErrorOpCompress, err)
+		return nil, false
+	}
+	return gzData, len(gzData) < len(data)
+}
+
+// brotliData 返回 data 的 Brotli 版本
+// 启用缓存时使用缓存中的版本（可能来自预压缩文件），否则读取预压缩文件或实时压缩（启用 Brotli 时）
+func (e *StaticEngine) brotliData(data []byte, path string) ([]byte, bool) {
+	if e.config.EnableCache {
+		if entry, ok := e.cache.acquire(e.cacheKey(path)); ok {
+			brData := entry.Brotli
+			e.cache.release(entry)
+			if brData != nil {
+				return brData, true
+			}
+		}
+	}
+	brData := e.compressBrotli(path, data)
+	return brData, brData != nil
+}
+
+// zstdDictCompress 使用配置的字典压缩数据，编码器可并发复用
+func (e *StaticEngine) zstdDictCompress(data []byte) ([]byte, error) {
+	e.zstdDictOnce.Do(func() {
+		e.zstdDictEncoder, e.zstdDictErr = NewZstdDictEncoder(e.config.ZstdDictionary)
+	})
+	if e.zstdDictErr != nil {
+		return nil, e.zstdDictErr
+	}
+	return e.zstdDictEncoder.EncodeAll(data, nil), nil
+}
+
+// getPrecompressedFile 读取与文件同目录、对应 encoding 的预压缩文件（如 gzip 对应 .gz）
+func (e *StaticEngine) getPrecompressedFile(path, encoding string) ([]byte, error) {
+	for _, pe := range e.precompressedEncodings() {
+		if pe.Encoding != encoding {
+			continue
+		}
+		if data, err := e.readPrecompressed(path + pe.Ext); err == nil {
+			return data, nil
+		}
+	}
+	return nil, os.ErrNotExist
+}
+
+// readPrecompressed 从文件系统或 embed 读取预压缩文件
+func (e *StaticEngine) readPrecompressed(path string) ([]byte, error) {
+	var data []byte
+	var err error
+	if e.config.EmbedFS != nil {
+		data, _, _, err = e.getEmbedFile(path)
+	} else {
+		data, _, _, err = e.getOSFile(path)
+	}
+	return data, err
+}
 
-	info, err := file.Stat()
-	if err != nil {
-		return nil, nil, err
+// precompressedEncodings 返回预压缩文件对应关系，未配置时使用默认值
+func (e *StaticEngine) precompressedEncodings() []PrecompressedEncoding {
+	if len(e.config.PrecompressedEncodings) > 0 {
+		return e.config.PrecompressedEncodings
 	}
+	return defaultPrecompressedEncodings
+}
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		return nil, nil, err
+// cacheControl 获取响应的 Cache-Control
+// 启用 NoCacheHTML 时 HTML 入口文件始终为 no-cache，其余文件使用全局配置
+func (e *StaticEngine) cacheControl(path string) string {
+	if e.config.NoCacheHTML && isHTMLPath(path) {
+		return "no-cache"
 	}
-
-	return data, info, nil
+	return e.config.CacheControl
 }
 
-// readFromHTTPFS 从 http.FileSystem 读取文件
-func readFromHTTPFS(fsys http.FileSystem, path string) ([]byte, os.FileInfo, error) {
-	file, err := fsys.Open(path)
-	if err != nil {
-		return nil, nil, err
+// requestCacheControl 获取当前请求的 Cache-Control
+// hashed 为内容寻址请求时使用 immutable 缓存；PrivateCacheWhen 对请求返回 true 时，将 public 指令替换为 private
+func (e *StaticEngine) requestCacheControl(c *gin.Context, path string, hashed bool) string {
+	cacheControl := e.cacheControl(path)
+	if hashed {
+		cacheControl = contentAddressedCacheControl
 	}
-	defer file.Close()
-
-	info, err := file.Stat()
-	if err != nil {
-		return nil, nil, err
+	if cacheControl == "" || e.config.PrivateCacheWhen == nil || !e.config.PrivateCacheWhen(c) {
+		return cacheControl
 	}
+	return privateCacheControl(cacheControl)
+}
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		return nil, nil, err
+// privateCacheControl 将 Cache-Control 中的 public 指令替换为 private，其余指令保持不变
+func privateCacheControl(cacheControl string) string {
+	directives := strings.Split(cacheControl, ",")
+	for i, d := range directives {
+		if strings.EqualFold(strings.TrimSpace(d), "public") {
+			directives[i] = strings.Replace(d, strings.TrimSpace(d), "private", 1)
+		}
 	}
-
-	return data, info, nil
+	return strings.Join(directives, ",")
 }
 
-// readFromFSOpener 使用 fs.FileOpener 接口读取文件
-func readFromFSOpener(opener interface {
-	Open(name string) (fs2.File, error)
-}, path string) ([]byte, os.FileInfo, error) {
-	file, err := opener.Open(path)
-	if err != nil {
-		return nil, nil, err
+// contentType 获取响应的 Content-Type
+// 启用内容嗅探时，对无法按扩展名识别的文件检测数据前 512 字节
+func (e *StaticEngine) contentType(path string, data []byte) string {
+	if e.config.WasmStreaming && isWasmPath(path) {
+		return "application/wasm"
 	}
-	defer file.Close()
+	mimeType := GetMimeType(path, e.config.MimeTypes)
+	if e.config.EnableContentSniffing && mimeType == "application/octet-stream" && len(data) > 0 {
+		return http.DetectContentType(data)
+	}
+	return mimeType
+}
 
-	info, err := file.Stat()
-	if err != nil {
-		return nil, nil, err
+// setCrossOriginIsolation 按配置设置跨源隔离响应头
+func (e *StaticEngine) setCrossOriginIsolation(h http.Header) {
+	if e.config.WasmStreaming && e.config.WasmCrossOriginIsolation {
+		h.Set("Cross-Origin-Opener-Policy", "same-origin")
+		h.Set("Cross-Origin-Embedder-Policy", "require-corp")
 	}
+}
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		return nil, nil, err
+// sourceName 返回当前文件后端的名称（SourceEmbed 或 SourceDisk）
+func (e *StaticEngine) sourceName() string {
+	if e.config.EmbedFS != nil {
+		return SourceEmbed
 	}
+	return SourceDisk
+}
 
-	return data, info, nil
+// isHiddenSourceMap 检查是否为启用 HideSourceMaps 后需要隐藏的 .map 文件
+func (e *StaticEngine) isHiddenSourceMap(path string) bool {
+	return e.config.HideSourceMaps && strings.EqualFold(filepath.Ext(path), ".map")
 }
 
-// generateETagEmbed 为 embed 文件生成 ETag
-func generateETagEmbed(path string, info os.FileInfo) string {
-	h := fnv.New64a()
-	h.Write([]byte(fmt.Sprintf("%d-%d-%s", info.Size(), info.ModTime().UnixNano(), path)))
-	return fmt.Sprintf(`"%x"`, h.Sum(nil))
+// isWasmPath 检查路径是否为 .wasm 文件
+func isWasmPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".wasm")
 }
 
-// getCompressedData 获取压缩后的数据
-func (e *StaticEngine) getCompressedData(c *gin.Context, data []byte, path string) ([]byte, string) {
-	if !e.config.EnableGzip {
-		return data, ""
+// matchETag 检查 If-None-Match 是否匹配当前 ETag
+// 支持逗号分隔的多个值、通配符 *（文件存在即匹配），按弱比较忽略 W/ 前缀，
+// 并忽略 WithEncodingETagSuffix 追加的编码后缀
+func matchETag(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
 	}
 
-	// 小文件不压缩
-	if len(data) < e.config.CompressMinSize {
-		return data, ""
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || stripETagEncoding(strings.TrimPrefix(candidate, "W/")) == etag {
+			return true
+		}
 	}
+	return false
+}
 
-	// 检查缓存
-	if e.config.EnableCache {
-		if entry, ok := e.cache.Get(path); ok && entry.Gzipped != nil {
-			acceptEncoding := c.GetHeader("Accept-Encoding")
-			compressed, encoding, ok := GetCompressedData(data, entry.Gzipped, nil, acceptEncoding)
-			if ok {
-				return compressed, encoding
-			}
-		}
+// etagEncodings 可能追加到 ETag 的编码后缀
+var etagEncodings = []string{"gzip", "zstd", "br", ZstdDictEncoding}
+
+// encodingETag 为压缩响应的 ETag 追加编码后缀，如 "abc" -> "abc-gzip"
+func encodingETag(etag, encoding string) string {
+	if strings.HasSuffix(etag, `"`) {
+		return etag[:len(etag)-1] + "-" + encoding + `"`
 	}
+	return etag + "-" + encoding
+}
 
-	// 实时压缩
-	acceptEncoding := c.GetHeader("Accept-Encoding")
-	if containsEncoding(acceptEncoding, "gzip") {
-		gzData, err := GzipCompress(data, e.config.GzipLevel)
-		if err == nil && len(gzData) < len(data) {
-			return gzData, "gzip"
+// stripETagEncoding 去掉 encodingETag 追加的编码后缀
+func stripETagEncoding(etag string) string {
+	for _, encoding := range etagEncodings {
+		suffix := "-" + encoding + `"`
+		if strings.HasSuffix(etag, suffix) {
+			return etag[:len(etag)-len(suffix)] + `"`
 		}
 	}
-
-	return data, ""
+	return etag
 }
 
 // checkNotModified 检查条件请求
 func (e *StaticEngine) checkNotModified(c *gin.Context, modTime time.Time, etag string) bool {
 	// ETag 检查
 	if e.config.UseETag {
-		ifNoneMatch := c.GetHeader("If-None-Match")
-		if ifNoneMatch != "" && ifNoneMatch == etag {
+		if matchETag(c.GetHeader("If-None-Match"), etag) {
 			c.Status(http.StatusNotModified)
 			return true
 		}
@@ -453,14 +1251,186 @@ func (e *StaticEngine) checkNotModified(c *gin.Context, modTime time.Time, etag
 	return false
 }
 
+// beforeServe 调用写入前回调，回调返回错误时响应 500 并返回 false
+func (e *StaticEngine) beforeServe(c *gin.Context, stat *FileStat) bool {
+	if e.config.BeforeServe == nil {
+		return true
+	}
+	if err := e.config.BeforeServe(c, stat); err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// writeData 写入响应体，语义同 c.Data，但检查写入错误：
+// 写入失败（如客户端中途断开）时记录到 c.Errors 并中止后续处理器
+func (e *StaticEngine) writeData(c *gin.Context, path, mimeType string, data []byte, raw int, encoding, source string) {
+	if c.Writer.Header().Get("Content-Type") == "" {
+		c.Header("Content-Type", mimeType)
+	}
+	if err := e.writeBody(c.Writer, path, c.Writer.Status(), data, raw, encoding, source); err != nil {
+		c.Error(err)
+		c.Abort()
+	}
+}
+
+// writeBody 写入响应体并报告结果
+// 仅完整写入时计入字节统计；设置了 OnResponse 时无论成功与否都回调
+func (e *StaticEngine) writeBody(w io.Writer, path string, status int, data []byte, raw int, encoding, source string) error {
+	n, err := writeFull(w, data)
+	if err == nil {
+		e.recordBytesServed(raw, n, encoding)
+	}
+	if e.config.OnResponse != nil {
+		e.config.OnResponse(&ResponseInfo{
+			Path:     path,
+			Status:   status,
+			Size:     len(data),
+			Written:  n,
+			Encoding: encoding,
+			Source:   source,
+			Err:      err,
+		})
+	}
+	return err
+}
+
+// writeFull 写入全部数据，未报错的短写视为 io.ErrShortWrite
+func writeFull(w io.Writer, data []byte) (int, error) {
+	n, err := w.Write(data)
+	if err == nil && n < len(data) {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
+
+// withHeadResponse 以 GET 的处理逻辑响应 HEAD 请求
+// 响应头（Content-Length、ETag、Last-Modified 等）与 GET 一致，响应体被丢弃
+func withHeadResponse(h gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer = &headGinWriter{c.Writer}
+		h(c)
+	}
+}
+
+// headGinWriter 丢弃响应体的 gin.ResponseWriter，用于 HEAD 请求
+type headGinWriter struct {
+	gin.ResponseWriter
+}
+
+func (w *headGinWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	return len(data), nil
+}
+
+func (w *headGinWriter) WriteString(s string) (int, error) {
+	w.WriteHeaderNow()
+	return len(s), nil
+}
+
+// isURITooLong 检查请求 URI（路径加查询串）是否超过限制，max <= 0 表示不限制
+func isURITooLong(r *http.Request, max int) bool {
+	return max > 0 && len(r.URL.RequestURI()) > max
+}
+
+// isUpgradeRequest 检查请求是否要求协议升级（Connection 头包含 upgrade）
+func isUpgradeRequest(r *http.Request) bool {
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serveDefaultFile 为缺失的常见路径返回内置回退内容，内容为空时返回 204
+// 返回是否已处理该请求
+func (e *StaticEngine) serveDefaultFile(c *gin.Context, path string) bool {
+	data, ok := e.config.DefaultFiles[path]
+	if !ok {
+		return false
+	}
+
+	if len(data) == 0 {
+		c.Status(http.StatusNoContent)
+		return true
+	}
+
+	if cacheControl := e.requestCacheControl(c, path, false); cacheControl != "" && !e.config.MinimalHeaders {
+		c.Header("Cache-Control", cacheControl)
+	}
+	if e.config.DebugHeaders {
+		c.Header("X-Served-From", SourceDefault)
+	}
+	c.Status(http.StatusOK)
+	e.writeData(c, path, e.contentType(path, data), data, len(data), "", SourceDefault)
+	return true
+}
+
+// serveReadError 报告文件存在但读取失败（权限、IO、校验等）的错误，并按 ReadErrorStatus 响应
+// 客户端已断开时不报告也不响应
+func (e *StaticEngine) serveReadError(c *gin.Context, path string, err error) {
+	if errors.Is(err, context.Canceled) {
+		c.Abort()
+		return
+	}
+	e.reportError(path, readErrorOp(err), err)
+	c.Error(err)
+	e.serveError(c, e.readErrorStatus())
+}
+
+// readErrorStatus 返回读取失败时的响应状态码，未设置时为 500
+func (e *StaticEngine) readErrorStatus() int {
+	if e.config.ReadErrorStatus == 0 {
+		return http.StatusInternalServerError
+	}
+	return e.config.ReadErrorStatus
+}
+
+// reportError 调用 OnError 回调报告内部服务错误
+func (e *StaticEngine) reportError(path, op string, err error) {
+	if e.config.OnError != nil {
+		e.config.OnError(path, op, err)
+	}
+}
+
+// isNotFound 判断读取错误是否表示文件不存在（含目录、路径中间段为文件的情况）
+// 其余错误（权限不足、IO 错误、校验失败等）说明文件存在但无法服务
+func isNotFound(err error) bool {
+	return errors.Is(err, fs2.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) || errors.Is(err, syscall.EISDIR)
+}
+
+// readErrorOp 返回读取错误对应的 OnError 操作名
+func readErrorOp(err error) string {
+	if errors.Is(err, ErrChecksumMismatch) {
+		return ErrorOpChecksum
+	}
+	var pathErr *fs2.PathError
+	if errors.As(err, &pathErr) && pathErr.Op == "stat" {
+		return ErrorOpStat
+	}
+	return ErrorOpRead
+}
+
 // serveError 服务错误页面
 func (e *StaticEngine) serveError(c *gin.Context, status int) {
+	// 自定义 404 处理器优先
+	if status == http.StatusNotFound && e.config.NotFoundHandler != nil {
+		e.config.NotFoundHandler(c)
+		return
+	}
+
 	// 尝试自定义 404
 	if status == http.StatusNotFound && e.config.Custom404 != "" {
 		data, err := os.ReadFile(filepath.Join(e.config.Root, e.config.Custom404))
 		if err == nil {
 			c.Header("Content-Type", "text/html")
-			c.Data(http.StatusNotFound, "text/html", data)
+			c.Status(http.StatusNotFound)
+			e.writeData(c, c.Request.URL.Path, "text/html", data, len(data), "", SourceDisk)
 			return
 		}
 	}
@@ -470,91 +1440,174 @@ func (e *StaticEngine) serveError(c *gin.Context, status int) {
 
 // ServeHTTP 实现 http.Handler 接口
 func (e *StaticEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Path
+	start := time.Now()
+	sw := &statusResponseWriter{ResponseWriter: w}
+	e.serveHTTP(sw, r)
+	e.requests.observe(sw.Status(), time.Since(start))
+}
 
-	// 安全检查
-	safe, cleanPath := IsPathTraversal(e.config.Root, path)
-	if !safe {
-		http.Error(w, "forbidden", http.StatusForbidden)
-		return
+// serveHTTP ServeHTTP 的请求处理
+// 将请求适配为 gin.Context 后交给 serveFile，与 gin 路由共用同一处理流程（含 OnRequest、
+// 内置回退文件、NotFoundHandler 与 BeforeServe 等回调，以及 RequestTimeout）；请求路径取 URL 的完整路径
+func (e *StaticEngine) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	gw := &ginResponseWriter{ResponseWriter: w, size: -1, status: http.StatusOK}
+	c := &gin.Context{
+		Request: r,
+		Writer:  gw,
+		Params:  gin.Params{{Key: "path", Value: r.URL.Path}},
 	}
 
-	// 检查隐藏文件
-	if e.config.HideDotFiles && IsHiddenPath(cleanPath) {
-		http.Error(w, "forbidden", http.StatusForbidden)
-		return
+	handler := e.withRequestTimeout(e.serveFile)
+	if r.Method == http.MethodHead {
+		handler = withHeadResponse(handler)
 	}
+	handler(c)
+	// 与 gin 一致：处理器只设置了状态码时补发响应头
+	gw.WriteHeaderNow()
+}
 
-	// 获取文件
-	data, modTime, etag, err := e.getFile(cleanPath)
-	if err != nil {
-		if e.config.EnableSPA && e.config.SPAFallback {
-			data, modTime, etag, err = e.getFile(e.config.IndexFile)
-			if err != nil {
-				http.Error(w, "not found", http.StatusNotFound)
-				return
-			}
-		} else {
-			http.Error(w, "not found", http.StatusNotFound)
-			return
-		}
+// ginResponseWriter 将 http.ResponseWriter 适配为 gin.ResponseWriter，供 ServeHTTP 复用 gin 处理流程
+// 与 gin 内置实现一致：WriteHeader 只记录状态码，首次写入响应体或调用 WriteHeaderNow 时才发出响应头
+type ginResponseWriter struct {
+	http.ResponseWriter
+	size   int // 已写入的响应体字节数，-1 表示响应头尚未发出
+	status int
+}
+
+func (w *ginResponseWriter) WriteHeader(code int) {
+	if code > 0 && !w.Written() {
+		w.status = code
 	}
+}
 
-	// 条件请求检查
-	if e.checkHTTPNotModified(r, modTime, etag) {
-		w.WriteHeader(http.StatusNotModified)
-		return
+func (w *ginResponseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.size = 0
+		w.ResponseWriter.WriteHeader(w.status)
 	}
+}
 
-	// 设置响应头
-	mimeType := GetMimeType(cleanPath, e.config.MimeTypes)
-	w.Header().Set("Content-Type", mimeType)
-	w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+func (w *ginResponseWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
 
-	if e.config.UseETag {
-		w.Header().Set("ETag", etag)
+func (w *ginResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *ginResponseWriter) Status() int {
+	return w.status
+}
+
+func (w *ginResponseWriter) Size() int {
+	return w.size
+}
+
+func (w *ginResponseWriter) Written() bool {
+	return w.size != -1
+}
+
+func (w *ginResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.size < 0 {
+		w.size = 0
 	}
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
 
-	if e.config.CacheControl != "" {
-		w.Header().Set("Cache-Control", e.config.CacheControl)
+func (w *ginResponseWriter) Flush() {
+	w.WriteHeaderNow()
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *ginResponseWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
 	}
+	return nil
+}
 
-	// Gzip 压缩
-	acceptEncoding := r.Header.Get("Accept-Encoding")
-	if e.config.EnableGzip && len(data) >= e.config.CompressMinSize {
-		if containsEncoding(acceptEncoding, "gzip") {
-			gzData, err := GzipCompress(data, e.config.GzipLevel)
-			if err == nil && len(gzData) < len(data) {
-				data = gzData
-				w.Header().Set("Content-Encoding", "gzip")
-				w.Header().Set("Vary", "Accept-Encoding")
-			}
-		}
+func (w *ginResponseWriter) Pusher() http.Pusher {
+	pusher, _ := w.ResponseWriter.(http.Pusher)
+	return pusher
+}
+
+// Unwrap 返回底层 http.ResponseWriter，供 http.ResponseController 使用
+func (w *ginResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// redirectDirectory 请求的是含索引文件的目录但缺少末尾斜杠时，重定向到带斜杠的地址（默认 301）
+// 保证页面中的相对链接按目录解析；重定向地址经 externalURL 转换为对外地址
+func (e *StaticEngine) redirectDirectory(w http.ResponseWriter, r *http.Request, cleanPath string) bool {
+	if cleanPath == "" || cleanPath == "/" || strings.HasSuffix(r.URL.Path, "/") {
+		return false
+	}
+	if _, _, _, err := e.getFile(pathpkg.Join(cleanPath, e.config.IndexFile)); err != nil {
+		return false
+	}
+	target := e.externalURL(r.URL.Path + "/")
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
 	}
+	http.Redirect(w, r, target, e.redirectStatus())
+	return true
+}
 
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
-	w.WriteHeader(http.StatusOK)
-	w.Write(data)
+// redirectStatus 返回重定向状态码，未配置或配置无效时为 301
+func (e *StaticEngine) redirectStatus() int {
+	if isRedirectStatus(e.config.RedirectStatus) {
+		return e.config.RedirectStatus
+	}
+	return http.StatusMovedPermanently
 }
 
-// checkHTTPNotModified 检查 HTTP 条件请求
-func (e *StaticEngine) checkHTTPNotModified(r *http.Request, modTime time.Time, etag string) bool {
-	if e.config.UseETag {
-		ifNoneMatch := r.Header.Get("If-None-Match")
-		if ifNoneMatch != "" && ifNoneMatch == etag {
-			return true
-		}
+// externalURL 将内部绝对路径转换为对外地址（加上 ExternalBasePath）
+func (e *StaticEngine) externalURL(p string) string {
+	base := strings.Trim(e.config.ExternalBasePath, "/")
+	if base == "" {
+		return p
 	}
+	return "/" + base + p
+}
 
-	ifModifiedSince := r.Header.Get("If-Modified-Since")
-	if ifModifiedSince != "" {
-		ifMod, err := time.Parse(http.TimeFormat, ifModifiedSince)
-		if err == nil && !modTime.After(ifMod) {
-			return true
+// tooManyRanges 检查 Range 头的区间数是否超过 MaxRanges 限制
+func (e *StaticEngine) tooManyRanges(header string) bool {
+	return e.config.MaxRanges > 0 && countRanges(header) > e.config.MaxRanges
+}
+
+// countRanges 统计 bytes 单位 Range 头中的区间数，其他单位或空值返回 0
+func countRanges(header string) int {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok {
+		return 0
+	}
+	n := 0
+	for _, r := range strings.Split(spec, ",") {
+		if strings.TrimSpace(r) != "" {
+			n++
 		}
 	}
+	return n
+}
 
-	return false
+// recordBytesServed 记录一次成功写入的原始字节数与实际传输字节数
+// encoding 非空时同时计入压缩统计（如启用）
+func (e *StaticEngine) recordBytesServed(raw, wire int, encoding string) {
+	atomic.AddInt64(&e.rawBytesServed, int64(raw))
+	atomic.AddInt64(&e.wireBytesServed, int64(wire))
+	if encoding != "" && e.config.EnableCompressionStats {
+		e.recordCompression(encoding, raw, wire)
+	}
+}
+
+// BytesServed 返回累计服务的字节数（并发安全）
+// raw: 压缩前的原始字节数
+// wire: 实际写出的字节数（压缩后），可用于出口流量计费
+func (e *StaticEngine) BytesServed() (raw, wire int64) {
+	return atomic.LoadInt64(&e.rawBytesServed), atomic.LoadInt64(&e.wireBytesServed)
 }
 
 // Cache 返回缓存实例（用于外部访问）
@@ -568,14 +1621,34 @@ func (e *StaticEngine) Config() *Config {
 }
 
 // ResetCache 重置缓存
+// 启用 WithStaleWhileRevalidate 时不清空，而是将条目标记为过期，由后续请求触发后台刷新
 func (e *StaticEngine) ResetCache() {
+	if e.config.StaleWindow > 0 {
+		e.cache.markStale(e.config.StaleWindow)
+		return
+	}
 	e.cache.Clear()
 }
 
 // ReloadCache 重新加载缓存
+// embed 后端会自动转为调用 ReloadEmbed；启用 WithStaleWhileRevalidate 时已缓存的文件在后台刷新，
+// 返回时刷新可能尚未完成
 func (e *StaticEngine) ReloadCache() error {
-	e.cache.Clear()
-	return e.cache.preloadDirectory(e.config.Root, e.config.EnableGzip, e.config.GzipLevel)
+	if e.config.EmbedFS != nil {
+		return e.ReloadEmbed()
+	}
+	e.ResetCache()
+	return e.preload()
+}
+
+// ReloadEmbed 重新加载 embed 缓存
+// 清空缓存后重新遍历 embed.FS 预加载所有文件，用于虚拟文件或转换规则变化后重新预热
+func (e *StaticEngine) ReloadEmbed() error {
+	if e.config.EmbedFS == nil {
+		return fmt.Errorf("embed filesystem not configured")
+	}
+	e.ResetCache()
+	return e.preload()
 }
 
 // generateETag 生成 ETag（HTTP 接口专用）
@@ -595,20 +1668,154 @@ func (e *StaticEngine) WriteTo(w io.Writer, path string) (int64, error) {
 	return int64(n), err
 }
 
-// preloadEmbed 预加载 embed 文件到缓存
-func (e *StaticEngine) preloadEmbed() {
-	if e.config.EmbedFS == nil {
-		return
+// startPreload 在后台执行启动预加载，完成后关闭 preloadDone
+func (e *StaticEngine) startPreload() {
+	e.preloadDone = make(chan struct{})
+	go func() {
+		e.preloadErr = e.preload()
+		close(e.preloadDone)
+	}()
+}
+
+// WaitPreload 阻塞直到启动预加载完成，返回预加载过程中的首个错误
+// 返回后所有文件已连同 ETag 和压缩版本写入缓存，首个请求即携带校验器，
+// 条件请求直接由缓存响应 304；未启用 PreloadOnStart 时立即返回 nil
+func (e *StaticEngine) WaitPreload() error {
+	if e.preloadDone == nil {
+		return nil
+	}
+	<-e.preloadDone
+	return e.preloadErr
+}
+
+// preload 预加载所有文件到缓存
+// 文件经 getFile 加载，与请求使用相同的缓存键；设置 MaxBackgroundGoroutines 时
+// 通过后台信号量并发加载，遍历协程本身也占用一个名额
+func (e *StaticEngine) preload() error {
+	e.acquireBackground()
+	defer e.releaseBackground()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	load := func(path string) {
+		if _, _, _, err := e.getFile(path); err != nil {
+			mu.Lock()
+			if firstErr == nil {
+				firstErr = err
+			}
+			mu.Unlock()
+		}
+	}
+	visit := func(path string) {
+		// 名额已满时在当前协程中加载，保证总数不超过上限
+		if !e.tryAcquireBackground() {
+			load(path)
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer e.releaseBackground()
+			load(path)
+		}()
+	}
+
+	var walkErr error
+	if e.config.EmbedFS != nil {
+		e.walkEmbedFiles(visit)
+	} else {
+		root := e.config.Root
+		walkErr = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			visit("/" + filepath.ToSlash(rel))
+			return nil
+		})
+	}
+
+	wg.Wait()
+	if walkErr != nil {
+		return walkErr
+	}
+	return firstErr
+}
+
+// backgroundSem 返回后台任务信号量，未设置上限时为 nil
+func (e *StaticEngine) backgroundSem() chan struct{} {
+	e.bgSemOnce.Do(func() {
+		if e.config.MaxBackgroundGoroutines > 0 {
+			e.bgSem = make(chan struct{}, e.config.MaxBackgroundGoroutines)
+		}
+	})
+	return e.bgSem
+}
+
+// acquireBackground 阻塞获取一个后台 goroutine 名额
+func (e *StaticEngine) acquireBackground() {
+	if sem := e.backgroundSem(); sem != nil {
+		sem <- struct{}{}
 	}
+	e.trackBackground(1)
+}
+
+// tryAcquireBackground 尝试获取一个后台 goroutine 名额，未设置上限或名额已满时返回 false
+func (e *StaticEngine) tryAcquireBackground() bool {
+	sem := e.backgroundSem()
+	if sem == nil {
+		return false
+	}
+	select {
+	case sem <- struct{}{}:
+		e.trackBackground(1)
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseBackground 归还后台 goroutine 名额
+func (e *StaticEngine) releaseBackground() {
+	e.trackBackground(-1)
+	if sem := e.backgroundSem(); sem != nil {
+		<-sem
+	}
+}
 
-	// 递归遍历 embed.FS
+// trackBackground 更新当前后台 goroutine 数并记录峰值
+func (e *StaticEngine) trackBackground(delta int32) {
+	n := atomic.AddInt32(&e.bgActive, delta)
+	for {
+		peak := atomic.LoadInt32(&e.bgPeak)
+		if n <= peak || atomic.CompareAndSwapInt32(&e.bgPeak, peak, n) {
+			return
+		}
+	}
+}
+
+// walkEmbedFiles 遍历 embed.FS 中的所有文件
+// fn 收到的路径已去掉 EmbedRoot 前缀并以 / 开头，与请求路径（缓存键）保持一致
+func (e *StaticEngine) walkEmbedFiles(fn func(string)) {
 	root := e.config.EmbedRoot
 	if root == "" {
 		root = "."
 	}
 
 	e.walkEmbed(e.config.EmbedFS, root, func(path string) {
-		e.getFile(path)
+		if e.config.EmbedRoot != "" {
+			path = strings.TrimPrefix(path, strings.TrimSuffix(e.config.EmbedRoot, "/")+"/")
+		}
+		fn("/" + path)
 	})
 }
 
@@ -635,17 +1842,17 @@ func (e *StaticEngine) walkEmbed(fs any, path string, fn func(string)) {
 	}
 
 	if info.IsDir() {
-		// 尝试使用 ReadDir
-		type readDirFile interface {
-			Readdir(n int) ([]fs2.DirEntry, error)
-		}
-		if rdf, ok := file.(readDirFile); ok {
-			entries, err := rdf.Readdir(-1)
+		// 目录需实现 fs.ReadDirFile（embed.FS 满足）
+		if rdf, ok := file.(fs2.ReadDirFile); ok {
+			entries, err := rdf.ReadDir(-1)
 			if err != nil {
 				return
 			}
 			for _, entry := range entries {
-				subPath := path + "/" + entry.Name()
+				subPath := entry.Name()
+				if path != "." {
+					subPath = path + "/" + subPath
+				}
 				e.walkEmbed(fs, subPath, fn)
 			}
 		}
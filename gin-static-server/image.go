@@ -0,0 +1,57 @@
+package ginstatic
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// modernImageFormats 参与协商的现代图片格式，按优先级从高到低排列
+var modernImageFormats = []struct {
+	ext      string
+	mimeType string
+}{
+	{".avif", "image/avif"},
+	{".webp", "image/webp"},
+}
+
+// negotiateImage 按 Accept 选择图片的现代格式版本（如 photo.jpg -> photo.avif）
+// 返回实际使用的路径，以及响应是否需要 Vary: Accept；
+// 未启用、非可协商图片时返回原路径和 false，客户端不支持或同名文件不存在时回退原路径
+func (e *StaticEngine) negotiateImage(accept, path string) (string, bool) {
+	if !e.config.EnableImageNegotiation || !isNegotiableImage(path) {
+		return path, false
+	}
+
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for _, format := range modernImageFormats {
+		if !acceptsMediaType(accept, format.mimeType) {
+			continue
+		}
+		sibling := base + format.ext
+		if _, _, _, err := e.getFile(sibling); err == nil {
+			return sibling, true
+		}
+	}
+	return path, true
+}
+
+// acceptsMediaType 检查 Accept 是否显式接受指定媒体类型（q > 0）
+// 通配符 image/* 与 */* 不视为支持，旧客户端普遍发送通配符却无法解码新格式
+func acceptsMediaType(accept, mimeType string) bool {
+	for _, t := range parseAcceptLanguage(accept) {
+		if strings.EqualFold(t, mimeType) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNegotiableImage 检查路径是否为可替换为现代格式的传统图片
+func isNegotiableImage(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
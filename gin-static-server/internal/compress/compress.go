@@ -0,0 +1,102 @@
+// Package compress 提供 gin-static-server 内部使用的 gzip/zstd 压缩原语
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// gzReaderPool Gzip 读取器池
+var gzReaderPool = sync.Pool{
+	New: func() interface{} {
+		return new(gzip.Reader)
+	},
+}
+
+// zstdEncoderPool Zstd 编码器池
+var zstdEncoderPool = sync.Pool{
+	New: func() interface{} {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
+		return enc
+	},
+}
+
+// GzipCompress 使用 Gzip 压缩数据
+// level: 压缩级别 1-9，超出范围时取最近的有效值
+func GzipCompress(data []byte, level int) ([]byte, error) {
+	if level < 1 {
+		level = gzip.BestSpeed
+	}
+	if level > 9 {
+		level = gzip.BestCompression
+	}
+
+	var buf bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = writer.Write(data)
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// GzipDecompress 解压 Gzip 数据
+func GzipDecompress(data []byte) ([]byte, error) {
+	return GzipDecompressReader(bytes.NewReader(data))
+}
+
+// GzipDecompressReader 读取并解压 r 中的 Gzip 数据
+func GzipDecompressReader(r io.Reader) ([]byte, error) {
+	reader := gzReaderPool.Get().(*gzip.Reader)
+	defer gzReaderPool.Put(reader)
+
+	err := reader.Reset(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(reader)
+}
+
+// ZstdCompress 使用 Zstd 压缩数据
+// 池中编码器只通过 EncodeAll 使用，不调用 Reset/Close，归还后可直接复用
+func ZstdCompress(data []byte) ([]byte, error) {
+	encoder, _ := zstdEncoderPool.Get().(*zstd.Encoder)
+	if encoder == nil {
+		// 池中编码器创建失败时重新创建，仍失败则返回错误
+		var err error
+		encoder, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer zstdEncoderPool.Put(encoder)
+
+	return encoder.EncodeAll(data, nil), nil
+}
+
+// ZstdDecompress 解压 Zstd 数据
+func ZstdDecompress(data []byte) ([]byte, error) {
+	dec, err := zstd.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+
+	return io.ReadAll(dec)
+}
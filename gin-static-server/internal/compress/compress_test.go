@@ -0,0 +1,73 @@
+package compress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGzipRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat("hello compress ", 100))
+
+	for _, level := range []int{0, 1, 6, 9, 10} {
+		compressed, err := GzipCompress(data, level)
+		if err != nil {
+			t.Fatalf("level %d: GzipCompress failed: %v", level, err)
+		}
+		if len(compressed) >= len(data) {
+			t.Errorf("level %d: expected compressed data to be smaller, got %d >= %d", level, len(compressed), len(data))
+		}
+
+		decompressed, err := GzipDecompress(compressed)
+		if err != nil {
+			t.Fatalf("level %d: GzipDecompress failed: %v", level, err)
+		}
+		if !bytes.Equal(decompressed, data) {
+			t.Errorf("level %d: decompressed data mismatch", level)
+		}
+	}
+
+	if _, err := GzipDecompress([]byte("not gzip")); err == nil {
+		t.Error("expected error for invalid gzip data")
+	}
+}
+
+func TestGzipDecompressReader(t *testing.T) {
+	data := []byte(strings.Repeat("reader ", 50))
+	compressed, err := GzipCompress(data, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decompressed, err := GzipDecompressReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("GzipDecompressReader failed: %v", err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Error("decompressed data mismatch")
+	}
+}
+
+func TestZstdRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat("hello zstd ", 100))
+
+	compressed, err := ZstdCompress(data)
+	if err != nil {
+		t.Fatalf("ZstdCompress failed: %v", err)
+	}
+	if len(compressed) >= len(data) {
+		t.Errorf("expected compressed data to be smaller, got %d >= %d", len(compressed), len(data))
+	}
+
+	decompressed, err := ZstdDecompress(compressed)
+	if err != nil {
+		t.Fatalf("ZstdDecompress failed: %v", err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Error("decompressed data mismatch")
+	}
+
+	if _, err := ZstdDecompress([]byte("not zstd")); err == nil {
+		t.Error("expected error for invalid zstd data")
+	}
+}
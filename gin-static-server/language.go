@@ -0,0 +1,107 @@
+package ginstatic
+
+import (
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// negotiateLanguage 按 Accept-Language 选择 HTML 文件的语言版本
+// 返回实际使用的路径与语言标签；未启用、非 HTML 文件或语言版本不存在时返回原路径和空标签
+func (e *StaticEngine) negotiateLanguage(acceptLanguage, path string) (string, string) {
+	if !e.config.EnableLanguageNegotiation || !isHTMLPath(path) {
+		return path, ""
+	}
+
+	lang := matchLanguage(acceptLanguage, e.config.SupportedLanguages)
+	if lang == "" {
+		lang = e.config.DefaultLanguage
+	}
+	if lang == "" {
+		return path, ""
+	}
+
+	ext := filepath.Ext(path)
+	langPath := strings.TrimSuffix(path, ext) + "." + lang + ext
+	if _, _, _, err := e.getFile(langPath); err != nil {
+		return path, ""
+	}
+	return langPath, lang
+}
+
+// setLanguageHeaders 为参与语言协商的 HTML 响应设置 Content-Language 与 Vary
+func (e *StaticEngine) setLanguageHeaders(h http.Header, path, lang string) {
+	if !e.config.EnableLanguageNegotiation || !isHTMLPath(path) {
+		return
+	}
+	h.Add("Vary", "Accept-Language")
+	if lang != "" {
+		h.Set("Content-Language", lang)
+	}
+}
+
+// matchLanguage 按 q 值从高到低匹配支持的语言
+// 先比较完整标签，再比较主标签（zh-CN 匹配 zh），未匹配返回空字符串
+func matchLanguage(acceptLanguage string, supported []string) string {
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		primary := strings.SplitN(tag, "-", 2)[0]
+		for _, s := range supported {
+			if strings.EqualFold(s, tag) || strings.EqualFold(s, primary) {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// parseAcceptLanguage 解析 Accept-Language，按 q 值从高到低返回语言标签
+// q=0 与通配符 * 会被忽略
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		tag, q := part, 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			tag = strings.TrimSpace(part[:i])
+			param := strings.TrimSpace(part[i+1:])
+			if strings.HasPrefix(param, "q=") {
+				v, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					continue
+				}
+				q = v
+			}
+		}
+		if tag == "*" || q <= 0 {
+			continue
+		}
+		tags = append(tags, weighted{tag: tag, q: q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
+
+// isHTMLPath 检查路径是否为 HTML 文件
+func isHTMLPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".html" || ext == ".htm"
+}
@@ -0,0 +1,155 @@
+package ginstatic
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ManifestEntry 资源清单条目
+type ManifestEntry struct {
+	Size        int64  `json:"size"`        // 文件大小（字节）
+	ETag        string `json:"etag"`        // 与响应头一致的 ETag
+	SRI         string `json:"sri"`         // 子资源完整性校验值（sha384）
+	ContentType string `json:"contentType"` // 响应的 Content-Type
+}
+
+// Manifest 生成所有可服务资源的清单（请求路径 -> 条目）
+// 遍历文件来源（磁盘或 embed），跳过会被拒绝的点文件；已缓存的文件直接复用缓存内容，
+// 未缓存的文件只读取不写入缓存。可用于 Service Worker 预缓存或缓存破坏
+func (e *StaticEngine) Manifest() (map[string]ManifestEntry, error) {
+	manifest := make(map[string]ManifestEntry)
+
+	add := func(path string) error {
+		if e.config.HideDotFiles && IsHiddenPath(path) || e.isHiddenSourceMap(path) {
+			return nil
+		}
+		data, etag, err := e.manifestFile(path)
+		if err != nil {
+			return err
+		}
+		manifest[path] = ManifestEntry{
+			Size:        int64(len(data)),
+			ETag:        etag,
+			SRI:         computeSRI(data),
+			ContentType: e.contentType(path, data),
+		}
+		return nil
+	}
+
+	if e.config.EmbedFS != nil {
+		var walkErr error
+		e.walkEmbedFiles(func(path string) {
+			if walkErr == nil {
+				walkErr = add(path)
+			}
+		})
+		return manifest, walkErr
+	}
+
+	err := filepath.WalkDir(e.config.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(e.config.Root, path)
+		if err != nil {
+			return err
+		}
+		return add("/" + filepath.ToSlash(relPath))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// manifestFile 获取清单所需的文件内容和 ETag，优先使用缓存
+func (e *StaticEngine) manifestFile(path string) ([]byte, string, error) {
+	if e.config.EnableCache {
+		if entry, ok := e.cache.acquire(e.cacheKey(path)); ok {
+			data, etag := entry.Data, entry.ETag
+			e.cache.release(entry)
+			return data, etag, nil
+		}
+	}
+
+	var data []byte
+	var etag string
+	var err error
+	if e.config.EmbedFS != nil {
+		data, _, etag, err = e.getEmbedFile(path)
+	} else {
+		data, _, etag, err = e.getOSFile(path)
+	}
+	return e.stripBOM(path, data), etag, err
+}
+
+// PrecacheEntry Service Worker 预缓存条目（Workbox precache manifest 格式）
+type PrecacheEntry struct {
+	URL      string `json:"url"`      // 资源 URL（含前缀）
+	Revision string `json:"revision"` // 内容哈希（sha384 十六进制）
+}
+
+// PrecacheHandler 返回预缓存清单的处理器
+// 响应 JSON 数组 [{url, revision}]，按 URL 排序，可直接用于 Workbox 的 precacheAndRoute
+func (e *StaticEngine) PrecacheHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		manifest, err := e.Manifest()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "manifest failed"})
+			return
+		}
+
+		prefix := strings.TrimSuffix(e.config.Prefix, "/")
+		entries := make([]PrecacheEntry, 0, len(manifest))
+		for path, entry := range manifest {
+			entries = append(entries, PrecacheEntry{
+				URL:      e.externalURL(prefix + path),
+				Revision: sriRevision(entry.SRI),
+			})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].URL < entries[j].URL
+		})
+
+		c.Header("Cache-Control", "no-cache")
+		c.JSON(http.StatusOK, entries)
+	}
+}
+
+// withPrecacheEndpoint 拦截预缓存清单端点，其余请求交给 next
+func (e *StaticEngine) withPrecacheEndpoint(endpoint string, next gin.HandlerFunc) gin.HandlerFunc {
+	precache := e.PrecacheHandler()
+	return func(c *gin.Context) {
+		if c.Request.URL.Path == endpoint {
+			precache(c)
+			return
+		}
+		next(c)
+	}
+}
+
+// sriRevision 将 SRI 值转换为十六进制内容哈希
+func sriRevision(sri string) string {
+	sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sri, "sha384-"))
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(sum)
+}
+
+// computeSRI 计算子资源完整性校验值
+func computeSRI(data []byte) string {
+	sum := sha512.Sum384(data)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,175 @@
+package ginstatic
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsNamespace Prometheus 指标名前缀
+const metricsNamespace = "ginstatic"
+
+// requestDurationBuckets 请求耗时直方图的桶上界（秒），与 prometheus.DefBuckets 相同
+var requestDurationBuckets = [...]float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// requestMetrics 请求计数与耗时直方图，请求路径上只做原子累加，抓取时再转换为 Prometheus 指标
+type requestMetrics struct {
+	codes sync.Map // 状态码 -> *int64
+
+	buckets [len(requestDurationBuckets)]uint64 // 各桶的请求数（不累计）
+	count   uint64
+	sumNano int64
+}
+
+// observe 记录一次请求
+func (m *requestMetrics) observe(status int, d time.Duration) {
+	v, ok := m.codes.Load(status)
+	if !ok {
+		v, _ = m.codes.LoadOrStore(status, new(int64))
+	}
+	atomic.AddInt64(v.(*int64), 1)
+
+	seconds := d.Seconds()
+	for i, upper := range requestDurationBuckets {
+		if seconds <= upper {
+			atomic.AddUint64(&m.buckets[i], 1)
+			break
+		}
+	}
+	atomic.AddUint64(&m.count, 1)
+	atomic.AddInt64(&m.sumNano, int64(d))
+}
+
+// withMetrics 记录 gin 路由处理的请求状态码与耗时
+func (e *StaticEngine) withMetrics(h gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		h(c)
+		e.requests.observe(c.Writer.Status(), time.Since(start))
+	}
+}
+
+// statusResponseWriter 记录状态码的 http.ResponseWriter，用于 ServeHTTP 的请求指标
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Unwrap 返回底层 http.ResponseWriter，供 http.ResponseController 使用
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status 返回已写出的状态码，未写出时为 200
+func (w *statusResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Collector 返回引擎的 Prometheus 指标收集器，注册后即可抓取：
+//
+//	prometheus.MustRegister(engine.Collector())
+//
+// 包含请求数（按状态码）与耗时直方图、缓存命中/未命中/淘汰次数、缓存大小与文件数、
+// 已服务字节数，以及各编码的压缩响应数、节省字节数与压缩比（后三项需启用 WithCompressionStats）。
+// 指标在抓取时读取，同一引擎的多个收集器不能注册到同一个 Registry
+func (e *StaticEngine) Collector() prometheus.Collector {
+	return &engineCollector{engine: e}
+}
+
+// engineCollector 按抓取读取引擎计数器的 prometheus.Collector
+type engineCollector struct {
+	engine *StaticEngine
+}
+
+var (
+	requestsDesc = prometheus.NewDesc(metricsNamespace+"_requests_total",
+		"Total number of requests handled, by status code.", []string{"code"}, nil)
+	requestDurationDesc = prometheus.NewDesc(metricsNamespace+"_request_duration_seconds",
+		"Request handling duration in seconds.", nil, nil)
+	cacheHitsDesc = prometheus.NewDesc(metricsNamespace+"_cache_hits_total",
+		"Total number of file lookups served from the memory cache.", nil, nil)
+	cacheMissesDesc = prometheus.NewDesc(metricsNamespace+"_cache_misses_total",
+		"Total number of file lookups that had to read the source.", nil, nil)
+	cacheEvictionsDesc = prometheus.NewDesc(metricsNamespace+"_cache_evictions_total",
+		"Total number of cache entries evicted to make room.", nil, nil)
+	cacheSizeDesc = prometheus.NewDesc(metricsNamespace+"_cache_size_bytes",
+		"Current size of the memory cache in bytes.", nil, nil)
+	cacheFilesDesc = prometheus.NewDesc(metricsNamespace+"_cache_files",
+		"Current number of files in the memory cache.", nil, nil)
+	bytesServedDesc = prometheus.NewDesc(metricsNamespace+"_bytes_served_total",
+		"Total bytes served, before (raw) and after (wire) compression.", []string{"kind"}, nil)
+	compressedResponsesDesc = prometheus.NewDesc(metricsNamespace+"_compressed_responses_total",
+		"Total number of compressed responses, by encoding.", []string{"encoding"}, nil)
+	compressionSavedDesc = prometheus.NewDesc(metricsNamespace+"_compression_saved_bytes_total",
+		"Total bytes saved by compression, by encoding.", []string{"encoding"}, nil)
+	compressionRatioDesc = prometheus.NewDesc(metricsNamespace+"_compression_ratio",
+		"Average compressed/raw size ratio weighted by bytes, by encoding.", []string{"encoding"}, nil)
+)
+
+func (c *engineCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		requestsDesc, requestDurationDesc, cacheHitsDesc, cacheMissesDesc, cacheEvictionsDesc,
+		cacheSizeDesc, cacheFilesDesc, bytesServedDesc, compressedResponsesDesc, compressionSavedDesc, compressionRatioDesc,
+	} {
+		ch <- desc
+	}
+}
+
+func (c *engineCollector) Collect(ch chan<- prometheus.Metric) {
+	e := c.engine
+	m := &e.requests
+
+	m.codes.Range(func(key, value interface{}) bool {
+		ch <- prometheus.MustNewConstMetric(requestsDesc, prometheus.CounterValue,
+			float64(atomic.LoadInt64(value.(*int64))), strconv.Itoa(key.(int)))
+		return true
+	})
+
+	// 直方图的桶为累计值
+	buckets := make(map[float64]uint64, len(requestDurationBuckets))
+	var cumulative uint64
+	for i, upper := range requestDurationBuckets {
+		cumulative += atomic.LoadUint64(&m.buckets[i])
+		buckets[upper] = cumulative
+	}
+	ch <- prometheus.MustNewConstHistogram(requestDurationDesc, atomic.LoadUint64(&m.count),
+		time.Duration(atomic.LoadInt64(&m.sumNano)).Seconds(), buckets)
+
+	ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&e.cacheHits)))
+	ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(atomic.LoadUint64(&e.cacheMisses)))
+	ch <- prometheus.MustNewConstMetric(cacheEvictionsDesc, prometheus.CounterValue, float64(e.cache.EvictCount()))
+	ch <- prometheus.MustNewConstMetric(cacheSizeDesc, prometheus.GaugeValue, float64(e.cache.Size()))
+	ch <- prometheus.MustNewConstMetric(cacheFilesDesc, prometheus.GaugeValue, float64(e.cache.FileCount()))
+
+	raw, wire := e.BytesServed()
+	ch <- prometheus.MustNewConstMetric(bytesServedDesc, prometheus.CounterValue, float64(raw), "raw")
+	ch <- prometheus.MustNewConstMetric(bytesServedDesc, prometheus.CounterValue, float64(wire), "wire")
+
+	for encoding, stats := range e.CompressionStats().Encodings {
+		ch <- prometheus.MustNewConstMetric(compressedResponsesDesc, prometheus.CounterValue, float64(stats.Responses), encoding)
+		ch <- prometheus.MustNewConstMetric(compressionSavedDesc, prometheus.CounterValue, float64(stats.SavedBytes), encoding)
+		ch <- prometheus.MustNewConstMetric(compressionRatioDesc, prometheus.GaugeValue, stats.Ratio, encoding)
+	}
+}
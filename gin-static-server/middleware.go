@@ -1,14 +1,17 @@
 package ginstatic
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"hash/fnv"
-	"io"
-	fs2 "io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	pathpkg "path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,16 +22,16 @@ import (
 type MiddlewareOptions struct {
 	// 请求限流
 	EnableRateLimit bool
-	RateLimit       int           // 每秒请求数
-	Burst           int           // 突发限制
+	RateLimit       int // 每秒请求数
+	Burst           int // 突发限制
 
 	// CORS
-	EnableCORS      bool
-	AllowedOrigins  []string
-	AllowedMethods  []string
-	AllowedHeaders  []string
-	ExposeHeaders   []string
-	MaxAge          time.Duration
+	EnableCORS     bool
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	ExposeHeaders  []string
+	MaxAge         time.Duration
 
 	// 安全头
 	EnableSecureHeaders bool
@@ -174,28 +177,133 @@ func RequestLoggerMiddleware(format string) gin.HandlerFunc {
 
 		// 简单日志输出
 		if status >= 400 {
-			log.Printf(format,
+			args := []any{
 				c.Request.Method,
 				status,
 				latency,
 				c.ClientIP(),
 				c.Request.UserAgent(),
 				path,
-			)
+			}
+			// 配合 RequestIDMiddleware 输出请求 ID
+			if requestID := GetRequestID(c); requestID != "" {
+				log.Printf(format+" | %s", append(args, requestID)...)
+				return
+			}
+			log.Printf(format, args...)
 		}
 	}
 }
 
-// CacheMiddleware 创建缓存控制中间件
-func CacheMiddleware(maxAge time.Duration, immutable bool) gin.HandlerFunc {
+// RequestIDKey 请求 ID 在 gin.Context 中的键名
+const RequestIDKey = "request_id"
+
+// RequestIDMiddleware 创建请求 ID 中间件
+// 优先读取请求头中的 ID，不存在时生成随机 ID，写入响应头并存入 gin.Context，
+// RequestLoggerMiddleware 会在日志中输出该 ID
+// headerName: 请求头/响应头名称，为空时使用 "X-Request-ID"
+func RequestIDMiddleware(headerName string) gin.HandlerFunc {
+	if headerName == "" {
+		headerName = "X-Request-ID"
+	}
+
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(headerName)
+		if requestID == "" {
+			requestID = generateRequestID()
+		}
+
+		c.Set(RequestIDKey, requestID)
+		c.Header(headerName, requestID)
+		c.Next()
+	}
+}
+
+// GetRequestID 获取 RequestIDMiddleware 存入的请求 ID，不存在时返回空字符串
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(RequestIDKey)
+}
+
+// generateRequestID 生成 16 字节随机十六进制请求 ID
+func generateRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// HTTPSRedirectMiddleware 创建 HTTPS 重定向中间件
+// 非 TLS 请求以 301 重定向到同一主机、路径与查询参数的 https:// 地址；httpsPort 为 443 或 <= 0 时地址中省略端口
+// trustedProxies 为可信代理的 IP 或 CIDR，仅来自这些地址的请求才采信 X-Forwarded-Proto，未指定时只依据 TLS 连接判断
+func HTTPSRedirectMiddleware(httpsPort int, trustedProxies ...string) gin.HandlerFunc {
+	proxies := make([]*net.IPNet, 0, len(trustedProxies))
+	for _, p := range trustedProxies {
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(p)
+		if err != nil {
+			panic(fmt.Sprintf("ginstatic: invalid trusted proxy %q: %v", p, err))
+		}
+		proxies = append(proxies, ipNet)
+	}
+
 	return func(c *gin.Context) {
-		value := "public"
-		if maxAge > 0 {
-			value += ", max-age=" + fmt.Sprintf("%d", int(maxAge.Seconds()))
+		if c.Request.TLS != nil || forwardedHTTPS(c, proxies) {
+			c.Next()
+			return
 		}
-		if immutable {
-			value += ", immutable"
+
+		host := c.Request.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		} else {
+			host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
 		}
+		if httpsPort > 0 && httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		c.Redirect(http.StatusMovedPermanently, "https://"+host+c.Request.URL.RequestURI())
+		c.Abort()
+	}
+}
+
+// forwardedHTTPS 检查来自可信代理的请求是否通过 X-Forwarded-Proto 声明为 https
+// 多级代理时取第一个值，即最靠近客户端的协议
+func forwardedHTTPS(c *gin.Context, proxies []*net.IPNet) bool {
+	proto := c.GetHeader("X-Forwarded-Proto")
+	if proto == "" || len(proxies) == 0 {
+		return false
+	}
+	if i := strings.IndexByte(proto, ','); i >= 0 {
+		proto = proto[:i]
+	}
+	if !strings.EqualFold(strings.TrimSpace(proto), "https") {
+		return false
+	}
+	ip := net.ParseIP(c.RemoteIP())
+	if ip == nil {
+		return false
+	}
+	for _, p := range proxies {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// CacheMiddleware 创建缓存控制中间件
+func CacheMiddleware(maxAge time.Duration, immutable bool) gin.HandlerFunc {
+	value := buildCacheControl(maxAge, immutable)
+	return func(c *gin.Context) {
 		c.Header("Cache-Control", value)
 		c.Next()
 	}
@@ -241,23 +349,40 @@ func (e *StaticEngine) AddMiddleware(middleware gin.HandlerFunc) *StaticEngine {
 
 // StaticExtsMiddlewareConfig 静态文件扩展名中间件配置
 type StaticExtsMiddlewareConfig struct {
-	Root            string            // 静态文件根目录
-	Prefix          string            // URL 路径前缀
-	EmbedFS         any               // embed.FS
-	EmbedRoot       string            // embed.FS 根目录
-	StaticExts      []string          // 需要拦截的静态资源扩展名
-	EnableCache     bool              // 是否启用缓存
-	MaxCacheSize    int64             // 最大缓存大小
-	MaxCacheFiles   int               // 最大缓存文件数
-	EnableGzip      bool              // 是否启用 Gzip
-	GzipLevel       int               // Gzip 压缩级别
-	UseETag         bool              // 是否使用 ETag
-	CacheControl    string            // 缓存控制头
-	HideDotFiles    bool              // 是否隐藏点文件
-	EnableIndex     bool              // 是否启用 index.html 回退（访问 / 自动返回 index.html）
-	IndexFile       string            // 默认索引文件，默认 "index.html"
-	OnCacheEvict    func(string)      // 缓存淘汰回调
-	OnRequest       func(string) bool // 请求前回调
+	Root              string            // 静态文件根目录
+	Prefix            string            // URL 路径前缀
+	EmbedFS           any               // embed.FS
+	EmbedRoot         string            // embed.FS 根目录
+	StaticExts        []string          // 需要拦截的静态资源扩展名
+	EnableCache       bool              // 是否启用缓存
+	MaxCacheSize      int64             // 最大缓存大小
+	MaxCacheFiles     int               // 最大缓存文件数
+	EnableGzip        bool              // 是否启用 Gzip
+	GzipLevel         int               // Gzip 压缩级别
+	EnableBrotli      bool              // 是否启用 Brotli
+	BrotliLevel       int               // Brotli 压缩级别
+	UseETag           bool              // 是否使用 ETag
+	CacheControl      string            // 缓存控制头
+	HideDotFiles      bool              // 是否隐藏点文件
+	EnableIndex       *bool             // 是否启用 index.html 回退（访问 / 自动返回 index.html），nil 表示默认启用
+	IndexFile         string            // 默认索引文件，默认 "index.html"
+	OnCacheEvict      func(string)      // 缓存淘汰回调
+	OnRequest         func(string) bool // 请求前回调
+	OnResponse        OnResponseFunc    // 写入响应体后回调，报告实际写入字节数与写入错误
+	Cache             *Cache            // 共享缓存实例，为空时按 MaxCacheSize/MaxCacheFiles 新建
+	PassthroughOnDeny bool              // 拒绝的请求调用 c.Next() 交给后续处理器，而不是返回 403
+	MaxURILength      int               // 请求 URI 最大长度，超出返回 414，0 表示不限制
+	EncodingPriority  []string          // 内容编码的服务端优先级，为空时使用 DefaultEncodingPriority，与引擎协商方式一致
+}
+
+// Bool 返回 v 的指针，用于设置 StaticExtsMiddlewareConfig.EnableIndex 等可选布尔字段
+func Bool(v bool) *bool {
+	return &v
+}
+
+// indexEnabled 是否启用 index.html 回退，未设置 EnableIndex 时默认启用
+func (c *StaticExtsMiddlewareConfig) indexEnabled() bool {
+	return c.EnableIndex == nil || *c.EnableIndex
 }
 
 // MiddlewareOption 中间件配置选项函数
@@ -282,19 +407,20 @@ var defaultStaticExts = []string{
 // defaultStaticExtsMiddlewareConfig 返回默认中间件配置
 func defaultStaticExtsMiddlewareConfig(root string) *StaticExtsMiddlewareConfig {
 	return &StaticExtsMiddlewareConfig{
-		Root:           root,
-		Prefix:         "",
-		StaticExts:     defaultStaticExts,
-		EnableCache:    true,
-		MaxCacheSize:   100 * 1024 * 1024, // 100MB
-		MaxCacheFiles:  500,
-		EnableGzip:     true,
-		GzipLevel:      1, // BestSpeed
-		UseETag:        true,
-		CacheControl:   "public, max-age=60",
-		HideDotFiles:   true,
-		EnableIndex:    true,
-		IndexFile:      "index.html",
+		Root:          root,
+		Prefix:        "",
+		StaticExts:    defaultStaticExts,
+		EnableCache:   true,
+		MaxCacheSize:  100 * 1024 * 1024, // 100MB
+		MaxCacheFiles: 500,
+		EnableGzip:    true,
+		GzipLevel:     1, // BestSpeed
+		BrotliLevel:   DefaultBrotliLevel,
+		UseETag:       true,
+		CacheControl:  "public, max-age=60",
+		HideDotFiles:  true,
+		IndexFile:     "index.html",
+		MaxURILength:  DefaultMaxURILength,
 	}
 }
 
@@ -336,6 +462,16 @@ func DisableMiddlewareCache() MiddlewareOption {
 	}
 }
 
+// WithMiddlewareCacheInstance 使用已有的缓存实例（同时启用缓存）
+// 多个中间件传入同一实例即可共享缓存，调用方持有该实例可查看统计或重置
+// 缓存键为清理后的请求路径，仅应在文件来源相同的中间件之间共享
+func WithMiddlewareCacheInstance(cache *Cache) MiddlewareOption {
+	return func(c *StaticExtsMiddlewareConfig) {
+		c.EnableCache = true
+		c.Cache = cache
+	}
+}
+
 // WithMiddlewareGzip 启用 Gzip
 func WithMiddlewareGzip(level int) MiddlewareOption {
 	return func(c *StaticExtsMiddlewareConfig) {
@@ -350,6 +486,24 @@ func WithMiddlewareGzip(level int) MiddlewareOption {
 	}
 }
 
+// WithMiddlewareBrotli 启用 Brotli，与引擎的 WithBrotli 一致
+// level: 压缩级别 0-11，超出范围时取边界值
+func WithMiddlewareBrotli(level int) MiddlewareOption {
+	level = GetBrotliLevel(level)
+	return func(c *StaticExtsMiddlewareConfig) {
+		c.EnableBrotli = true
+		c.BrotliLevel = level
+	}
+}
+
+// WithMiddlewareEncodingPriority 设置内容编码的服务端优先级，与引擎的 WithEncodingPriority 一致
+// 中间件实时生成 gzip 与 br，优先级中的其他编码会被跳过
+func WithMiddlewareEncodingPriority(encodings ...string) MiddlewareOption {
+	return func(c *StaticExtsMiddlewareConfig) {
+		c.EncodingPriority = encodings
+	}
+}
+
 // DisableMiddlewareGzip 禁用 Gzip
 func DisableMiddlewareGzip() MiddlewareOption {
 	return func(c *StaticExtsMiddlewareConfig) {
@@ -395,14 +549,14 @@ func WithMiddlewareShowDotFiles() MiddlewareOption {
 // WithMiddlewareIndex 启用 index.html 回退（访问 / 自动返回 index.html）
 func WithMiddlewareIndex() MiddlewareOption {
 	return func(c *StaticExtsMiddlewareConfig) {
-		c.EnableIndex = true
+		c.EnableIndex = Bool(true)
 	}
 }
 
 // DisableMiddlewareIndex 禁用 index.html 回退
 func DisableMiddlewareIndex() MiddlewareOption {
 	return func(c *StaticExtsMiddlewareConfig) {
-		c.EnableIndex = false
+		c.EnableIndex = Bool(false)
 	}
 }
 
@@ -427,6 +581,40 @@ func WithMiddlewareOnRequest(fn func(string) bool) MiddlewareOption {
 	}
 }
 
+// WithMiddlewareOnResponse 设置写入响应体后的回调
+// 客户端中途断开等写入失败时中止处理链，回调收到实际写入字节数与错误
+func WithMiddlewareOnResponse(fn OnResponseFunc) MiddlewareOption {
+	return func(c *StaticExtsMiddlewareConfig) {
+		c.OnResponse = fn
+	}
+}
+
+// WithMiddlewareMaxURILength 设置请求 URI 最大长度，超出时在路径处理前返回 414
+// n <= 0 表示不限制
+func WithMiddlewareMaxURILength(n int) MiddlewareOption {
+	return func(c *StaticExtsMiddlewareConfig) {
+		c.MaxURILength = n
+	}
+}
+
+// WithMiddlewarePassthroughOnDeny 拒绝的请求（目录遍历、隐藏文件、OnRequest 返回 false）
+// 调用 c.Next() 交给后续处理器统一处理，默认返回 JSON 403
+func WithMiddlewarePassthroughOnDeny() MiddlewareOption {
+	return func(c *StaticExtsMiddlewareConfig) {
+		c.PassthroughOnDeny = true
+	}
+}
+
+// denyMiddlewareRequest 拒绝请求：默认返回 403，启用透传时交给后续处理器
+func denyMiddlewareRequest(c *gin.Context, cfg *StaticExtsMiddlewareConfig) {
+	if cfg.PassthroughOnDeny {
+		c.Next()
+		return
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+	c.Abort()
+}
+
 // isStaticFile 检查路径是否匹配静态资源扩展名
 func isStaticFile(path string, exts []string) bool {
 	path = strings.ToLower(path)
@@ -439,6 +627,12 @@ func isStaticFile(path string, exts []string) bool {
 	return false
 }
 
+// normalizeRequestPath 规范化请求路径：合并连续斜杠并解析 . 与 ..
+// 结果始终以 / 开头，且不会越过根路径
+func normalizeRequestPath(p string) string {
+	return pathpkg.Clean("/" + p)
+}
+
 // StaticFileExtsMiddleware 创建静态文件扩展名中间件
 // 该中间件只处理以特定扩展名结尾的请求，解决与 API 路由冲突问题
 // 当文件不存在时，调用 c.Next() 继续传递请求
@@ -453,17 +647,22 @@ func StaticFileExtsMiddleware(root string, opts ...MiddlewareOption) gin.Handler
 		cfg.Root = filepath.Clean(cfg.Root)
 	}
 
-	// 创建缓存（如启用）
-	var cache *Cache
-	if cfg.EnableCache {
+	// 创建缓存（如启用且未传入共享实例）
+	cache := cfg.Cache
+	if cfg.EnableCache && cache == nil {
 		cache = NewCache(cfg.MaxCacheSize, cfg.MaxCacheFiles, cfg.OnCacheEvict)
 	}
 
 	return func(c *gin.Context) {
-		path := c.Request.URL.Path
+		if isURITooLong(c.Request, cfg.MaxURILength) {
+			c.AbortWithStatus(http.StatusRequestURITooLong)
+			return
+		}
+
+		path := normalizeRequestPath(c.Request.URL.Path)
 
 		// 检查是否为根路径，启用 index.html 回退
-		if cfg.EnableIndex && (path == "/" || path == "") {
+		if cfg.indexEnabled() && (path == "/" || path == "") {
 			path = "/" + cfg.IndexFile
 		}
 
@@ -482,27 +681,28 @@ func StaticFileExtsMiddleware(root string, opts ...MiddlewareOption) gin.Handler
 		// 安全检查：目录遍历防护
 		safe, cleanPath := IsPathTraversal(cfg.Root, path)
 		if !safe {
-			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
-			c.Abort()
+			denyMiddlewareRequest(c, cfg)
 			return
 		}
 
 		// 检查隐藏文件
 		if cfg.HideDotFiles && IsHiddenPath(cleanPath) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
-			c.Abort()
+			denyMiddlewareRequest(c, cfg)
 			return
 		}
 
 		// 请求前回调
 		if cfg.OnRequest != nil && !cfg.OnRequest(cleanPath) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
-			c.Abort()
+			denyMiddlewareRequest(c, cfg)
 			return
 		}
 
 		// 获取文件
 		data, modTime, etag, err := getMiddlewareFile(cfg, cache, cleanPath)
+		if err != nil && !isNotFound(err) {
+			serveMiddlewareReadError(c, err)
+			return
+		}
 		if err != nil {
 			// 文件不存在，调用 Next 继续传递
 			c.Next()
@@ -528,23 +728,54 @@ func StaticFileExtsMiddleware(root string, opts ...MiddlewareOption) gin.Handler
 			c.Header("Cache-Control", cfg.CacheControl)
 		}
 
-		// Gzip 压缩
-		data = applyMiddlewareGzip(c, data, cfg)
+		// 内容编码协商
+		data = applyMiddlewareEncoding(c, cache, cleanPath, data, cfg)
 
-		c.Status(http.StatusOK)
-		c.Header("Content-Type", mimeType)
+		// 所有响应头必须在写入状态码之前设置
 		c.Header("Content-Length", fmt.Sprintf("%d", len(data)))
-		c.Writer.Write(data)
+		c.Status(http.StatusOK)
+		writeMiddlewareBody(c, cfg, cleanPath, data)
 		c.Abort()
 	}
 }
 
+// serveMiddlewareReadError 文件存在但读取失败（权限不足、IO 错误等）时返回 JSON 500，不再交给后续处理器
+func serveMiddlewareReadError(c *gin.Context, err error) {
+	c.Error(err)
+	c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+}
+
+// writeMiddlewareBody 写入响应体，写入失败（如客户端中途断开）时记录到 c.Errors
+func writeMiddlewareBody(c *gin.Context, cfg *StaticExtsMiddlewareConfig, path string, data []byte) {
+	n, err := writeFull(c.Writer, data)
+	if err != nil {
+		c.Error(err)
+	}
+	if cfg.OnResponse != nil {
+		source := SourceDisk
+		if cfg.EmbedFS != nil {
+			source = SourceEmbed
+		}
+		cfg.OnResponse(&ResponseInfo{
+			Path:     path,
+			Status:   c.Writer.Status(),
+			Size:     len(data),
+			Written:  n,
+			Encoding: c.Writer.Header().Get("Content-Encoding"),
+			Source:   source,
+			Err:      err,
+		})
+	}
+}
+
 // getMiddlewareFile 获取文件内容（支持缓存）
 func getMiddlewareFile(cfg *StaticExtsMiddlewareConfig, cache *Cache, path string) ([]byte, time.Time, string, error) {
 	// 尝试从缓存获取
 	if cfg.EnableCache && cache != nil {
-		if entry, ok := cache.Get(path); ok {
-			return entry.Data, entry.ModTime, entry.ETag, nil
+		if entry, ok := cache.acquire(path); ok {
+			data, modTime, etag := entry.Data, entry.ModTime, entry.ETag
+			cache.release(entry)
+			return data, modTime, etag, nil
 		}
 	}
 
@@ -566,12 +797,11 @@ func getMiddlewareFile(cfg *StaticExtsMiddlewareConfig, cache *Cache, path strin
 
 	// 缓存（如启用）
 	if cfg.EnableCache && cache != nil {
-		entry := &cacheEntry{
-			Data:    data,
-			ModTime: modTime,
-			Size:    int64(len(data)),
-			ETag:    etag,
-		}
+		entry := newPooledEntry()
+		entry.Data = data
+		entry.ModTime = modTime
+		entry.Size = int64(len(data))
+		entry.ETag = etag
 
 		// 压缩（如启用）
 		if cfg.EnableGzip && len(data) >= 1024 {
@@ -580,6 +810,12 @@ func getMiddlewareFile(cfg *StaticExtsMiddlewareConfig, cache *Cache, path strin
 				entry.Gzipped = gzData
 			}
 		}
+		if cfg.EnableBrotli && len(data) >= 1024 {
+			brData, brErr := BrotliCompress(data, cfg.BrotliLevel)
+			if brErr == nil {
+				entry.Brotli = brData
+			}
+		}
 
 		cache.Set(path, entry)
 	}
@@ -606,64 +842,12 @@ func getMiddlewareOSFile(cfg *StaticExtsMiddlewareConfig, path string) ([]byte,
 
 // getMiddlewareEmbedFile 从 embed.FS 读取文件
 func getMiddlewareEmbedFile(cfg *StaticExtsMiddlewareConfig, path string) ([]byte, time.Time, string, error) {
-	// 移除前导斜杠
-	path = strings.TrimPrefix(path, "/")
-
-	// 加上 EmbedRoot 前缀
-	if cfg.EmbedRoot != "" {
-		path = cfg.EmbedRoot + "/" + path
-	}
-
-	var data []byte
-	var info os.FileInfo
-	var err error
-
-	// 尝试不同的 FS 类型
-	type fsOpener interface {
-		Open(name string) (fs2.File, error)
-	}
-
-	if f, ok := cfg.EmbedFS.(fsOpener); ok {
-		file, openErr := f.Open(path)
-		if openErr != nil {
-			return nil, time.Time{}, "", openErr
-		}
-		defer file.Close()
-
-		info, err = file.Stat()
-		if err != nil {
-			return nil, time.Time{}, "", err
-		}
-
-		data, err = io.ReadAll(file)
-		if err != nil {
-			return nil, time.Time{}, "", err
-		}
-	} else if httpFS, ok := cfg.EmbedFS.(http.FileSystem); ok {
-		file, openErr := httpFS.Open(path)
-		if openErr != nil {
-			return nil, time.Time{}, "", openErr
-		}
-		defer file.Close()
-
-		info, err = file.Stat()
-		if err != nil {
-			return nil, time.Time{}, "", err
-		}
-
-		data, err = io.ReadAll(file)
-		if err != nil {
-			return nil, time.Time{}, "", err
-		}
-	} else {
-		return nil, time.Time{}, "", fmt.Errorf("unsupported file system type")
-	}
-
-	if info.IsDir() {
-		return nil, time.Time{}, "", os.ErrNotExist
+	data, info, name, err := readEmbedFile(cfg.EmbedFS, cfg.EmbedRoot, path)
+	if err != nil {
+		return nil, time.Time{}, "", err
 	}
 
-	etag := generateMiddlewareETagEmbed(path, info)
+	etag := generateMiddlewareETagEmbed(name, info)
 	return data, info.ModTime(), etag, nil
 }
 
@@ -684,8 +868,7 @@ func generateMiddlewareETagEmbed(path string, info os.FileInfo) string {
 // checkMiddlewareNotModified 检查条件请求
 func checkMiddlewareNotModified(c *gin.Context, modTime time.Time, etag string, useETag bool) bool {
 	if useETag {
-		ifNoneMatch := c.GetHeader("If-None-Match")
-		if ifNoneMatch != "" && ifNoneMatch == etag {
+		if matchETag(c.GetHeader("If-None-Match"), etag) {
 			c.Status(http.StatusNotModified)
 			return true
 		}
@@ -703,33 +886,80 @@ func checkMiddlewareNotModified(c *gin.Context, modTime time.Time, etag string,
 	return false
 }
 
-// applyMiddlewareGzip 应用 Gzip 压缩
-func applyMiddlewareGzip(c *gin.Context, data []byte, cfg *StaticExtsMiddlewareConfig) []byte {
-	if !cfg.EnableGzip || len(data) < 1024 {
+// applyMiddlewareEncoding 按 Accept-Encoding 协商内容编码并压缩
+// 与引擎共用 negotiateEncoding，按 EncodingPriority 依次尝试；实时生成 gzip 与 br，
+// zstd 与 zstd 字典编码在引擎中只来自预压缩文件或字典配置，中间件同样跳过，协商结果与默认引擎一致
+func applyMiddlewareEncoding(c *gin.Context, cache *Cache, path string, data []byte, cfg *StaticExtsMiddlewareConfig) []byte {
+	if (!cfg.EnableGzip && !cfg.EnableBrotli) || len(data) < 1024 {
 		return data
 	}
 
-	acceptEncoding := c.GetHeader("Accept-Encoding")
-	if !containsEncoding(acceptEncoding, "gzip") {
-		return data
+	priority := cfg.EncodingPriority
+	if len(priority) == 0 {
+		priority = DefaultEncodingPriority
+	}
+	for _, encoding := range negotiateEncoding(c.GetHeader("Accept-Encoding"), priority) {
+		if encoded, ok := middlewareEncodeData(cache, path, data, cfg, encoding); ok {
+			c.Header("Content-Encoding", encoding)
+			c.Header("Vary", "Accept-Encoding")
+			return encoded
+		}
 	}
 
+	return data
+}
+
+// middlewareEncodeData 返回 data 的 encoding 编码版本，该编码未启用或不支持时返回 false
+func middlewareEncodeData(cache *Cache, path string, data []byte, cfg *StaticExtsMiddlewareConfig, encoding string) ([]byte, bool) {
+	switch encoding {
+	case "gzip":
+		if cfg.EnableGzip {
+			return middlewareGzipData(cache, path, data, cfg)
+		}
+	case "br":
+		if cfg.EnableBrotli {
+			return middlewareBrotliData(cache, path, data, cfg)
+		}
+	}
+	return nil, false
+}
+
+// middlewareGzipData 返回 data 的 gzip 版本
+// 缓存条目已有压缩版本（如 PrecompressFS 预热）时直接使用
+func middlewareGzipData(cache *Cache, path string, data []byte, cfg *StaticExtsMiddlewareConfig) ([]byte, bool) {
 	// 尝试从缓存获取压缩数据
-	if cfg.EnableCache {
-		// 简化处理，直接压缩
+	if cfg.EnableCache && cache != nil {
+		if entry, ok := cache.acquire(path); ok {
+			gzData := entry.Gzipped
+			cache.release(entry)
+			if gzData != nil && len(gzData) < len(data) {
+				return gzData, true
+			}
+		}
 	}
 
 	gzData, err := GzipCompress(data, cfg.GzipLevel)
-	if err == nil && len(gzData) < len(data) {
-		c.Header("Content-Encoding", "gzip")
-		c.Header("Vary", "Accept-Encoding")
-		return gzData
+	return gzData, err == nil && len(gzData) < len(data)
+}
+
+// middlewareBrotliData 返回 data 的 Brotli 版本，缓存条目已有 Brotli 版本时直接使用
+func middlewareBrotliData(cache *Cache, path string, data []byte, cfg *StaticExtsMiddlewareConfig) ([]byte, bool) {
+	if cfg.EnableCache && cache != nil {
+		if entry, ok := cache.acquire(path); ok {
+			brData := entry.Brotli
+			cache.release(entry)
+			if brData != nil && len(brData) < len(data) {
+				return brData, true
+			}
+		}
 	}
 
-	return data
+	brData, err := BrotliCompress(data, cfg.BrotliLevel)
+	return brData, err == nil && len(brData) < len(data)
 }
 
 // NewStaticFileExtsMiddlewareWithConfig 使用配置创建中间件
+// 布尔字段按结构体中的值生效（零值即关闭），EnableIndex 未设置（nil）时默认启用 index.html 回退
 func NewStaticFileExtsMiddlewareWithConfig(cfg *StaticExtsMiddlewareConfig) gin.HandlerFunc {
 	// 确保 Root 是绝对路径
 	if cfg.Root != "" {
@@ -741,25 +971,26 @@ func NewStaticFileExtsMiddlewareWithConfig(cfg *StaticExtsMiddlewareConfig) gin.
 		cfg.StaticExts = defaultStaticExts
 	}
 
-	// 创建缓存（如启用）
-	var cache *Cache
-	if cfg.EnableCache {
+	// 创建缓存（如启用且未传入共享实例）
+	cache := cfg.Cache
+	if cfg.EnableCache && cache == nil {
 		cache = NewCache(cfg.MaxCacheSize, cfg.MaxCacheFiles, cfg.OnCacheEvict)
 	}
 
-	// 如果没有设置 EnableIndex，默认启用
-	if !cfg.EnableIndex {
-		cfg.EnableIndex = true
-	}
 	if cfg.IndexFile == "" {
 		cfg.IndexFile = "index.html"
 	}
 
 	return func(c *gin.Context) {
-		path := c.Request.URL.Path
+		if isURITooLong(c.Request, cfg.MaxURILength) {
+			c.AbortWithStatus(http.StatusRequestURITooLong)
+			return
+		}
+
+		path := normalizeRequestPath(c.Request.URL.Path)
 
 		// 检查是否为根路径，启用 index.html 回退
-		if cfg.EnableIndex && (path == "/" || path == "") {
+		if cfg.indexEnabled() && (path == "/" || path == "") {
 			path = "/" + cfg.IndexFile
 		}
 
@@ -778,27 +1009,28 @@ func NewStaticFileExtsMiddlewareWithConfig(cfg *StaticExtsMiddlewareConfig) gin.
 		// 安全检查
 		safe, cleanPath := IsPathTraversal(cfg.Root, path)
 		if !safe {
-			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
-			c.Abort()
+			denyMiddlewareRequest(c, cfg)
 			return
 		}
 
 		// 检查隐藏文件
 		if cfg.HideDotFiles && IsHiddenPath(cleanPath) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
-			c.Abort()
+			denyMiddlewareRequest(c, cfg)
 			return
 		}
 
 		// 请求前回调
 		if cfg.OnRequest != nil && !cfg.OnRequest(cleanPath) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
-			c.Abort()
+			denyMiddlewareRequest(c, cfg)
 			return
 		}
 
 		// 获取文件
 		data, modTime, etag, err := getMiddlewareFile(cfg, cache, cleanPath)
+		if err != nil && !isNotFound(err) {
+			serveMiddlewareReadError(c, err)
+			return
+		}
 		if err != nil {
 			c.Next()
 			return
@@ -822,13 +1054,13 @@ func NewStaticFileExtsMiddlewareWithConfig(cfg *StaticExtsMiddlewareConfig) gin.
 			c.Header("Cache-Control", cfg.CacheControl)
 		}
 
-		// Gzip 压缩
-		data = applyMiddlewareGzip(c, data, cfg)
+		// 内容编码协商
+		data = applyMiddlewareEncoding(c, cache, cleanPath, data, cfg)
 
-		c.Status(http.StatusOK)
-		c.Header("Content-Type", mimeType)
+		// 所有响应头必须在写入状态码之前设置
 		c.Header("Content-Length", fmt.Sprintf("%d", len(data)))
-		c.Writer.Write(data)
+		c.Status(http.StatusOK)
+		writeMiddlewareBody(c, cfg, cleanPath, data)
 		c.Abort()
 	}
 }
@@ -1,9 +1,19 @@
 package ginstatic
 
 import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/gin-gonic/gin"
 )
@@ -128,6 +138,40 @@ func TestStaticFileExtsMiddleware_WithPrefix(t *testing.T) {
 	}
 }
 
+func TestStaticFileExtsMiddleware_NormalizePath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(StaticFileExtsMiddleware("./testdata/static", WithMiddlewarePrefix("/static")))
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"规范路径", "/static/app.js"},
+		{"前缀内双斜杠", "/static//app.js"},
+		{"前导双斜杠", "//static/app.js"},
+		{"包含点段", "/static/./app.js"},
+		{"包含上级段", "/static/js/../app.js"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/", nil)
+			req.URL.Path = tt.path
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Errorf("path %q: expected status 200, got %d", tt.path, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/javascript; charset=utf-8" {
+				t.Errorf("path %q: expected javascript Content-Type, got %s", tt.path, ct)
+			}
+		})
+	}
+}
+
 func TestStaticFileExtsMiddleware_PathTraversal(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -198,6 +242,52 @@ func TestStaticFileExtsMiddleware_CustomExts(t *testing.T) {
 	}
 }
 
+func TestStaticFileExtsMiddleware_SharedCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cache := NewCache(10*1024*1024, 100, nil)
+
+	// 第二个中间件的根目录为空目录，只能从共享缓存中获取文件
+	r := gin.New()
+	r.Use(StaticFileExtsMiddleware("./testdata/static",
+		WithMiddlewarePrefix("/a"), WithMiddlewareCacheInstance(cache)))
+	r.Use(StaticFileExtsMiddleware(t.TempDir(),
+		WithMiddlewarePrefix("/b"), WithMiddlewareCacheInstance(cache)))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/a/app.js", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if cache.FileCount() != 1 {
+		t.Fatalf("Expected 1 cached file, got %d", cache.FileCount())
+	}
+
+	body := w.Body.String()
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/b/app.js", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 from shared cache, got %d", w.Code)
+	}
+	if w.Body.String() != body {
+		t.Errorf("Expected shared cache body %q, got %q", body, w.Body.String())
+	}
+
+	// 重置共享缓存后，第二个中间件找不到文件
+	cache.Clear()
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/b/app.js", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 after reset, got %d", w.Code)
+	}
+}
+
 func TestStaticFileExtsMiddleware_CacheControl(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -241,6 +331,42 @@ func TestStaticFileExtsMiddleware_ETag(t *testing.T) {
 	}
 }
 
+func TestStaticFileExtsMiddleware_IfNoneMatchList(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(StaticFileExtsMiddleware("./testdata/static"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/app.js", nil)
+	r.ServeHTTP(w, req)
+	etag := w.Header().Get("ETag")
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{"多值包含当前 ETag", `"a", "b", ` + etag, http.StatusNotModified},
+		{"通配符", "*", http.StatusNotModified},
+		{"弱 ETag", "W/" + etag, http.StatusNotModified},
+		{"多值不包含当前 ETag", `"a", "b"`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/app.js", nil)
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
 func TestStaticFileExtsMiddleware_IndexFallback(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -261,3 +387,562 @@ func TestStaticFileExtsMiddleware_IndexFallback(t *testing.T) {
 		t.Errorf("Expected Content-Type text/html, got %s", contentType)
 	}
 }
+
+func TestRequestIDMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var logBuf bytes.Buffer
+	log.SetOutput(&logBuf)
+	defer log.SetOutput(os.Stderr)
+
+	r := gin.New()
+	r.Use(RequestIDMiddleware(""), RequestLoggerMiddleware(""))
+	r.GET("/ok", func(c *gin.Context) {
+		c.String(http.StatusOK, GetRequestID(c))
+	})
+
+	// 请求携带 ID：原样回显并写入日志
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/missing", nil)
+	req.Header.Set("X-Request-ID", "req-123")
+	r.ServeHTTP(w, req)
+
+	if got := w.Header().Get("X-Request-ID"); got != "req-123" {
+		t.Errorf("Expected X-Request-ID 'req-123', got %q", got)
+	}
+	if !strings.Contains(logBuf.String(), "req-123") {
+		t.Errorf("Expected log to contain request id, got %q", logBuf.String())
+	}
+
+	// 请求未携带 ID：自动生成，并可从上下文获取
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/ok", nil)
+	r.ServeHTTP(w, req)
+
+	generated := w.Header().Get("X-Request-ID")
+	if len(generated) != 32 {
+		t.Errorf("Expected generated 32-char request id, got %q", generated)
+	}
+	if w.Body.String() != generated {
+		t.Errorf("Expected context request id %q, got %q", generated, w.Body.String())
+	}
+}
+
+func TestNewStaticFileExtsMiddlewareWithConfig_IndexDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		enableIndex *bool
+		wantStatus  int
+	}{
+		{"显式禁用回退", Bool(false), http.StatusNotFound},
+		{"显式启用回退", Bool(true), http.StatusOK},
+		{"未设置时默认回退", nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(NewStaticFileExtsMiddlewareWithConfig(&StaticExtsMiddlewareConfig{
+				Root:        "./testdata/static",
+				EnableIndex: tt.enableIndex,
+			}))
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/", nil)
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestStaticFileExtsMiddleware_PassthroughOnDeny(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	deny := WithMiddlewareOnRequest(func(path string) bool { return false })
+
+	tests := []struct {
+		name       string
+		opts       []MiddlewareOption
+		wantStatus int
+		wantBody   string
+	}{
+		{"默认返回 403", []MiddlewareOption{deny}, http.StatusForbidden, `{"error":"forbidden"}`},
+		{"透传到后续处理器", []MiddlewareOption{deny, WithMiddlewarePassthroughOnDeny()}, http.StatusTeapot, "downstream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(StaticFileExtsMiddleware("./testdata/static", tt.opts...))
+			r.NoRoute(func(c *gin.Context) {
+				c.String(http.StatusTeapot, "downstream")
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/app.js", nil)
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("Expected body %q, got %q", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestStaticFileExtsMiddleware_GzipHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	root := t.TempDir()
+	content := bytes.Repeat([]byte("console.log('gzip');\n"), 100)
+	if err := os.WriteFile(root+"/large.js", content, 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	// 使用真实 HTTP 服务器，确认响应头随响应实际发出
+	r := gin.New()
+	r.Use(StaticFileExtsMiddleware(root))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"/large.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("Expected Content-Encoding gzip, got %q", resp.Header.Get("Content-Encoding"))
+	}
+	if resp.Header.Get("ETag") == "" {
+		t.Error("Expected ETag header")
+	}
+	if resp.Header.Get("Content-Length") != strconv.Itoa(len(body)) {
+		t.Errorf("Expected Content-Length %d, got %q", len(body), resp.Header.Get("Content-Length"))
+	}
+
+	decoded, err := GzipDecompress(body)
+	if err != nil {
+		t.Fatalf("failed to decompress body: %v", err)
+	}
+	if !bytes.Equal(decoded, content) {
+		t.Error("Expected decompressed body to equal original content")
+	}
+}
+
+func TestStaticFileExtsMiddleware_MaxURILength(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(StaticFileExtsMiddleware("./testdata/static", WithMiddlewareMaxURILength(64)))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/"+strings.Repeat("a", 100)+".js", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestURITooLong {
+		t.Errorf("Expected status 414, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/app.js", nil)
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestStaticFileExtsMiddleware_EmbedRoot(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	assets := fstest.MapFS{
+		"dist/app.js":        {Data: []byte("console.log('embed')")},
+		"dist/css/style.css": {Data: []byte("body{}")},
+		"other.js":           {Data: []byte("outside")},
+	}
+
+	tests := []struct {
+		name       string
+		embedRoot  string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"根目录文件", "dist", "/app.js", http.StatusOK, "console.log('embed')"},
+		{"嵌套目录", "dist", "/css/style.css", http.StatusOK, "body{}"},
+		{"根目录带斜杠", "dist/", "/app.js", http.StatusOK, "console.log('embed')"},
+		{"根目录外的文件", "dist", "/other.js", http.StatusNotFound, ""},
+		{"无根目录", "", "/other.js", http.StatusOK, "outside"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(StaticFileExtsMiddleware("", WithMiddlewareEmbedFS(assets, tt.embedRoot)))
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("Expected body %q, got %q", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestStaticFileExtsMiddleware_PrecompressedCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	content := []byte(strings.Repeat("console.log('precompressed');\n", 100))
+	assets := fstest.MapFS{
+		"dist/app.js": {Data: content},
+	}
+
+	// 预热时使用与中间件不同的压缩级别，以区分响应来自缓存还是现场压缩
+	cache, err := PrecompressFS(assets, "dist", 1)
+	if err != nil {
+		t.Fatalf("PrecompressFS failed: %v", err)
+	}
+	entry, ok := cache.Get("/app.js")
+	if !ok {
+		t.Fatal("Expected /app.js to be precompressed")
+	}
+
+	r := gin.New()
+	r.Use(StaticFileExtsMiddleware("",
+		WithMiddlewareEmbedFS(assets, "dist"),
+		WithMiddlewareCacheInstance(cache),
+		WithMiddlewareGzip(9),
+	))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/app.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected Content-Encoding gzip, got %q", w.Header().Get("Content-Encoding"))
+	}
+	if !bytes.Equal(w.Body.Bytes(), entry.Gzipped) {
+		t.Error("Expected response to use precompressed cache entry")
+	}
+	if w.Header().Get("ETag") != entry.ETag {
+		t.Errorf("Expected ETag %s, got %s", entry.ETag, w.Header().Get("ETag"))
+	}
+}
+
+// TestStaticFileExtsMiddleware_TruncatedWrite 测试客户端中途断开时报告截断
+func TestStaticFileExtsMiddleware_TruncatedWrite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	assets := fstest.MapFS{
+		"dist/data.txt": {Data: content},
+	}
+
+	var got *ResponseInfo
+	var ginErrs int
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		ginErrs = len(c.Errors)
+	})
+	r.Use(StaticFileExtsMiddleware("",
+		WithMiddlewareEmbedFS(assets, "dist"),
+		WithMiddlewareOnResponse(func(info *ResponseInfo) {
+			got = info
+		}),
+	))
+
+	w := &truncatingWriter{ResponseRecorder: httptest.NewRecorder(), limit: 1024}
+	req, _ := http.NewRequest("GET", "/data.txt", nil)
+	r.ServeHTTP(w, req)
+
+	if got == nil {
+		t.Fatal("Expected OnResponse to be called")
+	}
+	if got.Written != 1024 || got.Size != len(content) {
+		t.Errorf("Expected 1024 of %d bytes written, got %d of %d", len(content), got.Written, got.Size)
+	}
+	if !got.Truncated() || got.Err == nil {
+		t.Errorf("Expected truncated response with error, got err=%v", got.Err)
+	}
+	if ginErrs != 1 {
+		t.Errorf("Expected write error recorded in c.Errors, got %d errors", ginErrs)
+	}
+}
+
+// TestStaticFileExtsMiddleware_OnResponseSource 测试 OnResponse 按文件来源报告 Source
+func TestStaticFileExtsMiddleware_OnResponseSource(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/app.js", []byte("disk"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	assets := fstest.MapFS{"dist/app.js": {Data: []byte("embed")}}
+
+	tests := []struct {
+		name   string
+		opts   []MiddlewareOption
+		root   string
+		source string
+	}{
+		{"磁盘", nil, dir, SourceDisk},
+		{"嵌入", []MiddlewareOption{WithMiddlewareEmbedFS(assets, "dist")}, "", SourceEmbed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *ResponseInfo
+			opts := append(tt.opts, WithMiddlewareOnResponse(func(info *ResponseInfo) {
+				got = info
+			}))
+			r := gin.New()
+			r.Use(StaticFileExtsMiddleware(tt.root, opts...))
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/app.js", nil)
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d", w.Code)
+			}
+			if got == nil {
+				t.Fatal("Expected OnResponse to be called")
+			}
+			if got.Source != tt.source {
+				t.Errorf("Expected source %q, got %q", tt.source, got.Source)
+			}
+		})
+	}
+}
+
+// TestStaticFileExtsMiddleware_ReadError 测试读取失败返回 500，文件不存在交给后续处理器
+func TestStaticFileExtsMiddleware_ReadError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	assets := errorFS{
+		FS:   fstest.MapFS{"dist/secret.txt": {Data: []byte("secret")}},
+		errs: map[string]error{"dist/secret.txt": fs.ErrPermission},
+	}
+
+	r := gin.New()
+	r.Use(StaticFileExtsMiddleware("", WithMiddlewareEmbedFS(assets, "dist")))
+	r.NoRoute(func(c *gin.Context) {
+		c.String(http.StatusNotFound, "next")
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"文件不存在", "/missing.txt", http.StatusNotFound, "next"},
+		{"权限不足", "/secret.txt", http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("Expected body %q, got %q", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestStaticFileExtsMiddleware_QueryStringCacheKey 测试查询串不影响缓存键
+func TestStaticFileExtsMiddleware_QueryStringCacheKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	assets := fstest.MapFS{"dist/app.js": {Data: []byte("console.log('v');")}}
+	cache := NewCache(1<<20, 10, nil)
+
+	r := gin.New()
+	r.Use(StaticFileExtsMiddleware("",
+		WithMiddlewareEmbedFS(assets, "dist"),
+		WithMiddlewareCacheInstance(cache),
+	))
+
+	for _, target := range []string{"/app.js?v=1", "/app.js?v=2"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", target, nil)
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: Expected status 200, got %d", target, w.Code)
+		}
+	}
+
+	if n := cache.FileCount(); n != 1 {
+		t.Errorf("Expected 1 cache entry, got %d", n)
+	}
+}
+
+// TestStaticFileExtsMiddleware_EncodingParity 测试相同 Accept-Encoding 下中间件与引擎协商结果一致
+func TestStaticFileExtsMiddleware_EncodingParity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	content := strings.Repeat("console.log('parity');\n", 200)
+	if err := os.WriteFile(dir+"/app.js", []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 引擎未启用预压缩时不读取 .zst 文件，zstd 与中间件一样被跳过
+	zstData, err := ZstdCompress([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/app.js.zst", zstData, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	priorities := map[string][]string{
+		"默认优先级":     nil,
+		"不含 gzip":   {"br", "zstd"},
+		"gzip 排在末尾": {"zstd", "br", "gzip"},
+		"gzip 优先":   {"gzip", "br"},
+	}
+	accepts := []string{
+		"",
+		"gzip",
+		"gzip;q=0",
+		"br",
+		"br, gzip",
+		"br, zstd, gzip",
+		"br;q=1, gzip;q=0.5",
+		"br;q=0.5, gzip",
+		"zstd",
+		"zstd, gzip;q=0.5",
+		"zstd, br",
+		"zstd;q=1, br;q=0.9, gzip;q=0.1",
+		"deflate",
+		"identity, gzip;q=0.1",
+	}
+
+	for name, priority := range priorities {
+		for _, brotli := range []bool{false, true} {
+			engineOpts := []Option{WithEncodingPriority(priority...)}
+			middlewareOpts := []MiddlewareOption{WithMiddlewareEncodingPriority(priority...)}
+			if brotli {
+				engineOpts = append(engineOpts, WithBrotli(DefaultBrotliLevel))
+				middlewareOpts = append(middlewareOpts, WithMiddlewareBrotli(DefaultBrotliLevel))
+			}
+			assertEncodingParity(t, dir, fmt.Sprintf("%s brotli=%v", name, brotli), engineOpts, middlewareOpts, accepts)
+		}
+	}
+}
+
+// assertEncodingParity 对每个 Accept-Encoding 分别请求引擎与中间件，断言协商出的 Content-Encoding 一致
+func assertEncodingParity(t *testing.T, dir, name string, engineOpts []Option, middlewareOpts []MiddlewareOption, accepts []string) {
+	t.Helper()
+
+	engineRouter := gin.New()
+	New(engineRouter, dir, engineOpts...)
+
+	middlewareRouter := gin.New()
+	middlewareRouter.Use(StaticFileExtsMiddleware(dir, middlewareOpts...))
+
+	for _, accept := range accepts {
+		var got []string
+		for _, r := range []*gin.Engine{engineRouter, middlewareRouter} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/app.js", nil)
+			if accept != "" {
+				req.Header.Set("Accept-Encoding", accept)
+			}
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s %q: Expected status 200, got %d", name, accept, w.Code)
+			}
+			got = append(got, w.Header().Get("Content-Encoding"))
+		}
+		if got[0] != got[1] {
+			t.Errorf("%s %q: Expected identical encoding, engine %q, middleware %q", name, accept, got[0], got[1])
+		}
+	}
+}
+
+func TestHTTPSRedirectMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(port int, trusted ...string) *gin.Engine {
+		r := gin.New()
+		r.Use(HTTPSRedirectMiddleware(port, trusted...))
+		r.GET("/a", func(c *gin.Context) {
+			c.String(http.StatusOK, "ok")
+		})
+		return r
+	}
+
+	tests := []struct {
+		name     string
+		port     int
+		trusted  []string
+		host     string
+		remote   string
+		proto    string
+		tls      bool
+		status   int
+		location string
+	}{
+		{name: "HTTP 请求重定向到指定端口", port: 8443, host: "example.com:8080", status: http.StatusMovedPermanently, location: "https://example.com:8443/a?b=1"},
+		{name: "443 端口省略", port: 443, host: "example.com", status: http.StatusMovedPermanently, location: "https://example.com/a?b=1"},
+		{name: "IPv6 主机", port: 443, host: "[::1]:8080", status: http.StatusMovedPermanently, location: "https://[::1]/a?b=1"},
+		{name: "TLS 请求直接放行", port: 443, host: "example.com", tls: true, status: http.StatusOK},
+		{name: "可信代理声明 https 放行", port: 443, trusted: []string{"10.0.0.0/8"}, host: "example.com", remote: "10.1.2.3:1234", proto: "https", status: http.StatusOK},
+		{name: "不可信来源的 X-Forwarded-Proto 被忽略", port: 443, trusted: []string{"10.0.0.1"}, host: "example.com", remote: "203.0.113.9:1234", proto: "https", status: http.StatusMovedPermanently, location: "https://example.com/a?b=1"},
+		{name: "未配置可信代理时忽略 X-Forwarded-Proto", port: 443, host: "example.com", remote: "10.1.2.3:1234", proto: "https", status: http.StatusMovedPermanently, location: "https://example.com/a?b=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/a?b=1", nil)
+			req.Host = tt.host
+			if tt.remote != "" {
+				req.RemoteAddr = tt.remote
+			}
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			newRouter(tt.port, tt.trusted...).ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			if got := w.Header().Get("Location"); got != tt.location {
+				t.Errorf("Expected Location %q, got %q", tt.location, got)
+			}
+		})
+	}
+}
@@ -2,7 +2,11 @@ package ginstatic
 
 import (
 	"compress/gzip"
+	"net/http"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -23,35 +27,134 @@ type Config struct {
 	MaxCacheSize  int64 // 最大缓存大小（字节），默认 100MB
 	MaxCacheFiles int   // 最大缓存文件数，默认 500
 
+	EvictionPolicy EvictionPolicy // 缓存淘汰策略，默认 nil 表示使用 LRU 淘汰最久未访问的条目
+
+	CachePersistenceDir string // 缓存持久化目录，启动时导入、Close 时导出，默认为空表示不持久化
+
+	StaleWindow time.Duration // 缓存重置后过期条目可继续服务的时长，期间每个文件只有一个后台刷新，默认 0 表示直接清空
+
+	CacheTTL        time.Duration // 缓存条目的有效期，过期后重新加载（启用 CacheRevalidate 时先校验），默认 0 表示永不过期
+	CacheRevalidate bool          // 命中（或 TTL 过期）时通过 os.Stat 检查磁盘文件是否变化，变化时重新加载，默认 false
+
+	EnableWatch   bool          // 监听 Root 目录树，文件变化时淘汰对应的缓存条目，默认 false
+	WatchDebounce time.Duration // 文件监听的防抖时长，默认 0 表示使用 DefaultWatchDebounce
+
+	StreamThreshold int64 // 磁盘文件不小于该大小（字节）时流式返回，不读入内存也不缓存，默认 0 表示不启用
+
 	// 压缩配置
 	EnableGzip      bool // 是否启用 Gzip 压缩，默认 true
 	GzipLevel       int  // Gzip 压缩级别 (1-9)，默认 gzip.BestSpeed
 	CompressMinSize int  // 最小压缩大小，默认 1024 字节
 
+	EnableBrotli bool // 是否启用 Brotli 压缩，默认 false
+	BrotliLevel  int  // Brotli 压缩级别 (0-11)，默认 DefaultBrotliLevel
+
+	EnablePrecompressed    bool // 优先使用同目录的 .br/.zst/.gz 预压缩文件，默认 false
+	EnableCompressionStats bool // 统计各编码的压缩响应数与节省字节数，默认 false
+
+	PrecompressedEncodings []PrecompressedEncoding // 预压缩文件扩展名与 Content-Encoding 的对应关系，按优先级排列，为空时使用默认对应关系
+
+	ZstdDictionary []byte // zstd 压缩字典，设置后对声明 zstd-dict 编码的客户端使用字典压缩
+
+	EncodingPriority []string // 内容编码的服务端优先级，为空时使用 DefaultEncodingPriority
+
+	RangeOverEncoded bool // 压缩响应的 Range 按编码后的字节计算，返回 206 与编码后长度的 Content-Range，默认 false
+
 	// SPA 支持
 	EnableSPA   bool   // 是否启用 SPA 回退，默认 false
 	IndexFile   string // index.html 路径，默认 "index.html"
 	SPAFallback bool   // 是否在文件不存在时回退到 index.html
 
 	// 安全配置
-	HideDotFiles bool // 是否隐藏点文件，默认 true
+	HideDotFiles   bool // 是否隐藏点文件，默认 true
+	MaxURILength   int  // 请求 URI 最大长度，超出返回 414，默认 2048，0 表示不限制
+	HideSourceMaps bool // .map 请求一律返回 404，默认 false
+	MaxRanges      int  // 单个请求 Range 头允许的最大区间数，超出返回 416，默认 16，0 表示不限制
+
+	Checksums map[string]string // 文件完整性清单（请求路径 -> sha256 十六进制），加载时校验，不一致的文件拒绝服务
+
+	ContentAddressed map[string]string // 内容寻址清单（请求路径 -> sha256 十六进制），文件可通过 /<目录>/<sha256>/<文件名> 访问
+
+	StripBOM bool // 加载文本类型文件时去除开头的 UTF-8 BOM，默认 false
+
+	// 语言协商
+	EnableLanguageNegotiation bool     // 按 Accept-Language 返回 HTML 的语言版本，默认 false
+	DefaultLanguage           string   // 不支持客户端语言时使用的默认语言
+	SupportedLanguages        []string // 支持的语言标签，如 "en"、"zh"
+
+	// 图片格式协商
+	EnableImageNegotiation bool // 按 Accept 为 jpg/png/gif 返回同名 .avif/.webp 文件，默认 false
+
+	// 方法限制
+	EnableMethodNotAllowed bool // 对已存在资源的非 GET/HEAD/OPTIONS 请求返回 405，默认 false
 
 	// 缓存控制
 	CacheControl string // 缓存控制头，默认 "public, max-age=60"
+	NoCacheHTML  bool   // .html/.htm 响应始终使用 Cache-Control: no-cache，默认 false
 	UseETag      bool   // 是否使用 ETag，默认 true
 
+	PrivateCacheWhen func(c *gin.Context) bool // 返回 true 的请求（如已认证）将 Cache-Control 中的 public 替换为 private
+
+	EncodingETagSuffix bool // 压缩响应的 ETag 追加编码后缀（如 "abc-gzip"），默认 false
+
+	ETagProvider func(relPath string) (string, bool) // 外部提供的 ETag（如 CDN 上游计算的规范 ETag），返回 false 时使用内置计算
+
+	DebugHeaders   bool // 响应携带 X-Served-From 调试头，标明文件来源，默认 false
+	MinimalHeaders bool // 只设置 Content-Type、Content-Length 与 Content-Encoding，其余响应头交给外部中间件，默认 false
+
 	// 性能配置
 	PreloadOnStart bool // 启动时预加载文件到缓存，默认 false
-	ReadTimeout    int  // 读取超时（毫秒），默认 0 表示不限制
-	WriteTimeout   int  // 写入超时（毫秒），默认 0 表示不限制
+	ReadBufferSize int  // 读取磁盘文件时每次读取的缓冲区大小（字节），默认 DefaultReadBufferSize
+
+	MaxBackgroundGoroutines int // 后台任务（预加载等）可并发使用的 goroutine 上限，默认 0 表示顺序执行
+	ReadTimeout             int // 读取超时（毫秒），默认 0 表示不限制
+	WriteTimeout            int // 写入超时（毫秒），默认 0 表示不限制
+
+	ServerTiming bool // 输出 Server-Timing 响应头（缓存查找、读取、压缩耗时），默认 false
+
+	RequestTimeout time.Duration // 单个请求的整体截止时间（读取、压缩、写入），超时响应 503，默认 0 表示不限制
+
+	// Service Worker
+	PrecacheEndpoint string // 预缓存清单端点路径，为空表示不启用
+
+	// 反向代理
+	ExternalBasePath string // 对外基础路径（如 "/ui"），用于生成重定向等自引用 URL，默认为空
+	RedirectStatus   int    // 目录末尾斜杠等重定向使用的状态码，默认 0 表示 301
+
+	// 内容改写
+	Transform          TransformFunc // 按请求改写文件内容，默认为 nil 表示不改写
+	TransformExts      []string      // 参与改写的扩展名，为空时为 .html/.htm
+	StreamingTransform bool          // 流式输出改写结果，不设置 Content-Length，默认 false
 
 	// 自定义
-	Custom404    string            // 自定义 404 页面路径
-	MimeTypes    map[string]string // 自定义 MIME 类型
-	OnCacheEvict func(string)      // 缓存淘汰回调
-	OnRequest    func(string) bool // 请求前回调，返回 false 拒绝请求
+	Custom404       string            // 自定义 404 页面路径
+	NotFoundHandler gin.HandlerFunc   // 自定义 404 处理器，优先于 Custom404
+	DefaultFiles    map[string][]byte // 文件缺失时的内置回退内容（请求路径 -> 内容），内容为空返回 204
+	MimeTypes       map[string]string // 自定义 MIME 类型
+	OnCacheEvict    func(string)      // 缓存淘汰回调
+	OnRequest       func(string) bool // 请求前回调，返回 false 拒绝请求
+	BeforeServe     BeforeServeFunc   // 写入响应体前回调，返回错误时响应 500
+	OnResponse      OnResponseFunc    // 写入响应体后回调，报告实际写入字节数与写入错误
+	OnError         OnErrorFunc       // 内部服务错误回调（读取、stat、压缩失败），不含文件不存在
+	ReadErrorStatus int               // 文件存在但读取失败时的状态码，默认 0 表示 500
+
+	// 内容嗅探
+	EnableContentSniffing bool // 扩展名无法识别类型时嗅探内容，默认 false
+
+	// WASM 流式编译
+	WasmStreaming            bool // .wasm 固定返回 application/wasm 且不做实时压缩，默认 false
+	WasmCrossOriginIsolation bool // 同时设置 COOP/COEP 头以启用跨源隔离（多线程 WASM 需要）
 }
 
+// DefaultReadBufferSize 默认读取缓冲区大小
+const DefaultReadBufferSize = 32 * 1024
+
+// DefaultMaxURILength 默认请求 URI 最大长度
+const DefaultMaxURILength = 2048
+
+// DefaultMaxRanges 默认单个请求允许的最大 Range 区间数
+const DefaultMaxRanges = 16
+
 // Option 配置选项函数类型
 type Option func(*Config)
 
@@ -66,13 +169,17 @@ func defaultConfig(root string) *Config {
 		EnableGzip:      true,
 		GzipLevel:       gzip.BestSpeed,
 		CompressMinSize: 1024,
+		BrotliLevel:     DefaultBrotliLevel,
 		EnableSPA:       false,
 		IndexFile:       "index.html",
 		SPAFallback:     false,
 		HideDotFiles:    true,
+		MaxURILength:    DefaultMaxURILength,
+		MaxRanges:       DefaultMaxRanges,
 		CacheControl:    "public, max-age=60",
 		UseETag:         true,
 		PreloadOnStart:  false,
+		ReadBufferSize:  DefaultReadBufferSize,
 		ReadTimeout:     0,
 		WriteTimeout:    0,
 		Custom404:       "",
@@ -101,6 +208,33 @@ func WithCache(maxSize int64, maxFiles int) Option {
 	}
 }
 
+// WithEvictionPolicy 设置缓存淘汰策略，如 NewLRUPolicy、NewLFUPolicy、NewFIFOPolicy
+// 策略实例保存缓存键的状态，每个引擎应使用独立的实例
+func WithEvictionPolicy(policy EvictionPolicy) Option {
+	return func(c *Config) {
+		c.EvictionPolicy = policy
+	}
+}
+
+// WithStreamThreshold 设置流式返回的文件大小阈值（字节）
+// 磁盘上不小于 size 的文件通过 http.ServeContent 直接从文件流式返回，支持 Range 与条件请求，
+// 不读入内存、不进入缓存，也不做压缩。需要改写（WithTransform）或校验完整性的文件仍走常规路径；
+// embed 内容已在内存中，不受影响。size <= 0 表示不启用
+func WithStreamThreshold(size int64) Option {
+	return func(c *Config) {
+		c.StreamThreshold = size
+	}
+}
+
+// WithCachePersistence 在 dir 中持久化内存缓存
+// 创建引擎时从 dir 导入缓存，修改时间或大小与源文件不一致的条目会被丢弃；
+// 调用 StaticEngine.Close 时将当前缓存导出到 dir。需要同时启用缓存
+func WithCachePersistence(dir string) Option {
+	return func(c *Config) {
+		c.CachePersistenceDir = dir
+	}
+}
+
 // DisableCache 禁用内存缓存
 func DisableCache() Option {
 	return func(c *Config) {
@@ -123,6 +257,17 @@ func WithGzip(level int) Option {
 	}
 }
 
+// WithBrotli 启用 Brotli 压缩
+// level: 压缩级别 0-11，超出范围时取边界值；级别越高压缩率越高、耗时越长，
+// 启用缓存时每个文件只压缩一次并将 .br 版本存入缓存
+func WithBrotli(level int) Option {
+	level = GetBrotliLevel(level)
+	return func(c *Config) {
+		c.EnableBrotli = true
+		c.BrotliLevel = level
+	}
+}
+
 // DisableGzip 禁用 Gzip 压缩
 func DisableGzip() Option {
 	return func(c *Config) {
@@ -138,6 +283,84 @@ func WithCompressMinSize(size int) Option {
 	}
 }
 
+// WithPrecompressed 启用预压缩文件
+// 存在 app.js.br / app.js.zst / app.js.gz 时按客户端 Accept-Encoding 直接返回其内容
+// （Content-Encoding 分别为 br / zstd / gzip），不做实时压缩；
+// 启用缓存时 .gz、.br 内容分别作为该文件的 gzip、br 版本存入缓存，后续请求直接命中，不再读取磁盘
+func WithPrecompressed() Option {
+	return func(c *Config) {
+		c.EnablePrecompressed = true
+	}
+}
+
+// PrecompressedEncoding 预压缩文件扩展名与响应 Content-Encoding 的对应关系
+type PrecompressedEncoding struct {
+	Ext      string // 预压缩文件扩展名，如 ".br"
+	Encoding string // 返回该文件时的 Content-Encoding，如 "br"
+}
+
+// defaultPrecompressedEncodings 默认的预压缩文件对应关系，按优先级排列
+var defaultPrecompressedEncodings = []PrecompressedEncoding{
+	{Ext: ".br", Encoding: "br"},
+	{Ext: ".zst", Encoding: "zstd"},
+	{Ext: ".gz", Encoding: "gzip"},
+}
+
+// WithEncodingPriority 设置内容编码的服务端优先级，如 WithEncodingPriority("zstd", "br", "gzip")
+// 客户端 q 值更高的编码优先，q 值相同时按此顺序选择；未列出的编码不再使用
+func WithEncodingPriority(encodings ...string) Option {
+	return func(c *Config) {
+		c.EncodingPriority = encodings
+	}
+}
+
+// WithRangeOverEncoded 压缩响应的 Range 请求按编码后的字节处理
+// 客户端声明支持缓存中的编码并发送单个 Range 时，返回编码后内容的对应区间（206），
+// Content-Encoding 保持不变，Content-Range 的全长为编码后的长度。客户端须自行拼接各区间的编码字节后再解码，
+// 断点续传时应以 If-Range 携带编码响应的 ETag（建议配合 WithEncodingETagSuffix，使各编码的 ETag 互不相同）。
+// 未启用时已编码的响应忽略 Range 返回完整内容；未编码的响应始终按原始字节处理 Range
+func WithRangeOverEncoded() Option {
+	return func(c *Config) {
+		c.RangeOverEncoded = true
+	}
+}
+
+// WithContentEncodingForPrecompressed 设置预压缩文件扩展名对应的 Content-Encoding
+// 已有的扩展名（如 ".br"）覆盖其编码，新扩展名追加在默认对应关系之后；需配合 WithPrecompressed 使用
+// 例如 WithContentEncodingForPrecompressed(".brotli", "br")
+func WithContentEncodingForPrecompressed(ext, encoding string) Option {
+	return func(c *Config) {
+		if c.PrecompressedEncodings == nil {
+			c.PrecompressedEncodings = append([]PrecompressedEncoding(nil), defaultPrecompressedEncodings...)
+		}
+		for i := range c.PrecompressedEncodings {
+			if c.PrecompressedEncodings[i].Ext == ext {
+				c.PrecompressedEncodings[i].Encoding = encoding
+				return
+			}
+		}
+		c.PrecompressedEncodings = append(c.PrecompressedEncodings, PrecompressedEncoding{Ext: ext, Encoding: encoding})
+	}
+}
+
+// WithCompressionStats 启用压缩统计
+// 通过 StaticEngine.CompressionStats() 查看各编码的压缩响应数、平均压缩比和节省字节数，
+// 可用于调整压缩级别
+func WithCompressionStats() Option {
+	return func(c *Config) {
+		c.EnableCompressionStats = true
+	}
+}
+
+// WithZstdDictionary 设置 zstd 压缩字典
+// 对大量相似的小文件（如 JSON）可显著提升压缩比。字典压缩的响应使用 Content-Encoding: zstd-dict，
+// 仅当客户端在 Accept-Encoding 中声明 zstd-dict 时启用，客户端须持有同一字典才能解压
+func WithZstdDictionary(dict []byte) Option {
+	return func(c *Config) {
+		c.ZstdDictionary = dict
+	}
+}
+
 // WithSPA 启用 SPA 回退支持
 // 当请求的文件不存在时，返回 index.html 由前端路由接管
 func WithSPA(indexFile string) Option {
@@ -163,6 +386,44 @@ func WithCacheControl(header string) Option {
 	}
 }
 
+// WithMaxAge 按缓存时长设置缓存控制头
+// 生成 "public, max-age=<秒>[, immutable]"，与 CacheMiddleware 的格式一致
+// 需要完整自定义时使用 WithCacheControl
+func WithMaxAge(d time.Duration, immutable bool) Option {
+	return func(c *Config) {
+		c.CacheControl = buildCacheControl(d, immutable)
+	}
+}
+
+// WithNoCacheHTML 对 .html/.htm 响应强制使用 Cache-Control: no-cache
+// HTML 入口文件每次都向服务端验证，只有带哈希的静态资源使用全局缓存策略
+func WithNoCacheHTML() Option {
+	return func(c *Config) {
+		c.NoCacheHTML = true
+	}
+}
+
+// WithPrivateCacheWhen 对满足条件的请求使用 private 缓存
+// fn 返回 true（如请求携带认证头或会话 Cookie）时，Cache-Control 中的 public 指令替换为 private，
+// 避免 CDN 等共享缓存保存需认证的内容。通过 ServeHTTP 直接处理的请求，fn 收到的上下文只包含 Request
+func WithPrivateCacheWhen(fn func(c *gin.Context) bool) Option {
+	return func(c *Config) {
+		c.PrivateCacheWhen = fn
+	}
+}
+
+// buildCacheControl 构建 public 缓存控制头
+func buildCacheControl(maxAge time.Duration, immutable bool) string {
+	value := "public"
+	if maxAge > 0 {
+		value += ", max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+	}
+	if immutable {
+		value += ", immutable"
+	}
+	return value
+}
+
 // WithoutETag 禁用 ETag
 func WithoutETag() Option {
 	return func(c *Config) {
@@ -177,6 +438,15 @@ func WithETag() Option {
 	}
 }
 
+// WithEncodingETagSuffix 为压缩响应的 ETag 追加编码后缀（如 "<tag>-gzip"）
+// 压缩与未压缩响应使用不同的 ETag，避免不按 Vary: Accept-Encoding 区分的共享代理缓存串用；
+// If-None-Match 比较时会忽略该后缀，两种响应的条件请求均可得到 304
+func WithEncodingETagSuffix() Option {
+	return func(c *Config) {
+		c.EncodingETagSuffix = true
+	}
+}
+
 // HideDotFiles 隐藏点文件（默认行为）
 func HideDotFiles() Option {
 	return func(c *Config) {
@@ -191,13 +461,233 @@ func ShowDotFiles() Option {
 	}
 }
 
+// WithLanguageNegotiation 启用 HTML 语言协商
+// 请求 index.html 时按 Accept-Language 选择 index.<lang>.html（如 index.zh.html），
+// 客户端语言不受支持时使用 defaultLang，语言版本不存在时返回原文件；
+// 响应设置 Content-Language 与 Vary: Accept-Language
+func WithLanguageNegotiation(defaultLang string, supported []string) Option {
+	return func(c *Config) {
+		c.EnableLanguageNegotiation = true
+		c.DefaultLanguage = defaultLang
+		c.SupportedLanguages = supported
+	}
+}
+
+// WithImageNegotiation 启用图片格式协商
+// 请求 jpg/jpeg/png/gif 时，若 Accept 显式声明 image/avif 或 image/webp 且存在同名文件
+// （如 photo.jpg 对应 photo.avif、photo.webp），按 avif、webp 的优先级返回该文件，否则返回原图；
+// 响应携带 Vary: Accept
+func WithImageNegotiation() Option {
+	return func(c *Config) {
+		c.EnableImageNegotiation = true
+	}
+}
+
+// WithMethodNotAllowed 对已存在资源的 POST/PUT/PATCH/DELETE 请求返回 405
+// 响应携带 Allow: GET, HEAD；资源不存在时仍返回 404
+// 注意：会在前缀下为这些方法注册通配路由，与同前缀下相同方法的其他路由冲突
+func WithMethodNotAllowed() Option {
+	return func(c *Config) {
+		c.EnableMethodNotAllowed = true
+	}
+}
+
+// WithMaxURILength 设置请求 URI 最大长度
+// 超出时在任何路径处理之前返回 414 URI Too Long，n <= 0 表示不限制
+func WithMaxURILength(n int) Option {
+	return func(c *Config) {
+		c.MaxURILength = n
+	}
+}
+
+// WithMaxRanges 设置单个请求 Range 头允许的最大区间数
+// 防止大量细碎区间放大处理开销，超出时返回 416 Range Not Satisfiable，n <= 0 表示不限制
+func WithMaxRanges(n int) Option {
+	return func(c *Config) {
+		c.MaxRanges = n
+	}
+}
+
+// WithRedirectStatus 设置重定向（目录补齐末尾斜杠等）使用的状态码
+// 可选 301、302、303、307、308，如 308 在重定向时保留请求方法；其他值忽略，保持默认 301
+func WithRedirectStatus(code int) Option {
+	return func(c *Config) {
+		if isRedirectStatus(code) {
+			c.RedirectStatus = code
+		}
+	}
+}
+
+// isRedirectStatus 检查状态码是否为可用于 Location 重定向的 3xx 状态码
+func isRedirectStatus(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// WithETagProvider 设置外部 ETag 来源，在内置计算之前调用
+// relPath 为请求路径（如 "/js/app.js"），返回 true 时使用提供的 ETag（未加引号时自动补上），
+// 返回 false 时回退到内置计算；条件请求与缓存均使用最终的 ETag。
+// 提供的 ETag 在文件加载入缓存时确定，之后变化需等文件重新加载后才生效
+func WithETagProvider(fn func(relPath string) (string, bool)) Option {
+	return func(c *Config) {
+		c.ETagProvider = fn
+	}
+}
+
+// WithDebugHeaders 响应携带 X-Served-From 调试头，取值为 SourceDisk、SourceEmbed 或 SourceDefault
+// 用于排查文件来自哪个来源，生产环境通常无需开启
+func WithDebugHeaders() Option {
+	return func(c *Config) {
+		c.DebugHeaders = true
+	}
+}
+
+// WithMinimalHeaders 只输出 Content-Type、Content-Length（压缩时加 Content-Encoding）
+// 不再设置 Last-Modified、ETag、Cache-Control、Vary、Content-Language 等响应头，
+// 适合由外部中间件统一管理响应头的场景；客户端收不到校验器，因此也不会发起条件请求
+func WithMinimalHeaders() Option {
+	return func(c *Config) {
+		c.MinimalHeaders = true
+	}
+}
+
+// WithTransform 按请求改写指定类型文件的内容（如注入 CSP nonce、运行时环境变量）
+// exts 为参与改写的扩展名，为空时为 .html/.htm；改写后的响应不使用 ETag 和压缩，Cache-Control 为 no-cache
+func WithTransform(fn TransformFunc, exts ...string) Option {
+	return func(c *Config) {
+		c.Transform = fn
+		c.TransformExts = exts
+	}
+}
+
+// WithStreamingTransform 流式输出 Transform 的改写结果
+// 每次写入立即刷新到客户端，不预先计算 Content-Length（HTTP/1.1 下为 chunked 传输），
+// 适合较大的 HTML；需配合 WithTransform 使用
+func WithStreamingTransform() Option {
+	return func(c *Config) {
+		c.StreamingTransform = true
+	}
+}
+
+// WithExternalBasePath 设置对外基础路径
+// 部署在剥离路径前缀的反向代理之后（如公开地址 /ui/ 转发到内部 /）时，
+// 目录重定向和预缓存清单中的 URL 均加上该前缀，使其指向公开地址
+func WithExternalBasePath(base string) Option {
+	return func(c *Config) {
+		c.ExternalBasePath = base
+	}
+}
+
+// WithChecksums 设置文件完整性清单（请求路径 -> sha256 十六进制，如 "/js/app.js"）
+// 文件从磁盘或 embed.FS 加载时校验，内容不一致时记录日志并返回 ErrChecksumMismatch，
+// 该文件不会进入缓存也不会被服务；清单中未列出的文件不做校验
+func WithChecksums(sums map[string]string) Option {
+	return func(c *Config) {
+		c.Checksums = sums
+	}
+}
+
+// WithContentAddressed 启用内容寻址服务
+// manifest 为请求路径到 sha256 十六进制的清单（如 "/assets/app.js"），清单中的文件可通过
+// 在文件名前插入哈希的路径访问（"/assets/<sha256>/app.js"），响应使用
+// "public, max-age=31536000, immutable"；路径中的哈希与清单不一致时返回 404。
+// 文件加载时同样按清单校验内容，原路径仍按常规缓存策略服务。
+// 可用 StaticEngine.ContentAddressedPath 生成页面中引用的地址
+func WithContentAddressed(manifest map[string]string) Option {
+	return func(c *Config) {
+		c.ContentAddressed = manifest
+	}
+}
+
+// WithStripBOM 加载文本类型文件（text/*、JavaScript、JSON、XML、SVG）时去除开头的 UTF-8 BOM
+// 去除在读取时进行，缓存内容、Content-Length 与实时压缩均基于去除后的内容；
+// Checksums 按原始文件校验，预压缩文件按原样返回，需在生成前自行去除
+func WithStripBOM() Option {
+	return func(c *Config) {
+		c.StripBOM = true
+	}
+}
+
 // WithPreloadOnStart 启动时预加载文件到缓存
+// 预加载在后台进行，可通过 StaticEngine.WaitPreload 等待完成
 func WithPreloadOnStart() Option {
 	return func(c *Config) {
 		c.PreloadOnStart = true
 	}
 }
 
+// WithCacheTTL 设置缓存条目的有效期
+// 条目写入缓存 d 后，下一次请求重新读取源文件并替换缓存；与 WithCacheRevalidate 同时使用时，
+// 过期后先检查文件是否变化，未变化则续期而不重新读取。d <= 0 表示永不过期
+func WithCacheTTL(d time.Duration) Option {
+	return func(c *Config) {
+		c.CacheTTL = d
+	}
+}
+
+// WithCacheRevalidate 缓存命中时通过 os.Stat 重新校验磁盘文件
+// 修改时间或 ETag（修改时间与大小）变化、文件被删除时重新加载，修改后的文件无需重启即可生效。
+// 未设置 WithCacheTTL 时每次命中都校验，设置后仅在条目过期时校验；embed 内容不会变化，不做校验
+func WithCacheRevalidate() Option {
+	return func(c *Config) {
+		c.CacheRevalidate = true
+	}
+}
+
+// WithWatch 使用 fsnotify 监听 Root 目录树，文件创建、修改、删除或重命名时淘汰对应的缓存条目，
+// 下一次请求重新读取，适合本地开发。事件经过防抖（默认 DefaultWatchDebounce）后批量处理；
+// 预压缩文件变化时同时淘汰其源文件。仅对磁盘目录且启用缓存时生效，调用 StaticEngine.Close 停止监听
+func WithWatch() Option {
+	return func(c *Config) {
+		c.EnableWatch = true
+	}
+}
+
+// WithWatchDebounce 设置文件监听的防抖时长，d 内没有新事件后才淘汰缓存，需配合 WithWatch 使用
+func WithWatchDebounce(d time.Duration) Option {
+	return func(c *Config) {
+		c.WatchDebounce = d
+	}
+}
+
+// WithStaleWhileRevalidate 缓存重置（ResetCache、ReloadCache、ReloadEmbed，如部署后）时不清空缓存，
+// 而是将条目标记为过期：d 内（加减 10% 随机抖动）请求仍返回旧内容，同时每个文件只由一个后台 goroutine
+// 重新加载，避免大量并发未命中同时读盘；超出窗口仍未刷新的条目由请求同步加载。
+// 后台刷新受 WithMaxBackgroundGoroutines 限制
+func WithStaleWhileRevalidate(d time.Duration) Option {
+	return func(c *Config) {
+		c.StaleWindow = d
+	}
+}
+
+// WithMaxBackgroundGoroutines 限制预加载等后台任务同时占用的 goroutine 数（含遍历协程本身）
+// n <= 0 表示不启用并发，预加载在单个 goroutine 中顺序执行
+func WithMaxBackgroundGoroutines(n int) Option {
+	return func(c *Config) {
+		c.MaxBackgroundGoroutines = n
+	}
+}
+
+// WithReadBufferSize 设置读取磁盘文件时的缓冲区大小，默认 32KB
+// 大文件场景下增大缓冲区可减少 read 系统调用次数；n <= 0 时使用默认值
+func WithReadBufferSize(n int) Option {
+	return func(c *Config) {
+		c.ReadBufferSize = n
+	}
+}
+
+// WithServerTiming 输出 Server-Timing 响应头，便于在浏览器开发者工具中诊断慢请求
+// 格式为 "cache;dur=0.012, read;dur=0.345, compress;dur=1.234"（毫秒）；计时有额外开销，默认关闭
+func WithServerTiming() Option {
+	return func(c *Config) {
+		c.ServerTiming = true
+	}
+}
+
 // WithReadTimeout 设置读取超时
 func WithReadTimeout(timeoutMs int) Option {
 	return func(c *Config) {
@@ -212,6 +702,22 @@ func WithWriteTimeout(timeoutMs int) Option {
 	}
 }
 
+// WithRequestTimeout 设置单个请求的整体截止时间
+// 覆盖文件读取、压缩和写入；超时后放弃进行中的加载并响应 503，gin 路由与 ServeHTTP 均生效
+func WithRequestTimeout(d time.Duration) Option {
+	return func(c *Config) {
+		c.RequestTimeout = d
+	}
+}
+
+// WithPrecacheEndpoint 在 path 提供 Service Worker 预缓存清单
+// 响应格式见 StaticEngine.PrecacheHandler
+func WithPrecacheEndpoint(path string) Option {
+	return func(c *Config) {
+		c.PrecacheEndpoint = path
+	}
+}
+
 // WithCustom404 设置自定义 404 页面
 func WithCustom404(path string) Option {
 	return func(c *Config) {
@@ -219,6 +725,56 @@ func WithCustom404(path string) Option {
 	}
 }
 
+// WithNotFoundHandler 设置自定义 404 处理器
+// 文件不存在时调用该处理器（如渲染模板页面或转发请求），由其完全控制响应，优先于 WithCustom404
+func WithNotFoundHandler(handler gin.HandlerFunc) Option {
+	return func(c *Config) {
+		c.NotFoundHandler = handler
+	}
+}
+
+// WithDefaultRobots 设置 /robots.txt 缺失时返回的默认内容
+// 爬虫请求不会落入 SPA 回退返回 index.html
+func WithDefaultRobots(content string) Option {
+	return withDefaultFile("/robots.txt", []byte(content))
+}
+
+// WithDefaultFavicon 设置 /favicon.ico 缺失时返回的默认图标
+// data 为空时返回 204 No Content
+func WithDefaultFavicon(data []byte) Option {
+	return withDefaultFile("/favicon.ico", data)
+}
+
+// withDefaultFile 注册内置回退文件
+func withDefaultFile(path string, data []byte) Option {
+	return func(c *Config) {
+		if c.DefaultFiles == nil {
+			c.DefaultFiles = make(map[string][]byte)
+		}
+		c.DefaultFiles[path] = data
+	}
+}
+
+// WithHideSourceMaps 隐藏 source map
+// 所有 .map 请求返回 404（SPA 模式下也不回退到 index.html），并从预缓存清单中排除，
+// 避免在生产环境暴露源码
+func WithHideSourceMaps() Option {
+	return func(c *Config) {
+		c.HideSourceMaps = true
+	}
+}
+
+// WithSourceMapContentType 设置 .map 文件的 Content-Type，默认 "application/json"
+// 如 "application/octet-stream" 避免浏览器直接展示，或 "application/json; charset=utf-8"
+func WithSourceMapContentType(contentType string) Option {
+	return WithMimeTypes(map[string]string{"map": contentType})
+}
+
+// WithJSONContentType 设置 .json 文件的 Content-Type，默认 "application/json; charset=utf-8"
+func WithJSONContentType(contentType string) Option {
+	return WithMimeTypes(map[string]string{"json": contentType})
+}
+
 // WithMimeTypes 设置自定义 MIME 类型
 func WithMimeTypes(types map[string]string) Option {
 	return func(c *Config) {
@@ -235,6 +791,94 @@ func WithOnCacheEvict(fn func(string)) Option {
 	}
 }
 
+// FileStat 即将服务的文件信息
+type FileStat struct {
+	Path        string    // 文件路径，如 "/js/app.js"
+	Size        int64     // 原始文件大小
+	ModTime     time.Time // 修改时间
+	ETag        string    // ETag 值
+	ContentType string    // Content-Type
+	Encoding    string    // 响应使用的压缩编码，未压缩为空
+	Source      string    // 提供该文件的来源，见 SourceDisk 等常量
+}
+
+// FileStat.Source、ResponseInfo.Source 与 X-Served-From 调试头的取值
+const (
+	SourceDisk    = "disk"    // 磁盘目录（Root）
+	SourceEmbed   = "embed"   // embed.FS 或 http.FileSystem
+	SourceDefault = "default" // DefaultFiles 内置回退内容
+)
+
+// BeforeServeFunc 写入响应体前的回调
+type BeforeServeFunc func(c *gin.Context, stat *FileStat) error
+
+// WithBeforeServe 设置写入响应体前的回调
+// 在条件请求检查和响应头设置之后调用，可修改响应头、写 Cookie 或通过 c.Status 修改状态码；
+// 返回非 nil 错误时中止并响应 500。仅作用于 gin 路由，不作用于 ServeHTTP
+func WithBeforeServe(fn BeforeServeFunc) Option {
+	return func(c *Config) {
+		c.BeforeServe = fn
+	}
+}
+
+// ResponseInfo 响应体写入结果
+type ResponseInfo struct {
+	Path     string // 请求的文件路径，如 "/js/app.js"
+	Status   int    // 响应状态码
+	Size     int    // 应写入的响应体字节数（压缩后）
+	Written  int    // 实际写入的字节数
+	Encoding string // 响应使用的压缩编码，未压缩为空
+	Source   string // 提供该文件的来源，见 SourceDisk 等常量
+	Err      error  // 写入错误（如客户端中途断开），完整写入时为 nil
+}
+
+// Truncated 报告响应体是否未完整写入
+func (r *ResponseInfo) Truncated() bool {
+	return r.Err != nil || r.Written < r.Size
+}
+
+// OnResponseFunc 写入响应体后的回调
+type OnResponseFunc func(info *ResponseInfo)
+
+// WithOnResponse 设置写入响应体后的回调
+// 客户端中途断开等写入失败时立即停止服务，回调收到实际写入字节数与错误，可用于记录截断；
+// 截断的响应不计入字节统计。同时作用于 gin 路由和 ServeHTTP
+func WithOnResponse(fn OnResponseFunc) Option {
+	return func(c *Config) {
+		c.OnResponse = fn
+	}
+}
+
+// OnError 回调的操作名
+const (
+	ErrorOpRead      = "read"      // 读取文件失败（如权限不足、IO 错误）
+	ErrorOpStat      = "stat"      // 获取文件信息失败
+	ErrorOpCompress  = "compress"  // 压缩失败，响应回退为未压缩内容
+	ErrorOpChecksum  = "checksum"  // 内容与 Checksums 清单不一致
+	ErrorOpTransform = "transform" // Transform 改写内容失败
+)
+
+// OnErrorFunc 内部服务错误回调，op 为 ErrorOpRead 等操作名
+type OnErrorFunc func(path string, op string, err error)
+
+// WithOnError 设置内部服务错误回调
+// 文件存在但读取失败时回调并响应 500（文件不存在仍为 404，不触发回调）；
+// 压缩失败时回调，并回退为未压缩响应。同时作用于 gin 路由和 ServeHTTP
+func WithOnError(fn OnErrorFunc) Option {
+	return func(c *Config) {
+		c.OnError = fn
+	}
+}
+
+// WithReadErrorStatus 设置文件存在但读取失败（权限不足、IO 错误等）时的响应状态码
+// 默认 500；设为 404 时与文件不存在一致（使用 NotFoundHandler / Custom404），不暴露文件是否存在；
+// 文件不存在始终返回 404
+func WithReadErrorStatus(status int) Option {
+	return func(c *Config) {
+		c.ReadErrorStatus = status
+	}
+}
+
 // WithOnRequest 设置请求前回调
 func WithOnRequest(fn func(string) bool) Option {
 	return func(c *Config) {
@@ -242,6 +886,27 @@ func WithOnRequest(fn func(string) bool) Option {
 	}
 }
 
+// WithContentSniffing 启用内容嗅探
+// 当按扩展名只能得到 application/octet-stream 时（如无扩展名文件），
+// 使用 http.DetectContentType 检测已加载数据的前 512 字节，不产生额外 IO
+func WithContentSniffing() Option {
+	return func(c *Config) {
+		c.EnableContentSniffing = true
+	}
+}
+
+// WithWasmStreaming 保证 .wasm 文件可被 WebAssembly.instantiateStreaming 直接编译
+// .wasm 响应的 Content-Type 固定为 application/wasm（不受自定义 MIME 和内容嗅探影响），
+// 且不做实时压缩，原样返回文件字节
+// crossOriginIsolation: 为响应设置 Cross-Origin-Opener-Policy: same-origin 与
+// Cross-Origin-Embedder-Policy: require-corp，供使用 SharedArrayBuffer 的多线程 WASM 使用
+func WithWasmStreaming(crossOriginIsolation bool) Option {
+	return func(c *Config) {
+		c.WasmStreaming = true
+		c.WasmCrossOriginIsolation = crossOriginIsolation
+	}
+}
+
 // WithEmbedRoot 设置 embed.FS 的根目录
 // 当 embed 的是子目录时使用，例如：
 //
@@ -275,7 +940,59 @@ func applyConfig(root string, opts []Option) *Config {
 	return cfg
 }
 
+// builtinMimeTypes 内置常见类型
+var builtinMimeTypes = map[string]string{
+	"html":  "text/html; charset=utf-8",
+	"htm":   "text/html; charset=utf-8",
+	"css":   "text/css; charset=utf-8",
+	"js":    "application/javascript; charset=utf-8",
+	"mjs":   "application/javascript; charset=utf-8",
+	"json":  "application/json; charset=utf-8",
+	"xml":   "application/xml; charset=utf-8",
+	"txt":   "text/plain; charset=utf-8",
+	"md":    "text/markdown; charset=utf-8",
+	"png":   "image/png",
+	"jpg":   "image/jpeg",
+	"jpeg":  "image/jpeg",
+	"gif":   "image/gif",
+	"svg":   "image/svg+xml",
+	"ico":   "image/x-icon",
+	"webp":  "image/webp",
+	"avif":  "image/avif",
+	"woff":  "font/woff",
+	"woff2": "font/woff2",
+	"ttf":   "font/ttf",
+	"eot":   "application/vnd.ms-fontobject",
+	"otf":   "font/otf",
+	"pdf":   "application/pdf",
+	"zip":   "application/zip",
+	"gz":    "application/gzip",
+	"tar":   "application/x-tar",
+	"wasm":  "application/wasm",
+	"map":   "application/json", // source map
+}
+
+// registeredMimeTypes 通过 RegisterMimeType 注册的进程级 MIME 类型
+var (
+	registeredMimeTypes   = make(map[string]string)
+	registeredMimeTypesMu sync.RWMutex
+)
+
+// RegisterMimeType 注册进程级自定义 MIME 类型，对所有引擎和中间件生效
+// ext 可带或不带前导点（如 ".glb" 或 "glb"）；注册的类型优先于内置类型，
+// 但低于引擎通过 WithMimeTypes 设置的类型。可并发调用，通常在 init 或启动时注册
+func RegisterMimeType(ext, mime string) {
+	ext = strings.TrimPrefix(ext, ".")
+	if ext == "" || mime == "" {
+		return
+	}
+	registeredMimeTypesMu.Lock()
+	registeredMimeTypes[ext] = mime
+	registeredMimeTypesMu.Unlock()
+}
+
 // GetMimeType 获取文件的 MIME 类型
+// 查找顺序为 customTypes、RegisterMimeType 注册的类型、内置类型
 func GetMimeType(filename string, customTypes map[string]string) string {
 	ext := filepath.Ext(filename)
 	if ext == "" {
@@ -288,39 +1005,15 @@ func GetMimeType(filename string, customTypes map[string]string) string {
 		return mime
 	}
 
-	// 内置常见类型
-	mimeTypes := map[string]string{
-		"html":  "text/html; charset=utf-8",
-		"htm":   "text/html; charset=utf-8",
-		"css":   "text/css; charset=utf-8",
-		"js":    "application/javascript; charset=utf-8",
-		"mjs":   "application/javascript; charset=utf-8",
-		"json":  "application/json; charset=utf-8",
-		"xml":   "application/xml; charset=utf-8",
-		"txt":   "text/plain; charset=utf-8",
-		"md":    "text/markdown; charset=utf-8",
-		"png":   "image/png",
-		"jpg":   "image/jpeg",
-		"jpeg":  "image/jpeg",
-		"gif":   "image/gif",
-		"svg":   "image/svg+xml",
-		"ico":   "image/x-icon",
-		"webp":  "image/webp",
-		"avif":  "image/avif",
-		"woff":  "font/woff",
-		"woff2": "font/woff2",
-		"ttf":   "font/ttf",
-		"eot":   "application/vnd.ms-fontobject",
-		"otf":   "font/otf",
-		"pdf":   "application/pdf",
-		"zip":   "application/zip",
-		"gz":    "application/gzip",
-		"tar":   "application/x-tar",
-		"wasm":  "application/wasm",
-		"map":   "application/json", // source map
-	}
-
-	if mime, ok := mimeTypes[ext]; ok {
+	// 进程级注册类型
+	registeredMimeTypesMu.RLock()
+	mime, ok := registeredMimeTypes[ext]
+	registeredMimeTypesMu.RUnlock()
+	if ok {
+		return mime
+	}
+
+	if mime, ok := builtinMimeTypes[ext]; ok {
 		return mime
 	}
 
@@ -360,13 +1053,17 @@ func NewEmbed(router *gin.Engine, embedFS any, opts ...Option) *StaticEngine {
 		EnableGzip:      true,
 		GzipLevel:       gzip.BestSpeed,
 		CompressMinSize: 1024,
+		BrotliLevel:     DefaultBrotliLevel,
 		EnableSPA:       false,
 		IndexFile:       "index.html",
 		SPAFallback:     false,
 		HideDotFiles:    true,
+		MaxURILength:    DefaultMaxURILength,
+		MaxRanges:       DefaultMaxRanges,
 		CacheControl:    "public, max-age=60",
 		UseETag:         true,
 		PreloadOnStart:  false,
+		ReadBufferSize:  DefaultReadBufferSize,
 		ReadTimeout:     0,
 		WriteTimeout:    0,
 		Custom404:       "",
@@ -382,17 +1079,20 @@ func NewEmbed(router *gin.Engine, embedFS any, opts ...Option) *StaticEngine {
 
 	engine := &StaticEngine{
 		config: cfg,
-		cache:  NewCache(cfg.MaxCacheSize, cfg.MaxCacheFiles, cfg.OnCacheEvict),
+		cache:  NewCacheWithPolicy(cfg.MaxCacheSize, cfg.MaxCacheFiles, cfg.OnCacheEvict, cfg.EvictionPolicy),
 	}
 
 	// 注册路由
 	engine.registerRoutes(router)
 
+	// 恢复持久化缓存（如启用），先于预加载执行，已恢复的文件不再重复读取
+	if cfg.EnableCache && cfg.CachePersistenceDir != "" {
+		engine.restoreCache()
+	}
+
 	// 预加载（如启用）
 	if cfg.PreloadOnStart {
-		go func() {
-			engine.preloadEmbed()
-		}()
+		engine.startPreload()
 	}
 
 	return engine
@@ -403,15 +1103,17 @@ func NewEmbedWithConfig(router *gin.Engine, embedFS any, cfg *Config) *StaticEng
 	cfg.EmbedFS = embedFS
 	engine := &StaticEngine{
 		config: cfg,
-		cache:  NewCache(cfg.MaxCacheSize, cfg.MaxCacheFiles, cfg.OnCacheEvict),
+		cache:  NewCacheWithPolicy(cfg.MaxCacheSize, cfg.MaxCacheFiles, cfg.OnCacheEvict, cfg.EvictionPolicy),
 	}
 
 	engine.registerRoutes(router)
 
+	if cfg.EnableCache && cfg.CachePersistenceDir != "" {
+		engine.restoreCache()
+	}
+
 	if cfg.PreloadOnStart {
-		go func() {
-			engine.preloadEmbed()
-		}()
+		engine.startPreload()
 	}
 
 	return engine
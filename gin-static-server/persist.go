@@ -0,0 +1,208 @@
+package ginstatic
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// persistIndexFile 持久化目录中的索引文件名
+const persistIndexFile = "index.json"
+
+// persistVersion 持久化格式版本，不一致时 Import 拒绝加载
+const persistVersion = 1
+
+// persistIndex 持久化索引，记录每个条目的元数据与数据文件名
+type persistIndex struct {
+	Version int            `json:"version"`
+	Entries []persistEntry `json:"entries"`
+}
+
+// persistEntry 单个缓存条目的元数据
+type persistEntry struct {
+	Key     string    `json:"key"`               // 缓存键
+	Path    string    `json:"path,omitempty"`    // 文件路径
+	ModTime time.Time `json:"modTime"`           // 文件修改时间
+	Size    int64     `json:"size"`              // 原始内容大小
+	ETag    string    `json:"etag"`              // ETag 值
+	Data    string    `json:"data"`              // 原始内容数据文件名
+	Gzipped string    `json:"gzipped,omitempty"` // Gzip 内容数据文件名，未压缩为空
+	Brotli  string    `json:"brotli,omitempty"`  // Brotli 内容数据文件名，未压缩为空
+}
+
+// Export 将缓存内容（原始内容、压缩版本与元数据）导出到 dir
+// 目录格式为 index.json 加每个条目的 .raw/.gz/.br 数据文件；dir 应专用于缓存持久化，
+// 导出时覆盖索引并删除其中不再引用的数据文件
+func (c *Cache) Export(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	index := persistIndex{Version: persistVersion}
+	written := make(map[string]bool)
+	var exportErr error
+	c.entries.Range(func(key, _ interface{}) bool {
+		k := key.(string)
+		ce, ok := c.acquire(k)
+		if !ok {
+			return true
+		}
+		defer c.release(ce)
+
+		name := fmt.Sprintf("%x", md5.Sum([]byte(k)))
+		pe := persistEntry{
+			Key:     k,
+			Path:    ce.Path,
+			ModTime: ce.ModTime,
+			Size:    ce.Size,
+			ETag:    ce.ETag,
+			Data:    name + ".raw",
+		}
+		if err := os.WriteFile(filepath.Join(dir, pe.Data), ce.Data, 0644); err != nil {
+			exportErr = err
+			return false
+		}
+		written[pe.Data] = true
+		if ce.Gzipped != nil {
+			pe.Gzipped = name + ".gz"
+			if err := os.WriteFile(filepath.Join(dir, pe.Gzipped), ce.Gzipped, 0644); err != nil {
+				exportErr = err
+				return false
+			}
+			written[pe.Gzipped] = true
+		}
+		if ce.Brotli != nil {
+			pe.Brotli = name + ".br"
+			if err := os.WriteFile(filepath.Join(dir, pe.Brotli), ce.Brotli, 0644); err != nil {
+				exportErr = err
+				return false
+			}
+			written[pe.Brotli] = true
+		}
+		index.Entries = append(index.Entries, pe)
+		return true
+	})
+	if exportErr != nil {
+		return exportErr
+	}
+
+	// 索引最后写入并原子替换，中途失败时旧索引仍然完整
+	data, err := json.Marshal(&index)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, persistIndexFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, persistIndexFile)); err != nil {
+		return err
+	}
+
+	// 清理上次导出遗留的数据文件
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		name := f.Name()
+		if (strings.HasSuffix(name, ".raw") || strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".br")) && !written[name] {
+			os.Remove(filepath.Join(dir, name))
+		}
+	}
+	return nil
+}
+
+// Import 从 Export 导出的目录加载缓存条目
+// 不校验条目是否与源文件一致；引擎通过 WithCachePersistence 加载时会按源文件校验
+func (c *Cache) Import(dir string) error {
+	return c.importFrom(dir, nil)
+}
+
+// importFrom 从 dir 加载缓存条目，valid 不为 nil 时只加载其返回 true 的条目
+func (c *Cache) importFrom(dir string, valid func(entry *cacheEntry, key string) bool) error {
+	data, err := os.ReadFile(filepath.Join(dir, persistIndexFile))
+	if err != nil {
+		return err
+	}
+	var index persistIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return fmt.Errorf("invalid cache index: %w", err)
+	}
+	if index.Version != persistVersion {
+		return fmt.Errorf("unsupported cache index version: %d", index.Version)
+	}
+
+	for _, pe := range index.Entries {
+		raw, err := os.ReadFile(filepath.Join(dir, filepath.Base(pe.Data)))
+		if err != nil || int64(len(raw)) != pe.Size {
+			continue
+		}
+		entry := newPooledEntry()
+		entry.Data = raw
+		entry.ModTime = pe.ModTime
+		entry.Size = pe.Size
+		entry.ETag = pe.ETag
+		entry.Path = pe.Path
+		if pe.Gzipped != "" {
+			if gz, err := os.ReadFile(filepath.Join(dir, filepath.Base(pe.Gzipped))); err == nil {
+				entry.Gzipped = gz
+			}
+		}
+		if pe.Brotli != "" {
+			if br, err := os.ReadFile(filepath.Join(dir, filepath.Base(pe.Brotli))); err == nil {
+				entry.Brotli = br
+			}
+		}
+		if valid != nil && !valid(entry, pe.Key) {
+			continue
+		}
+		c.Set(pe.Key, entry)
+	}
+	return nil
+}
+
+// restoreCache 从 CachePersistenceDir 恢复缓存，只加载与源文件一致的条目
+// 目录或索引不存在（首次启动）时静默跳过
+func (e *StaticEngine) restoreCache() {
+	err := e.cache.importFrom(e.config.CachePersistenceDir, func(entry *cacheEntry, key string) bool {
+		path, ok := strings.CutPrefix(key, e.cacheNamespace)
+		return ok && e.sourceUnchanged(path, entry)
+	})
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("ginstatic: restore cache: %v", err)
+	}
+}
+
+// sourceUnchanged 检查缓存条目是否与当前源文件一致
+// 磁盘文件比较由修改时间、大小生成的 ETag；embed 文件的修改时间恒为零值，直接比较内容
+func (e *StaticEngine) sourceUnchanged(path string, entry *cacheEntry) bool {
+	if e.verifyChecksum(path, entry.Data) != nil {
+		return false
+	}
+	if e.config.EmbedFS != nil {
+		data, modTime, etag, err := e.getEmbedFile(path)
+		return err == nil && e.resolveETag(path, etag) == entry.ETag && modTime.Equal(entry.ModTime) && bytes.Equal(e.stripBOM(path, data), entry.Data)
+	}
+	absPath := filepath.Join(e.config.Root, path)
+	info, err := os.Stat(absPath)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	return e.resolveETag(path, generateETag(absPath, info)) == entry.ETag && info.ModTime().Equal(entry.ModTime)
+}
+
+// Close 关闭引擎，停止 WithWatch 文件监听；配置了 WithCachePersistence 时将缓存导出到持久化目录
+func (e *StaticEngine) Close() error {
+	e.stopWatch()
+	if e.config.CachePersistenceDir == "" {
+		return nil
+	}
+	return e.cache.Export(e.config.CachePersistenceDir)
+}
@@ -0,0 +1,100 @@
+package ginstatic
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// applyRange 按 Range 头截取响应内容，只支持单个区间
+// 未编码的响应按原始字节处理；已编码的响应仅在启用 RangeOverEncoded 时按编码后的字节处理，否则返回完整内容。
+// 多区间、格式无效或 If-Range 不匹配时返回完整内容。
+// 返回响应体、用于字节统计的原始大小与状态码，区间不可满足时状态码为 416 且响应体为空
+func (e *StaticEngine) applyRange(r *http.Request, h http.Header, data []byte, raw int, encoding string) ([]byte, int, int) {
+	if encoding != "" && !e.config.RangeOverEncoded {
+		return data, raw, http.StatusOK
+	}
+	if !e.config.MinimalHeaders {
+		h.Set("Accept-Ranges", "bytes")
+	}
+
+	header := r.Header.Get("Range")
+	if header == "" || !ifRangeMatches(r.Header.Get("If-Range"), h) {
+		return data, raw, http.StatusOK
+	}
+	start, end, valid, satisfiable := parseSingleRange(header, len(data))
+	if !valid {
+		return data, raw, http.StatusOK
+	}
+	if !satisfiable {
+		h.Set("Content-Range", fmt.Sprintf("bytes */%d", len(data)))
+		return nil, 0, http.StatusRequestedRangeNotSatisfiable
+	}
+
+	// 编码时 Content-Range 以编码后的长度为全长，部分响应不计入压缩节省
+	h.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+	part := data[start : end+1]
+	return part, len(part), http.StatusPartialContent
+}
+
+// ifRangeMatches 检查 If-Range 是否与当前响应的校验器一致，未携带 If-Range 时视为一致
+// ETag 形式要求强匹配，日期形式与 Last-Modified 逐字比较
+func ifRangeMatches(ifRange string, h http.Header) bool {
+	ifRange = strings.TrimSpace(ifRange)
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		etag := h.Get("ETag")
+		return etag != "" && !strings.HasPrefix(etag, "W/") && ifRange == etag
+	}
+	return ifRange == h.Get("Last-Modified")
+}
+
+// parseSingleRange 解析单区间 bytes Range 头，返回闭区间 [start, end]
+// 多区间、其他单位或格式无效时 valid 为 false；起点超出内容长度或后缀长度为 0 时 satisfiable 为 false
+func parseSingleRange(header string, size int) (start, end int, valid, satisfiable bool) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, false, false
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, false, false
+	}
+	first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+
+	if first == "" {
+		// 后缀区间 bytes=-n：最后 n 个字节
+		n, err := strconv.Atoi(last)
+		if err != nil || n < 0 {
+			return 0, 0, false, false
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, true, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true, true
+	}
+
+	start, err := strconv.Atoi(first)
+	if err != nil || start < 0 {
+		return 0, 0, false, false
+	}
+	end = size - 1
+	if last != "" {
+		if end, err = strconv.Atoi(last); err != nil || end < start {
+			return 0, 0, false, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	if start >= size {
+		return 0, 0, true, false
+	}
+	return start, end, true, true
+}
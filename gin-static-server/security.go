@@ -18,6 +18,11 @@ func IsPathTraversal(root, relPath string) (bool, string) {
 		return false, ""
 	}
 
+	// embed 后端没有磁盘根目录，清理后的路径即为安全路径
+	if root == "" {
+		return true, cleanPath
+	}
+
 	// 计算绝对路径
 	absPath := filepath.Join(root, cleanPath)
 
@@ -47,8 +52,9 @@ func IsHiddenFile(path string) bool {
 }
 
 // IsHiddenPath 检查路径中是否包含隐藏目录或文件
+// 按 / 与系统路径分隔符拆分，"." 与 ".." 不视为隐藏
 func IsHiddenPath(path string) bool {
-	parts := filepath.SplitList(path)
+	parts := strings.Split(filepath.ToSlash(path), "/")
 	for _, part := range parts {
 		if len(part) > 0 && part[0] == '.' && part != "." && part != ".." {
 			return true
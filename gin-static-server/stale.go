@@ -0,0 +1,53 @@
+package ginstatic
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// staleJitter StaleWindow 的随机抖动比例，避免同一批条目在同一时刻集中转为同步加载
+const staleJitter = 0.1
+
+// markStale 将所有条目标记为过期，过期条目在各自的窗口（window 加减随机抖动）内仍可服务
+func (c *Cache) markStale(window time.Duration) {
+	now := time.Now()
+	c.entries.Range(func(_, value interface{}) bool {
+		jitter := 1 + staleJitter*(2*rand.Float64()-1)
+		deadline := now.Add(time.Duration(float64(window) * jitter))
+		atomic.StoreInt64(&value.(*cacheEntry).staleUntil, deadline.UnixNano())
+		return true
+	})
+}
+
+// staleState 返回条目是否已过期，以及过期条目是否仍在可服务窗口内
+func (ce *cacheEntry) staleState(now time.Time) (stale, servable bool) {
+	until := atomic.LoadInt64(&ce.staleUntil)
+	if until == 0 {
+		return false, false
+	}
+	return true, now.UnixNano() < until
+}
+
+// revalidate 在后台重新加载过期条目，同一键同时只有一个刷新在进行
+// 刷新占用后台任务名额；文件已不存在时删除条目，其他错误保留过期条目直到窗口结束
+func (e *StaticEngine) revalidate(path string) {
+	key := e.cacheKey(path)
+	if _, loaded := e.revalidating.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+	go func() {
+		defer e.revalidating.Delete(key)
+		e.acquireBackground()
+		defer e.releaseBackground()
+
+		data, modTime, etag, err := e.readSource(path)
+		if err != nil {
+			if isNotFound(err) {
+				e.cache.Delete(key)
+			}
+			return
+		}
+		e.cache.Set(key, e.newCacheEntry(path, data, modTime, etag))
+	}()
+}
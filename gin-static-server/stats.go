@@ -0,0 +1,60 @@
+package ginstatic
+
+import "sync/atomic"
+
+// EncodingStats 单个编码的压缩统计
+type EncodingStats struct {
+	Responses       int64   // 压缩响应数
+	RawBytes        int64   // 压缩前字节数
+	CompressedBytes int64   // 压缩后字节数
+	SavedBytes      int64   // 节省的字节数
+	Ratio           float64 // 平均压缩比（压缩后/压缩前，按字节加权），越小越好
+}
+
+// CompressionStats 压缩统计汇总
+type CompressionStats struct {
+	Responses  int64                    // 压缩响应总数
+	SavedBytes int64                    // 节省的总字节数
+	Encodings  map[string]EncodingStats // 按编码（gzip、zstd 等）分组
+}
+
+// compressionCounter 单个编码的原子计数器
+type compressionCounter struct {
+	responses       int64
+	rawBytes        int64
+	compressedBytes int64
+}
+
+// recordCompression 记录一次压缩响应
+func (e *StaticEngine) recordCompression(encoding string, raw, compressed int) {
+	v, _ := e.compressionStats.LoadOrStore(encoding, &compressionCounter{})
+	counter := v.(*compressionCounter)
+	atomic.AddInt64(&counter.responses, 1)
+	atomic.AddInt64(&counter.rawBytes, int64(raw))
+	atomic.AddInt64(&counter.compressedBytes, int64(compressed))
+}
+
+// CompressionStats 返回压缩统计（需启用 WithCompressionStats）
+func (e *StaticEngine) CompressionStats() CompressionStats {
+	stats := CompressionStats{Encodings: make(map[string]EncodingStats)}
+
+	e.compressionStats.Range(func(key, value interface{}) bool {
+		counter := value.(*compressionCounter)
+		es := EncodingStats{
+			Responses:       atomic.LoadInt64(&counter.responses),
+			RawBytes:        atomic.LoadInt64(&counter.rawBytes),
+			CompressedBytes: atomic.LoadInt64(&counter.compressedBytes),
+		}
+		es.SavedBytes = es.RawBytes - es.CompressedBytes
+		if es.RawBytes > 0 {
+			es.Ratio = float64(es.CompressedBytes) / float64(es.RawBytes)
+		}
+
+		stats.Encodings[key.(string)] = es
+		stats.Responses += es.Responses
+		stats.SavedBytes += es.SavedBytes
+		return true
+	})
+
+	return stats
+}
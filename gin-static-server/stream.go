@@ -0,0 +1,155 @@
+package ginstatic
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveStream 磁盘文件不小于 StreamThreshold 时通过 http.ServeContent 流式返回
+// 文件不读入内存、不进入缓存，Range、条件请求与 HEAD 由 http.ServeContent 处理；返回是否已处理该请求。
+// 已缓存、需要改写或需要校验完整性的文件仍走常规路径；embed 内容本就位于内存中，不做流式处理。
+// 与常规路径一样调用 BeforeServe、OnResponse 并计入字节统计，读取使用 ReadBufferSize 大小的缓冲区
+func (e *StaticEngine) serveStream(c *gin.Context, path string, hashed bool, lang string, varyAccept bool) bool {
+	if e.config.StreamThreshold <= 0 || e.config.EmbedFS != nil || e.shouldTransform(path) || e.needsChecksum(path) {
+		return false
+	}
+	if e.config.EnableCache {
+		if entry, ok := e.cache.acquire(e.cacheKey(path)); ok {
+			e.cache.release(entry)
+			return false
+		}
+	}
+
+	// 先 stat 再打开，小文件交给常规路径时不会重复打开
+	absPath := filepath.Join(e.config.Root, path)
+	if info, err := os.Stat(absPath); err != nil || !info.Mode().IsRegular() || info.Size() < e.config.StreamThreshold {
+		return false
+	}
+	f, err := os.Open(absPath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+
+	r := c.Request
+	if e.tooManyRanges(r.Header.Get("Range")) {
+		c.Header("Content-Range", fmt.Sprintf("bytes */%d", info.Size()))
+		c.AbortWithStatus(http.StatusRequestedRangeNotSatisfiable)
+		return true
+	}
+
+	h := c.Writer.Header()
+	mimeType := e.contentType(path, nil)
+	h.Set("Content-Type", mimeType)
+	etag := e.resolveETag(path, generateETag(absPath, info))
+	modTime := info.ModTime()
+	if e.config.MinimalHeaders {
+		// 零值修改时间使 http.ServeContent 不输出 Last-Modified
+		modTime = time.Time{}
+	} else {
+		if e.config.UseETag {
+			h.Set("ETag", etag)
+		}
+		if cacheControl := e.requestCacheControl(c, path, hashed); cacheControl != "" {
+			h.Set("Cache-Control", cacheControl)
+		}
+		e.setCrossOriginIsolation(h)
+		e.setLanguageHeaders(h, path, lang)
+		if varyAccept {
+			h.Add("Vary", "Accept")
+		}
+	}
+	if e.config.DebugHeaders {
+		h.Set("X-Served-From", SourceDisk)
+	}
+
+	// 写入前回调
+	if !e.beforeServe(c, &FileStat{
+		Path:        path,
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		ETag:        etag,
+		ContentType: mimeType,
+		Source:      SourceDisk,
+	}) {
+		return true
+	}
+
+	buf := e.getReadBuffer()
+	defer e.readBufPool.Put(buf)
+	sw := &streamResponseWriter{ResponseWriter: c.Writer, buf: *buf}
+	http.ServeContent(sw, r, path, modTime, f)
+
+	if sw.err == nil {
+		e.recordBytesServed(sw.n, sw.n, "")
+	} else {
+		c.Error(sw.err)
+		c.Abort()
+	}
+	if e.config.OnResponse != nil {
+		size, err := strconv.Atoi(h.Get("Content-Length"))
+		if err != nil {
+			size = sw.n
+		}
+		e.config.OnResponse(&ResponseInfo{
+			Path:    path,
+			Status:  c.Writer.Status(),
+			Size:    size,
+			Written: sw.n,
+			Source:  SourceDisk,
+			Err:     sw.err,
+		})
+	}
+	return true
+}
+
+// streamResponseWriter 统计 http.ServeContent 写入的字节数并记录首个写入错误
+type streamResponseWriter struct {
+	http.ResponseWriter
+	buf []byte
+	n   int
+	err error
+}
+
+func (w *streamResponseWriter) Write(data []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(data)
+	w.n += n
+	if err == nil && n < len(data) {
+		err = io.ErrShortWrite
+	}
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// ReadFrom 使用 ReadBufferSize 大小的缓冲区复制文件内容
+// 包装后 io.CopyBuffer 不会走 ReaderFrom/WriterTo 捷径，也不会递归回到 ReadFrom
+func (w *streamResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.CopyBuffer(struct{ io.Writer }{w}, struct{ io.Reader }{r}, w.buf)
+}
+
+// Unwrap 返回底层 http.ResponseWriter，供 http.ResponseController 使用
+func (w *streamResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// needsChecksum 检查路径是否列在 Checksums 或 ContentAddressed 清单中，加载时需要校验内容
+func (e *StaticEngine) needsChecksum(path string) bool {
+	key := "/" + strings.TrimPrefix(path, "/")
+	_, inChecksums := e.config.Checksums[key]
+	_, inContentAddressed := e.config.ContentAddressed[key]
+	return inChecksums || inContentAddressed
+}
@@ -0,0 +1,213 @@
+package ginstatic
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewTarGzFS 从 tar.gz 归档创建静态文件服务器
+// 归档在创建时整体读入内存，之后与 embed 后端共用读取、缓存逻辑，
+// 支持嵌套目录和 WithEmbedRoot 子目录
+//
+// 示例:
+//
+//	engine, err := ginstatic.NewTarGzFS(r, "./dist.tar.gz", ginstatic.WithEmbedRoot("dist"))
+func NewTarGzFS(router *gin.Engine, path string, opts ...Option) (*StaticEngine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fsys, err := ReadTarGzFS(f)
+	if err != nil {
+		return nil, fmt.Errorf("read tar.gz %s: %w", path, err)
+	}
+
+	return NewEmbed(router, fsys, opts...), nil
+}
+
+// ReadTarGzFS 将 tar.gz 数据读入内存文件系统
+// 只保留普通文件和目录，绝对路径、包含 .. 的条目和符号链接会被跳过；
+// 同一路径既是文件又是目录（如文件 a 与 a/b）时返回错误
+func ReadTarGzFS(r io.Reader) (fs.FS, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	fsys := memFS{".": newMemDir(".", time.Time{})}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean(strings.TrimPrefix(strings.TrimPrefix(hdr.Name, "./"), "/"))
+		if name == "." || !fs.ValidPath(name) {
+			continue
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := fsys.mkdirAll(name, hdr.ModTime); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			if err := fsys.mkdirAll(path.Dir(name), hdr.ModTime); err != nil {
+				return nil, err
+			}
+			if node, ok := fsys[name]; ok && node.IsDir() {
+				return nil, errPathConflict(name)
+			}
+			fsys[name] = &memNode{
+				name:    path.Base(name),
+				data:    data,
+				mode:    fs.FileMode(hdr.Mode).Perm(),
+				modTime: hdr.ModTime,
+			}
+			fsys[path.Dir(name)].children[path.Base(name)] = true
+		}
+	}
+
+	return fsys, nil
+}
+
+// memFS 只读内存文件系统，键为 fs.ValidPath 格式的路径
+type memFS map[string]*memNode
+
+// memNode 内存文件或目录，同时实现 fs.FileInfo 与 fs.DirEntry
+type memNode struct {
+	name     string
+	data     []byte
+	mode     fs.FileMode
+	modTime  time.Time
+	children map[string]bool // 目录的子条目名，为 nil 表示普通文件
+}
+
+func newMemDir(name string, modTime time.Time) *memNode {
+	return &memNode{name: name, mode: fs.ModeDir | 0755, modTime: modTime, children: make(map[string]bool)}
+}
+
+// mkdirAll 创建目录及其所有父目录，路径上已有同名文件时返回错误
+func (m memFS) mkdirAll(name string, modTime time.Time) error {
+	if name == "." {
+		return nil
+	}
+	if node, ok := m[name]; ok {
+		if !node.IsDir() {
+			return errPathConflict(name)
+		}
+		return nil
+	}
+	parent := path.Dir(name)
+	if err := m.mkdirAll(parent, modTime); err != nil {
+		return err
+	}
+	m[name] = newMemDir(path.Base(name), modTime)
+	m[parent].children[path.Base(name)] = true
+	return nil
+}
+
+// errPathConflict 归档中同一路径既是文件又是目录
+func errPathConflict(name string) error {
+	return fmt.Errorf("tar entry %s: file and directory share the same path", name)
+}
+
+// Open 实现 fs.FS
+func (m memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	node, ok := m[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if node.children == nil {
+		return &memFile{node: node, reader: bytes.NewReader(node.data)}, nil
+	}
+
+	names := make([]string, 0, len(node.children))
+	for child := range node.children {
+		names = append(names, child)
+	}
+	sort.Strings(names)
+
+	entries := make([]fs.DirEntry, len(names))
+	for i, child := range names {
+		entries[i] = m[path.Join(name, child)]
+	}
+	return &memDirFile{node: node, entries: entries}, nil
+}
+
+func (n *memNode) Name() string               { return n.name }
+func (n *memNode) Size() int64                { return int64(len(n.data)) }
+func (n *memNode) Mode() fs.FileMode          { return n.mode }
+func (n *memNode) ModTime() time.Time         { return n.modTime }
+func (n *memNode) IsDir() bool                { return n.children != nil }
+func (n *memNode) Sys() any                   { return nil }
+func (n *memNode) Type() fs.FileMode          { return n.mode.Type() }
+func (n *memNode) Info() (fs.FileInfo, error) { return n, nil }
+
+// memFile 打开的内存文件
+type memFile struct {
+	node   *memNode
+	reader *bytes.Reader
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.node, nil }
+func (f *memFile) Read(p []byte) (int, error) { return f.reader.Read(p) }
+func (f *memFile) Close() error               { return nil }
+
+// memDirFile 打开的内存目录，实现 fs.ReadDirFile
+type memDirFile struct {
+	node    *memNode
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *memDirFile) Stat() (fs.FileInfo, error) { return d.node, nil }
+func (d *memDirFile) Close() error               { return nil }
+
+func (d *memDirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.node.name, Err: errors.New("is a directory")}
+}
+
+// ReadDir 实现 fs.ReadDirFile
+func (d *memDirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+	return remaining[:n], nil
+}
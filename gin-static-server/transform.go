@@ -0,0 +1,104 @@
+package ginstatic
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TransformFunc 按请求改写文件内容（如注入 CSP nonce、运行时环境变量）
+// 读取原始内容 data，将改写结果写入 w；返回错误时响应 500（流式模式下已写出的内容无法撤回）
+type TransformFunc func(c *gin.Context, path string, data []byte, w io.Writer) error
+
+// defaultTransformExts 未指定扩展名时参与改写的文件类型
+var defaultTransformExts = []string{".html", ".htm"}
+
+// shouldTransform 检查文件是否需要按请求改写
+func (e *StaticEngine) shouldTransform(path string) bool {
+	if e.config.Transform == nil {
+		return false
+	}
+	exts := e.config.TransformExts
+	if len(exts) == 0 {
+		exts = defaultTransformExts
+	}
+	ext := filepath.Ext(path)
+	for _, want := range exts {
+		if strings.EqualFold(ext, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveTransformed 对需要改写的文件调用 Transform 并写入响应，返回是否已处理该请求
+// 改写结果因请求而异，不使用 ETag、条件请求和压缩缓存，Cache-Control 固定为 no-cache。
+// 启用 StreamingTransform 时边改写边写出，不设置 Content-Length（HTTP/1.1 下为 chunked 传输）；
+// 否则完整缓冲后按 Content-Length 响应
+func (e *StaticEngine) serveTransformed(c *gin.Context, w http.ResponseWriter, path string, data []byte) bool {
+	if !e.shouldTransform(path) {
+		return false
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", e.contentType(path, data))
+	if !e.config.MinimalHeaders {
+		h.Set("Cache-Control", "no-cache")
+	}
+
+	if e.config.StreamingTransform {
+		w.WriteHeader(http.StatusOK)
+		sw := &streamWriter{w: w}
+		err := e.config.Transform(c, path, data, sw)
+		if err != nil {
+			e.reportError(path, ErrorOpTransform, err)
+			c.Error(err)
+			c.Abort()
+		}
+		if e.config.OnResponse != nil {
+			e.config.OnResponse(&ResponseInfo{Path: path, Status: http.StatusOK, Size: sw.n, Written: sw.n, Source: e.sourceName(), Err: err})
+		}
+		return true
+	}
+
+	var buf bytes.Buffer
+	if err := e.config.Transform(c, path, data, &buf); err != nil {
+		e.reportError(path, ErrorOpTransform, err)
+		c.Error(err)
+		c.Abort()
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return true
+	}
+
+	h.Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	if err := e.writeBody(w, path, http.StatusOK, buf.Bytes(), buf.Len(), "", e.sourceName()); err != nil {
+		c.Error(err)
+		c.Abort()
+	}
+	return true
+}
+
+// streamWriter 每次写入后立即刷新，使改写结果分块送达客户端
+type streamWriter struct {
+	w http.ResponseWriter
+	n int
+}
+
+// Write 写入并刷新到客户端
+func (s *streamWriter) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	s.n += n
+	if err != nil {
+		return n, err
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, nil
+}
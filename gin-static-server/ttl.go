@@ -0,0 +1,34 @@
+package ginstatic
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// entryFresh 检查缓存条目是否仍可直接返回
+// 写入缓存超过 CacheTTL 的条目视为过期；启用 CacheRevalidate 时，过期（未设置 TTL 时为每次命中）的磁盘条目
+// 通过 os.Stat 比较修改时间与 ETag，源文件未变化时续期并继续使用，变化或已删除时返回 false 由调用方重新加载。
+// 调用时须持有条目引用
+func (e *StaticEngine) entryFresh(path string, entry *cacheEntry, now time.Time) bool {
+	if e.config.CacheTTL > 0 && now.UnixNano()-atomic.LoadInt64(&entry.storedAt) < int64(e.config.CacheTTL) {
+		return true
+	}
+	if !e.config.CacheRevalidate {
+		return e.config.CacheTTL <= 0
+	}
+	if e.config.EmbedFS != nil {
+		// embed 内容随二进制固定，不会变化
+		return true
+	}
+
+	absPath := filepath.Join(e.config.Root, path)
+	info, err := os.Stat(absPath)
+	if err != nil || info.IsDir() || !info.ModTime().Equal(entry.ModTime) ||
+		e.resolveETag(path, generateETag(absPath, info)) != entry.ETag {
+		return false
+	}
+	atomic.StoreInt64(&entry.storedAt, now.UnixNano())
+	return true
+}
@@ -0,0 +1,151 @@
+package ginstatic
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultWatchDebounce 文件监听的默认防抖时长
+const DefaultWatchDebounce = 100 * time.Millisecond
+
+// watchSidecarExts 预压缩文件扩展名，变化时同时淘汰对应源文件的缓存
+var watchSidecarExts = []string{".gz", ".br", ".zst"}
+
+// fileWatcher 监听 Root 目录树，文件变化时淘汰对应的缓存条目
+type fileWatcher struct {
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// startWatch 启动 Root 目录树的文件监听
+// fsnotify 不递归监听，启动时添加所有子目录，运行中新建的目录在创建事件中补充
+func (e *StaticEngine) startWatch() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("ginstatic: watch %s: %v", e.config.Root, err)
+		return
+	}
+	if err := addWatchTree(watcher, e.config.Root); err != nil {
+		watcher.Close()
+		log.Printf("ginstatic: watch %s: %v", e.config.Root, err)
+		return
+	}
+
+	w := &fileWatcher{watcher: watcher, done: make(chan struct{})}
+	w.stopped.Add(1)
+	go e.watchLoop(w)
+	e.watcher = w
+}
+
+// stopWatch 停止文件监听并等待监听协程退出，未启用时直接返回
+func (e *StaticEngine) stopWatch() {
+	if e.watcher == nil {
+		return
+	}
+	close(e.watcher.done)
+	e.watcher.watcher.Close()
+	e.watcher.stopped.Wait()
+	e.watcher = nil
+}
+
+// addWatchTree 监听 root 及其所有子目录
+func addWatchTree(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+// watchLoop 收集文件事件，在 WatchDebounce 内没有新事件后统一淘汰受影响的缓存条目
+// 编辑器保存文件时往往连续产生多个事件，防抖使一次保存只淘汰一次
+func (e *StaticEngine) watchLoop(w *fileWatcher) {
+	defer w.stopped.Done()
+
+	debounce := e.config.WatchDebounce
+	if debounce <= 0 {
+		debounce = DefaultWatchDebounce
+	}
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+	pending := make(map[string]struct{})
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					// 新目录及其中已有的文件
+					if err := addWatchTree(w.watcher, event.Name); err != nil {
+						log.Printf("ginstatic: watch %s: %v", event.Name, err)
+					}
+				}
+			}
+			if rel, err := filepath.Rel(e.config.Root, event.Name); err == nil {
+				pending["/"+filepath.ToSlash(rel)] = struct{}{}
+			}
+			timer.Reset(debounce)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("ginstatic: watch %s: %v", e.config.Root, err)
+		case <-timer.C:
+			e.evictChanged(pending)
+			pending = make(map[string]struct{})
+		}
+	}
+}
+
+// evictChanged 淘汰变化路径对应的缓存条目
+// 路径本身、以其为目录前缀的条目（目录被删除或重命名）以及预压缩文件对应的源文件均被淘汰
+func (e *StaticEngine) evictChanged(paths map[string]struct{}) {
+	if len(paths) == 0 {
+		return
+	}
+	changed := make(map[string]struct{}, len(paths))
+	for path := range paths {
+		changed[path] = struct{}{}
+		for _, ext := range watchSidecarExts {
+			if base, ok := strings.CutSuffix(path, ext); ok {
+				changed[base] = struct{}{}
+			}
+		}
+	}
+
+	var keys []string
+	e.cache.entries.Range(func(key, _ interface{}) bool {
+		path, ok := strings.CutPrefix(key.(string), e.cacheNamespace)
+		if !ok {
+			return true
+		}
+		for p := range changed {
+			if path == p || strings.HasPrefix(path, p+"/") {
+				keys = append(keys, key.(string))
+				break
+			}
+		}
+		return true
+	})
+	for _, key := range keys {
+		e.cache.Delete(key)
+	}
+}
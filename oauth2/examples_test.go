@@ -10,8 +10,8 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// ExampleOAuth2Service_NewOAuth2Service 演示如何创建 OAuth2 服务
-func ExampleOAuth2Service_NewOAuth2Service() {
+// ExampleNewOAuth2Service 演示如何创建 OAuth2 服务
+func ExampleNewOAuth2Service() {
 	// 创建 OAuth2 配置
 	cfg := &Config{
 		Server:       "http://localhost:8080",
@@ -1,8 +1,13 @@
 package oauth2
 
 import (
+	"crypto/subtle"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,15 +17,104 @@ import (
 // 处理 OAuth2 相关的 HTTP 请求
 type OAuth2Handler struct {
 	oauth2Service *OAuth2Service
+	cacheTTL      time.Duration
+	tokenCache    *tokenCache
+	now           func() time.Time
+	failPolicy    FailurePolicy
+
+	jwksTTL     time.Duration
+	jwksMu      sync.Mutex
+	jwksBody    []byte    // 缓存的 JWKS 响应体
+	jwksExpires time.Time // 缓存过期时间
+}
+
+// HandlerOption 处理器配置选项
+type HandlerOption func(*OAuth2Handler)
+
+// WithTokenCache 启用认证中间件的令牌验证缓存
+//
+// 验证通过的令牌在 ttl 时长内不再请求 OAuth2 服务器，过期后重新验证
+func WithTokenCache(ttl time.Duration) HandlerOption {
+	return func(h *OAuth2Handler) {
+		h.cacheTTL = ttl
+	}
+}
+
+// DefaultJWKSCacheTTL JWKS 代理默认缓存时长
+const DefaultJWKSCacheTTL = 10 * time.Minute
+
+// WithJWKSCacheTTL 设置 JWKS 代理的缓存时长，默认为 DefaultJWKSCacheTTL
+//
+// 缓存期内 JWKS 请求不再访问 OAuth2 服务器，响应的 Cache-Control max-age 为剩余有效期
+func WithJWKSCacheTTL(ttl time.Duration) HandlerOption {
+	return func(h *OAuth2Handler) {
+		if ttl > 0 {
+			h.jwksTTL = ttl
+		}
+	}
+}
+
+// FailurePolicy OAuth2 服务器不可用时认证中间件的处理策略
+type FailurePolicy int
+
+const (
+	// FailClosed 返回 401（默认，与令牌无效时相同）
+	FailClosed FailurePolicy = iota
+
+	// FailUnavailable 返回 503，便于客户端区分服务故障与令牌失效
+	FailUnavailable
+
+	// FailOpen 放行请求，并通过 IsUnverified 标记令牌未经验证
+	FailOpen
+)
+
+// UnverifiedKey 令牌未经验证标记在 gin.Context 中的键
+const UnverifiedKey = "oauth2_unverified"
+
+// WithFailurePolicy 设置 OAuth2 服务器不可用（网络错误或 5xx）时认证中间件的策略
+//
+// 令牌无效时始终返回 401，不受该策略影响；FailOpen 放行的令牌不会写入令牌缓存
+func WithFailurePolicy(policy FailurePolicy) HandlerOption {
+	return func(h *OAuth2Handler) {
+		h.failPolicy = policy
+	}
+}
+
+// IsUnverified 判断请求是否在 FailOpen 策略下未经验证即被放行
+func IsUnverified(c *gin.Context) bool {
+	return c.GetBool(UnverifiedKey)
+}
+
+// WithClock 自定义时钟
+//
+// 用于计算令牌缓存的过期时间，默认为 time.Now，测试中可注入可控时钟
+func WithClock(now func() time.Time) HandlerOption {
+	return func(h *OAuth2Handler) {
+		if now != nil {
+			h.now = now
+		}
+	}
 }
 
 // NewOAuth2Handler 创建 OAuth2 处理器实例
 //
 // 参数 oauth2Service 为 OAuth2 服务层实例
-func NewOAuth2Handler(oauth2Service *OAuth2Service) *OAuth2Handler {
-	return &OAuth2Handler{
+func NewOAuth2Handler(oauth2Service *OAuth2Service, opts ...HandlerOption) *OAuth2Handler {
+	h := &OAuth2Handler{
 		oauth2Service: oauth2Service,
+		now:           time.Now,
+		jwksTTL:       DefaultJWKSCacheTTL,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	if h.cacheTTL > 0 {
+		h.tokenCache = newTokenCache(h.cacheTTL, h.now)
 	}
+
+	return h
 }
 
 // GetConfig 获取 OAuth2 配置
@@ -59,6 +153,75 @@ func (h *OAuth2Handler) Callback(c *gin.Context) {
 	c.JSON(http.StatusOK, tokenResp)
 }
 
+// CallbackWithCookieState 处理授权回调，并以双重提交 Cookie 校验 state（防 CSRF）
+//
+// POST /api/oauth2/callback
+// 适用于不使用服务端会话的 SPA：发起授权前调用 SetStateCookie 写入 state，
+// 回调时 state 取自查询参数或请求体，与 Cookie 不一致或 Cookie 缺失时返回 400
+func (h *OAuth2Handler) CallbackWithCookieState(c *gin.Context) {
+	var req CallbackRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_request",
+			"error_description": "缺少授权码: " + err.Error(),
+		})
+		return
+	}
+
+	state := c.Query("state")
+	if state == "" {
+		state = req.State
+	}
+	if !VerifyStateCookie(c, state) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_state",
+			"error_description": "state 与 Cookie 不一致",
+		})
+		return
+	}
+
+	tokenResp, err := h.oauth2Service.ExchangeCodeForToken(req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "token_exchange_failed",
+			"error_description": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResp)
+}
+
+// StateCookieName SetStateCookie 写入的 Cookie 名称
+const StateCookieName = "oauth2_state"
+
+// StateCookieMaxAge state Cookie 的有效期（秒），需覆盖用户在授权页停留的时间
+const StateCookieMaxAge = 600
+
+// SetStateCookie 将 state 写入 HttpOnly Cookie，供回调时双重提交校验
+//
+// Cookie 使用 SameSite=Lax，从授权服务器跳转回来的顶级导航仍会携带；
+// 请求为 HTTPS 时设置 Secure
+func SetStateCookie(c *gin.Context, state string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(StateCookieName, state, StateCookieMaxAge, "/", "", c.Request.TLS != nil, true)
+}
+
+// VerifyStateCookie 校验 state 与 SetStateCookie 写入的 Cookie 是否一致
+//
+// 两者均非空且相同时返回 true，比较为常量时间；无论结果如何都会清除该 Cookie，
+// 每个 state 只能使用一次
+func VerifyStateCookie(c *gin.Context, state string) bool {
+	cookie, err := c.Cookie(StateCookieName)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(StateCookieName, "", -1, "/", "", c.Request.TLS != nil, true)
+	if err != nil || cookie == "" || state == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) == 1
+}
+
 // GetUserInfo 获取用户信息
 //
 // GET /api/oauth2/userinfo
@@ -136,6 +299,52 @@ func (h *OAuth2Handler) BuildAuthorizeURL(c *gin.Context) {
 	})
 }
 
+// JWKS 同源代理 OAuth2 服务器的 JWKS
+//
+// GET /api/oauth2/jwks
+// 供在前端校验 ID Token 的 SPA 使用，避免跨域请求 OAuth2 服务器。
+// 响应在 WithJWKSCacheTTL 时长内缓存；刷新失败时继续返回已过期的缓存，没有缓存时返回 502
+func (h *OAuth2Handler) JWKS(c *gin.Context) {
+	body, expires, err := h.jwks()
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":             "jwks_unavailable",
+			"error_description": err.Error(),
+		})
+		return
+	}
+
+	maxAge := int(expires.Sub(h.now()) / time.Second)
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	c.Header("Cache-Control", "public, max-age="+strconv.Itoa(maxAge))
+	c.Data(http.StatusOK, "application/json", body)
+}
+
+// jwks 返回缓存的 JWKS 及其过期时间，缓存过期时向 OAuth2 服务器刷新
+func (h *OAuth2Handler) jwks() ([]byte, time.Time, error) {
+	h.jwksMu.Lock()
+	defer h.jwksMu.Unlock()
+
+	now := h.now()
+	if h.jwksBody != nil && now.Before(h.jwksExpires) {
+		return h.jwksBody, h.jwksExpires, nil
+	}
+
+	body, err := h.oauth2Service.FetchJWKS()
+	if err != nil {
+		if h.jwksBody != nil {
+			return h.jwksBody, h.jwksExpires, nil
+		}
+		return nil, time.Time{}, err
+	}
+
+	h.jwksBody = body
+	h.jwksExpires = now.Add(h.jwksTTL)
+	return h.jwksBody, h.jwksExpires, nil
+}
+
 // extractBearerToken 从 Authorization Header 中提取 Bearer Token
 func extractBearerToken(authHeader string) string {
 	if authHeader == "" {
@@ -158,6 +367,7 @@ func (h *OAuth2Handler) RegisterRoutes(r *gin.RouterGroup) {
 	r.POST("/oauth2/callback", h.Callback)
 	r.GET("/oauth2/userinfo", h.GetUserInfo)
 	r.POST("/oauth2/refresh", h.RefreshToken)
+	r.GET("/oauth2/jwks", h.JWKS)
 }
 
 // Middleware 认证中间件
@@ -176,8 +386,26 @@ func (h *OAuth2Handler) Middleware() gin.HandlerFunc {
 			return
 		}
 
+		// 命中缓存则跳过远程验证
+		if h.tokenCache != nil && h.tokenCache.valid(token) {
+			c.Next()
+			return
+		}
+
 		// 验证令牌
 		_, err := h.oauth2Service.GetUserInfo(token)
+		if err != nil && errors.Is(err, ErrServerUnavailable) && h.failPolicy != FailClosed {
+			if h.failPolicy == FailOpen {
+				c.Set(UnverifiedKey, true)
+				c.Next()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error":             "temporarily_unavailable",
+				"error_description": err.Error(),
+			})
+			return
+		}
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error":             "invalid_token",
@@ -186,6 +414,90 @@ func (h *OAuth2Handler) Middleware() gin.HandlerFunc {
 			return
 		}
 
+		if h.tokenCache != nil {
+			h.tokenCache.add(token)
+		}
+
+		c.Next()
+	}
+}
+
+// CookieToBearerMiddleware 将 Cookie 中的访问令牌转为 Authorization 头
+//
+// 用于 Cookie 会话模式，浏览器不会发送 Bearer 头时，使 GetUserInfo 和 Middleware 等
+// 基于 Bearer 的处理器无需修改即可工作；请求已携带 Authorization 头时保持不变
+func CookieToBearerMiddleware(cookieName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			if token, err := c.Cookie(cookieName); err == nil && token != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+
 		c.Next()
 	}
 }
+
+// tokenCacheMaxEntries 令牌缓存条目上限，达到时先清理已过期条目，仍然已满则淘汰最早过期的条目
+const tokenCacheMaxEntries = 10000
+
+// tokenCache 已验证令牌缓存
+//
+// 记录令牌的过期时间，过期时间由注入的时钟计算
+type tokenCache struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+// newTokenCache 创建令牌缓存
+func newTokenCache(ttl time.Duration, now func() time.Time) *tokenCache {
+	return &tokenCache{
+		entries: make(map[string]time.Time),
+		ttl:     ttl,
+		now:     now,
+	}
+}
+
+// valid 检查令牌是否在缓存有效期内，过期条目会被删除
+func (tc *tokenCache) valid(token string) bool {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	expiresAt, ok := tc.entries[token]
+	if !ok {
+		return false
+	}
+	if !tc.now().Before(expiresAt) {
+		delete(tc.entries, token)
+		return false
+	}
+	return true
+}
+
+// add 缓存已验证的令牌
+func (tc *tokenCache) add(token string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	now := tc.now()
+	if _, ok := tc.entries[token]; !ok && len(tc.entries) >= tokenCacheMaxEntries {
+		var oldest string
+		var oldestAt time.Time
+		for k, expiresAt := range tc.entries {
+			if !now.Before(expiresAt) {
+				delete(tc.entries, k)
+				continue
+			}
+			if oldest == "" || expiresAt.Before(oldestAt) {
+				oldest, oldestAt = k, expiresAt
+			}
+		}
+		// 没有过期条目时淘汰最早过期的，保证条目数不超过上限
+		if len(tc.entries) >= tokenCacheMaxEntries {
+			delete(tc.entries, oldest)
+		}
+	}
+	tc.entries[token] = now.Add(tc.ttl)
+}
@@ -1,11 +1,25 @@
 package oauth2
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -159,6 +173,8 @@ type MockServer struct {
 	tokenResp   TokenResponse
 	userInfo    UserInfo
 	tokenActive bool
+
+	userInfoCalls int32
 }
 
 func NewMockServer() *MockServer {
@@ -203,6 +219,7 @@ func (ms *MockServer) handleToken(w http.ResponseWriter, r *http.Request) {
 }
 
 func (ms *MockServer) handleUserInfo(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&ms.userInfoCalls, 1)
 	auth := r.Header.Get("Authorization")
 	if !strings.HasPrefix(auth, "Bearer ") {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
@@ -657,3 +674,918 @@ func TestOAuth2Service_DefaultServer(t *testing.T) {
 		t.Errorf("自定义 Server 不匹配: got %v, want %v", svc2.GetServer(), "http://custom-server.com")
 	}
 }
+
+// TestOAuth2Handler_Middleware_TokenCacheExpiry 测试令牌缓存按注入时钟过期后重新验证
+func TestOAuth2Handler_Middleware_TokenCacheExpiry(t *testing.T) {
+	mock := NewMockServer()
+	defer mock.Close()
+
+	svc := NewOAuth2Service(&Config{Server: mock.URL(), ClientID: "test-client"})
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	handler := NewOAuth2Handler(svc,
+		WithTokenCache(time.Minute),
+		WithClock(func() time.Time { return now }),
+	)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(handler.Middleware())
+	router.GET("/protected", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func() {
+		req := httptest.NewRequest("GET", "/protected", nil)
+		req.Header.Set("Authorization", "Bearer mock-access-token")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("状态码不匹配: got %v, want %v", w.Code, http.StatusOK)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		advance   time.Duration
+		wantCalls int32
+	}{
+		{"首次请求远程验证", 0, 1},
+		{"缓存期内命中缓存", 30 * time.Second, 1},
+		{"缓存过期后重新验证", 31 * time.Second, 2},
+		{"重新验证后再次缓存", 10 * time.Second, 2},
+	}
+
+	for _, tt := range tests {
+		now = now.Add(tt.advance)
+		request()
+		if got := atomic.LoadInt32(&mock.userInfoCalls); got != tt.wantCalls {
+			t.Errorf("%s: userinfo 调用次数 = %d, want %d", tt.name, got, tt.wantCalls)
+		}
+	}
+}
+
+// TestTokenCache_MaxEntries 测试令牌缓存已满且没有过期条目时淘汰最早过期的条目
+func TestTokenCache_MaxEntries(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tc := newTokenCache(time.Hour, func() time.Time { return now })
+
+	for i := 0; i < tokenCacheMaxEntries; i++ {
+		tc.add(fmt.Sprintf("token-%d", i))
+		now = now.Add(time.Millisecond)
+	}
+	tc.add("token-new")
+
+	if got := len(tc.entries); got != tokenCacheMaxEntries {
+		t.Errorf("缓存条目数 = %d, want %d", got, tokenCacheMaxEntries)
+	}
+	if tc.valid("token-0") {
+		t.Error("最早缓存的令牌应被淘汰")
+	}
+	if !tc.valid("token-1") || !tc.valid("token-new") {
+		t.Error("其余令牌应仍在缓存中")
+	}
+
+	// 已缓存的令牌重新写入时只刷新过期时间，不淘汰其他条目
+	tc.add("token-new")
+	if !tc.valid("token-1") {
+		t.Error("刷新已有令牌不应淘汰其他条目")
+	}
+}
+
+// TestOAuth2Service_TokenEndpoint200WithErrorBody 测试 token 端点返回 200 但响应体为错误
+func TestOAuth2Service_TokenEndpoint200WithErrorBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"error":"invalid_grant","error_description":"授权码已过期"}`))
+	}))
+	defer server.Close()
+
+	svc := NewOAuth2Service(&Config{Server: server.URL, ClientID: "test-client"})
+
+	tests := []struct {
+		name string
+		call func() (*TokenResponse, error)
+	}{
+		{"ExchangeCodeForToken", func() (*TokenResponse, error) { return svc.ExchangeCodeForToken("expired-code") }},
+		{"RefreshToken", func() (*TokenResponse, error) { return svc.RefreshToken("expired-refresh-token") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.call()
+			if resp != nil {
+				t.Errorf("不应返回令牌: %+v", resp)
+			}
+
+			var oauthErr *OAuth2Error
+			if !errors.As(err, &oauthErr) {
+				t.Fatalf("期望 *OAuth2Error，实际 %v", err)
+			}
+			if oauthErr.Code != "invalid_grant" {
+				t.Errorf("Code = %v, want invalid_grant", oauthErr.Code)
+			}
+			if oauthErr.ErrorDescription != "授权码已过期" {
+				t.Errorf("ErrorDescription = %v, want 授权码已过期", oauthErr.ErrorDescription)
+			}
+		})
+	}
+}
+
+// TestOAuth2Service_TokenRequestEncoding 测试 token 请求体的表单与 JSON 编码
+func TestOAuth2Service_TokenRequestEncoding(t *testing.T) {
+	tests := []struct {
+		name            string
+		opts            []ServiceOption
+		wantContentType string
+	}{
+		{"默认表单编码", nil, "application/x-www-form-urlencoded"},
+		{"显式表单编码", []ServiceOption{WithTokenRequestEncoding(TokenEncodingForm)}, "application/x-www-form-urlencoded"},
+		{"JSON 编码", []ServiceOption{WithTokenRequestEncoding(TokenEncodingJSON)}, "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotParams []map[string]string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if ct := r.Header.Get("Content-Type"); ct != tt.wantContentType {
+					http.Error(w, "unexpected content type: "+ct, http.StatusBadRequest)
+					return
+				}
+
+				params := map[string]string{}
+				if tt.wantContentType == "application/json" {
+					if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+						http.Error(w, "invalid json", http.StatusBadRequest)
+						return
+					}
+				} else {
+					r.ParseForm()
+					for k := range r.PostForm {
+						params[k] = r.PostForm.Get(k)
+					}
+				}
+				gotParams = append(gotParams, params)
+
+				json.NewEncoder(w).Encode(TokenResponseBody{AccessToken: "access-" + params["grant_type"]})
+			}))
+			defer server.Close()
+
+			svc := NewOAuth2Service(&Config{
+				Server:       server.URL,
+				ClientID:     "test-client",
+				ClientSecret: "test-secret",
+				RedirectURI:  "http://localhost:3000/callback",
+			}, tt.opts...)
+
+			resp, err := svc.ExchangeCodeForToken("test-code")
+			if err != nil {
+				t.Fatalf("ExchangeCodeForToken 失败: %v", err)
+			}
+			if resp.AccessToken != "access-authorization_code" {
+				t.Errorf("AccessToken 不匹配: got %v", resp.AccessToken)
+			}
+
+			resp, err = svc.RefreshToken("test-refresh-token")
+			if err != nil {
+				t.Fatalf("RefreshToken 失败: %v", err)
+			}
+			if resp.AccessToken != "access-refresh_token" {
+				t.Errorf("AccessToken 不匹配: got %v", resp.AccessToken)
+			}
+
+			if len(gotParams) != 2 {
+				t.Fatalf("请求次数 = %d, want 2", len(gotParams))
+			}
+			if gotParams[0]["code"] != "test-code" || gotParams[0]["client_secret"] != "test-secret" {
+				t.Errorf("授权码请求参数不匹配: %v", gotParams[0])
+			}
+			if gotParams[1]["refresh_token"] != "test-refresh-token" {
+				t.Errorf("刷新请求参数不匹配: %v", gotParams[1])
+			}
+		})
+	}
+}
+
+// TestOAuth2Service_PushAuthorizationRequest 测试推送授权请求与基于 request_uri 的授权 URL
+func TestOAuth2Service_PushAuthorizationRequest(t *testing.T) {
+	var gotForm url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/custom/par" || r.Method != "POST" {
+			http.NotFound(w, r)
+			return
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/x-www-form-urlencoded" {
+			http.Error(w, "unexpected content type: "+ct, http.StatusBadRequest)
+			return
+		}
+		r.ParseForm()
+		gotForm = r.PostForm
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"request_uri":"urn:ietf:params:oauth:request_uri:abc123","expires_in":60}`))
+	}))
+	defer server.Close()
+
+	svc := NewOAuth2Service(&Config{
+		Server:       server.URL,
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+		RedirectURI:  "http://localhost:3000/callback",
+	}, WithPAREndpoint(server.URL+"/custom/par"))
+
+	requestURI, err := svc.PushAuthorizationRequest(url.Values{
+		"state": {"xyz"},
+		"scope": {"read write"},
+	})
+	if err != nil {
+		t.Fatalf("PushAuthorizationRequest 失败: %v", err)
+	}
+	if requestURI != "urn:ietf:params:oauth:request_uri:abc123" {
+		t.Errorf("request_uri 不匹配: got %v", requestURI)
+	}
+
+	wantForm := map[string]string{
+		"client_id":     "test-client",
+		"client_secret": "test-secret",
+		"redirect_uri":  "http://localhost:3000/callback",
+		"response_type": "code",
+		"state":         "xyz",
+		"scope":         "read write",
+	}
+	for k, want := range wantForm {
+		if got := gotForm.Get(k); got != want {
+			t.Errorf("请求参数 %s = %q, want %q", k, got, want)
+		}
+	}
+
+	authURL, err := url.Parse(svc.BuildAuthorizeURLFromPAR(requestURI))
+	if err != nil {
+		t.Fatalf("授权 URL 无法解析: %v", err)
+	}
+	query := authURL.Query()
+	if query.Get("request_uri") != requestURI {
+		t.Errorf("授权 URL 缺少 request_uri: %v", authURL)
+	}
+	if query.Get("client_id") != "test-client" {
+		t.Errorf("授权 URL client_id 不匹配: %v", authURL)
+	}
+	if query.Get("state") != "" {
+		t.Errorf("授权 URL 不应包含已推送的参数: %v", authURL)
+	}
+}
+
+// TestOAuth2Service_PushAuthorizationRequest_Error 测试 PAR 端点返回错误
+func TestOAuth2Service_PushAuthorizationRequest_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_request","error_description":"redirect_uri 未注册"}`))
+	}))
+	defer server.Close()
+
+	svc := NewOAuth2Service(&Config{Server: server.URL, ClientID: "test-client"})
+
+	requestURI, err := svc.PushAuthorizationRequest(url.Values{"state": {"xyz"}})
+	if requestURI != "" {
+		t.Errorf("不应返回 request_uri: %v", requestURI)
+	}
+
+	var oauthErr *OAuth2Error
+	if !errors.As(err, &oauthErr) {
+		t.Fatalf("期望 *OAuth2Error，实际 %v", err)
+	}
+	if oauthErr.Code != "invalid_request" {
+		t.Errorf("Code = %v, want invalid_request", oauthErr.Code)
+	}
+}
+
+// TestCookieToBearerMiddleware 测试 Cookie 中的令牌转为 Bearer 头
+func TestCookieToBearerMiddleware(t *testing.T) {
+	mock := NewMockServer()
+	defer mock.Close()
+
+	svc := NewOAuth2Service(&Config{Server: mock.URL(), ClientID: "test-client"})
+	handler := NewOAuth2Handler(svc)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CookieToBearerMiddleware("access_token"))
+	router.GET("/api/oauth2/userinfo", handler.GetUserInfo)
+	router.GET("/header", func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetHeader("Authorization"))
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		cookie     string
+		authHeader string
+		wantStatus int
+		wantBody   string
+	}{
+		{"Cookie 令牌访问 Bearer 处理器", "/api/oauth2/userinfo", "mock-access-token", "", http.StatusOK, ""},
+		{"无 Cookie 无令牌", "/api/oauth2/userinfo", "", "", http.StatusUnauthorized, ""},
+		{"Cookie 转为 Bearer 头", "/header", "cookie-token", "", http.StatusOK, "Bearer cookie-token"},
+		{"已有 Authorization 头不覆盖", "/header", "cookie-token", "Bearer header-token", http.StatusOK, "Bearer header-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "access_token", Value: tt.cookie})
+			}
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("状态码不匹配: got %v, want %v", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("响应不匹配: got %v, want %v", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+// TestOAuth2Handler_Middleware_FailurePolicy 测试 OAuth2 服务器故障时各策略的处理
+func TestOAuth2Handler_Middleware_FailurePolicy(t *testing.T) {
+	// 返回 5xx 的服务器
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	// 已关闭的服务器，模拟网络错误
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closedURL := closed.URL
+	closed.Close()
+
+	// 拒绝令牌的服务器
+	reject := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"invalid_token"}`))
+	}))
+	defer reject.Close()
+
+	mock := NewMockServer()
+	defer mock.Close()
+
+	tests := []struct {
+		name           string
+		server         string
+		authHeader     string
+		policy         FailurePolicy
+		wantStatus     int
+		wantUnverified bool
+	}{
+		{"FailClosed 5xx 返回 401", down.URL, "Bearer t", FailClosed, http.StatusUnauthorized, false},
+		{"FailUnavailable 5xx 返回 503", down.URL, "Bearer t", FailUnavailable, http.StatusServiceUnavailable, false},
+		{"FailUnavailable 网络错误返回 503", closedURL, "Bearer t", FailUnavailable, http.StatusServiceUnavailable, false},
+		{"FailOpen 5xx 放行并标记", down.URL, "Bearer t", FailOpen, http.StatusOK, true},
+		{"FailOpen 网络错误放行并标记", closedURL, "Bearer t", FailOpen, http.StatusOK, true},
+		{"FailOpen 令牌无效仍返回 401", reject.URL, "Bearer invalid", FailOpen, http.StatusUnauthorized, false},
+		{"FailOpen 服务正常时不标记", mock.URL(), "Bearer mock-access-token", FailOpen, http.StatusOK, false},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewOAuth2Service(&Config{Server: tt.server, ClientID: "test-client"})
+			handler := NewOAuth2Handler(svc, WithFailurePolicy(tt.policy))
+
+			router := gin.New()
+			router.Use(handler.Middleware())
+			router.GET("/protected", func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"unverified": IsUnverified(c)})
+			})
+
+			req := httptest.NewRequest("GET", "/protected", nil)
+			req.Header.Set("Authorization", tt.authHeader)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("状态码不匹配: got %v, want %v", w.Code, tt.wantStatus)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Unverified bool `json:"unverified"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if body.Unverified != tt.wantUnverified {
+				t.Errorf("unverified = %v, want %v", body.Unverified, tt.wantUnverified)
+			}
+		})
+	}
+}
+
+// newTestClientCertificate 生成自签名的客户端证书
+func newTestClientCertificate(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("生成密钥失败: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("生成证书失败: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("解析证书失败: %v", err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, leaf
+}
+
+// TestOAuth2Service_ClientCertificate 测试 mTLS 客户端证书认证
+func TestOAuth2Service_ClientCertificate(t *testing.T) {
+	cert, leaf := newTestClientCertificate(t)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(leaf)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 || r.TLS.PeerCertificates[0].Subject.CommonName != "test-client" {
+			http.Error(w, "missing client certificate", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(TokenResponseBody{AccessToken: "mtls-token"})
+	}))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+
+	cfg := &Config{Server: server.URL, ClientID: "test-client"}
+
+	tests := []struct {
+		name    string
+		opts    []ServiceOption
+		wantErr bool
+	}{
+		{"携带客户端证书", []ServiceOption{WithClientCertificate(cert), WithRootCAs(rootCAs)}, false},
+		{"未携带客户端证书", []ServiceOption{WithRootCAs(rootCAs)}, true},
+		{"WithHTTPClient 覆盖证书配置", []ServiceOption{WithClientCertificate(cert), WithRootCAs(rootCAs), WithHTTPClient(server.Client())}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewOAuth2Service(cfg, tt.opts...)
+
+			resp, err := svc.ExchangeCodeForToken("test-code")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("期望握手失败，实际获得令牌 %+v", resp)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExchangeCodeForToken 失败: %v", err)
+			}
+			if resp.AccessToken != "mtls-token" {
+				t.Errorf("AccessToken 不匹配: got %v", resp.AccessToken)
+			}
+		})
+	}
+}
+
+// newTestIDToken 构造未签名的测试 ID Token
+func newTestIDToken(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("序列化声明失败: %v", err)
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	return header + "." + base64.RawURLEncoding.EncodeToString(payload) + ".signature"
+}
+
+// TestOAuth2Service_VerifyIDToken 测试 ID Token 的 nonce、受众和过期校验
+func TestOAuth2Service_VerifyIDToken(t *testing.T) {
+	svc := NewOAuth2Service(&Config{Server: "http://localhost", ClientID: "test-client"})
+	future := time.Now().Add(time.Hour).Unix()
+	past := time.Now().Add(-time.Minute).Unix()
+
+	tests := []struct {
+		name          string
+		claims        map[string]any
+		expectedNonce string
+		wantErr       string
+	}{
+		{"nonce 匹配", map[string]any{"sub": "user-1", "aud": "test-client", "exp": future, "nonce": "n-123"}, "n-123", ""},
+		{"受众为数组", map[string]any{"sub": "user-1", "aud": []string{"test-client", "other"}, "azp": "test-client", "exp": future, "nonce": "n-123"}, "n-123", ""},
+		{"nonce 不匹配", map[string]any{"sub": "user-1", "aud": "test-client", "exp": future, "nonce": "n-456"}, "n-123", "nonce 不匹配"},
+		{"缺少 nonce", map[string]any{"sub": "user-1", "aud": "test-client", "exp": future}, "n-123", "nonce 不匹配"},
+		{"已过期", map[string]any{"sub": "user-1", "aud": "test-client", "exp": past, "nonce": "n-123"}, "n-123", "已过期"},
+		{"受众不符", map[string]any{"sub": "user-1", "aud": "other-client", "exp": future, "nonce": "n-123"}, "n-123", "受众不包含客户端"},
+		{"多受众 azp 不符", map[string]any{"sub": "user-1", "aud": []string{"test-client", "other"}, "azp": "other", "exp": future, "nonce": "n-123"}, "n-123", "azp 与客户端不符"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := svc.VerifyIDToken(newTestIDToken(t, tt.claims), tt.expectedNonce)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("VerifyIDToken 失败: %v", err)
+				}
+				if claims.Subject != "user-1" || claims.Nonce != tt.expectedNonce {
+					t.Errorf("声明不匹配: %+v", claims)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidIDToken) {
+				t.Fatalf("期望 ErrInvalidIDToken，实际 %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("错误信息不匹配: got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := svc.VerifyIDToken("not-a-jwt", "n-123"); !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("格式错误应返回 ErrInvalidIDToken，实际 %v", err)
+	}
+}
+
+// TestMemoryNonceStore 测试 nonce 一次性取出与过期
+func TestMemoryNonceStore(t *testing.T) {
+	store := NewMemoryNonceStore()
+
+	nonce, err := GenerateNonce()
+	if err != nil {
+		t.Fatalf("GenerateNonce 失败: %v", err)
+	}
+	if err := store.Save("state-1", nonce, time.Minute); err != nil {
+		t.Fatalf("Save 失败: %v", err)
+	}
+
+	got, ok := store.Pop("state-1")
+	if !ok || got != nonce {
+		t.Fatalf("Pop 结果不匹配: got %v, want %v", got, nonce)
+	}
+	if _, ok := store.Pop("state-1"); ok {
+		t.Error("nonce 只能取出一次")
+	}
+
+	store.Save("state-2", "expired", -time.Second)
+	if _, ok := store.Pop("state-2"); ok {
+		t.Error("过期的 nonce 不应取出")
+	}
+
+	svc := NewOAuth2Service(&Config{Server: "http://localhost", ClientID: "test-client"})
+	authURL, err := url.Parse(svc.BuildAuthorizeURLWithNonce("state-1", "openid", nonce))
+	if err != nil {
+		t.Fatalf("授权 URL 无法解析: %v", err)
+	}
+	if authURL.Query().Get("nonce") != nonce || authURL.Query().Get("state") != "state-1" {
+		t.Errorf("授权 URL 参数不匹配: %v", authURL)
+	}
+}
+
+func TestOAuth2Handler_JWKS(t *testing.T) {
+	const jwks = `{"keys":[{"kty":"EC","kid":"k1","crv":"P-256","x":"x","y":"y"}]}`
+	var hits int32
+	var down atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/keys" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&hits, 1)
+		if down.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(jwks))
+	}))
+	defer upstream.Close()
+
+	now := time.Unix(1700000000, 0)
+	svc := NewOAuth2Service(&Config{Server: upstream.URL}, WithJWKSEndpoint(upstream.URL+"/keys"))
+	handler := NewOAuth2Handler(svc,
+		WithJWKSCacheTTL(time.Minute),
+		WithClock(func() time.Time { return now }),
+	)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.RegisterRoutes(router.Group("/api"))
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/oauth2/jwks", nil))
+		return w
+	}
+
+	w := get()
+	if w.Code != http.StatusOK || w.Body.String() != jwks {
+		t.Fatalf("首次请求: got %d %q, want 200 %q", w.Code, w.Body.String(), jwks)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "public, max-age=60" {
+		t.Errorf("Cache-Control = %q, want public, max-age=60", got)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+
+	// 缓存期内不访问上游，max-age 为剩余有效期
+	now = now.Add(20 * time.Second)
+	w = get()
+	if w.Code != http.StatusOK || w.Body.String() != jwks {
+		t.Fatalf("缓存请求: got %d %q", w.Code, w.Body.String())
+	}
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Errorf("缓存期内上游请求次数 = %d, want 1", n)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "public, max-age=40" {
+		t.Errorf("Cache-Control = %q, want public, max-age=40", got)
+	}
+
+	// 过期后刷新；上游故障时返回已过期的缓存
+	now = now.Add(time.Minute)
+	down.Store(true)
+	w = get()
+	if w.Code != http.StatusOK || w.Body.String() != jwks {
+		t.Fatalf("上游故障: got %d %q, want 缓存内容", w.Code, w.Body.String())
+	}
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Errorf("过期后上游请求次数 = %d, want 2", n)
+	}
+
+	// 没有缓存且上游故障时返回 502
+	fresh := NewOAuth2Handler(svc)
+	r2 := gin.New()
+	r2.GET("/jwks", fresh.JWKS)
+	w = httptest.NewRecorder()
+	r2.ServeHTTP(w, httptest.NewRequest("GET", "/jwks", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("无缓存上游故障: got %d, want 502", w.Code)
+	}
+}
+
+func TestOAuth2Service_LoadDiscovery(t *testing.T) {
+	var tokenHits int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(DiscoveryDocument{
+				Issuer:                server.URL,
+				AuthorizationEndpoint: server.URL + "/idp/auth",
+				TokenEndpoint:         server.URL + "/idp/token",
+				UserInfoEndpoint:      server.URL + "/idp/me",
+				RevocationEndpoint:    server.URL + "/idp/revoke",
+				JWKSURI:               server.URL + "/idp/keys",
+			})
+		case "/idp/token":
+			atomic.AddInt32(&tokenHits, 1)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"discovered","token_type":"Bearer","expires_in":3600}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	svc := NewOAuth2Service(&Config{Server: server.URL, ClientID: "test-client"})
+	if err := svc.LoadDiscovery(context.Background()); err != nil {
+		t.Fatalf("LoadDiscovery() 错误 = %v", err)
+	}
+
+	got := svc.Endpoints()
+	want := Endpoints{
+		Authorize:  server.URL + "/idp/auth",
+		Token:      server.URL + "/idp/token",
+		UserInfo:   server.URL + "/idp/me",
+		Introspect: server.URL + "/oauth2/introspect",
+		Revoke:     server.URL + "/idp/revoke",
+		JWKS:       server.URL + "/idp/keys",
+		PAR:        server.URL + "/oauth2/par",
+	}
+	if got != want {
+		t.Errorf("Endpoints() = %+v, want %+v", got, want)
+	}
+
+	token, err := svc.ExchangeCodeForToken("code")
+	if err != nil {
+		t.Fatalf("ExchangeCodeForToken() 错误 = %v", err)
+	}
+	if token.AccessToken != "discovered" || atomic.LoadInt32(&tokenHits) != 1 {
+		t.Errorf("token 请求未发送到发现的端点: token=%q hits=%d", token.AccessToken, tokenHits)
+	}
+	if u := svc.BuildAuthorizeURL("s", "read"); !strings.HasPrefix(u, server.URL+"/idp/auth?") {
+		t.Errorf("BuildAuthorizeURL() = %q, want 发现的授权端点", u)
+	}
+}
+
+func TestOAuth2Service_LoadDiscoveryFallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	svc := NewOAuth2Service(&Config{Server: server.URL}, WithJWKSEndpoint("https://keys.example.com/jwks"))
+	err := svc.LoadDiscovery(context.Background())
+	if !errors.Is(err, ErrServerUnavailable) {
+		t.Fatalf("LoadDiscovery() 错误 = %v, want ErrServerUnavailable", err)
+	}
+
+	got := svc.Endpoints()
+	if got.Token != server.URL+"/oauth2/token" || got.Authorize != server.URL+"/oauth2/authorize" {
+		t.Errorf("加载失败后应使用默认端点, got %+v", got)
+	}
+	if got.JWKS != "https://keys.example.com/jwks" {
+		t.Errorf("JWKS = %q, want 显式设置的端点", got.JWKS)
+	}
+}
+
+func TestOAuth2Service_ExchangeToken(t *testing.T) {
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth2/token" {
+			http.NotFound(w, r)
+			return
+		}
+		r.ParseForm()
+		form = r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"exchanged-token","issued_token_type":"urn:ietf:params:oauth:token-type:access_token","token_type":"Bearer","expires_in":600}`))
+	}))
+	defer server.Close()
+
+	svc := NewOAuth2Service(&Config{Server: server.URL, ClientID: "test-client", ClientSecret: "test-secret"})
+
+	resp, err := svc.ExchangeToken("subject-token", TokenTypeAccessToken, TokenTypeAccessToken, "https://api.example.com")
+	if err != nil {
+		t.Fatalf("ExchangeToken 失败: %v", err)
+	}
+
+	wantForm := map[string]string{
+		"grant_type":           GrantTypeTokenExchange,
+		"subject_token":        "subject-token",
+		"subject_token_type":   TokenTypeAccessToken,
+		"requested_token_type": TokenTypeAccessToken,
+		"audience":             "https://api.example.com",
+		"client_id":            "test-client",
+		"client_secret":        "test-secret",
+	}
+	for k, want := range wantForm {
+		if got := form.Get(k); got != want {
+			t.Errorf("参数 %s = %q, want %q", k, got, want)
+		}
+	}
+
+	if resp.AccessToken != "exchanged-token" {
+		t.Errorf("AccessToken = %q, want %q", resp.AccessToken, "exchanged-token")
+	}
+	if resp.IssuedTokenType != TokenTypeAccessToken {
+		t.Errorf("IssuedTokenType = %q, want %q", resp.IssuedTokenType, TokenTypeAccessToken)
+	}
+	if resp.ExpiresIn != 600 {
+		t.Errorf("ExpiresIn = %d, want 600", resp.ExpiresIn)
+	}
+
+	// 可选参数为空时不发送
+	if _, err := svc.ExchangeToken("subject-token", TokenTypeJWT, "", ""); err != nil {
+		t.Fatalf("ExchangeToken 失败: %v", err)
+	}
+	if _, ok := form["requested_token_type"]; ok {
+		t.Errorf("requested_token_type 为空时不应发送")
+	}
+	if _, ok := form["audience"]; ok {
+		t.Errorf("audience 为空时不应发送")
+	}
+
+	if _, err := svc.ExchangeToken("", TokenTypeAccessToken, "", ""); err == nil {
+		t.Error("subjectToken 为空时应返回错误")
+	}
+	if _, err := svc.ExchangeToken("subject-token", "", "", ""); err == nil {
+		t.Error("subjectTokenType 为空时应返回错误")
+	}
+}
+
+func TestOAuth2Service_ExchangeToken_OAuth2Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant","error_description":"subject token expired"}`))
+	}))
+	defer server.Close()
+
+	svc := NewOAuth2Service(&Config{Server: server.URL, ClientID: "test-client", ClientSecret: "test-secret"})
+
+	_, err := svc.ExchangeToken("subject-token", TokenTypeAccessToken, "", "")
+	if err == nil {
+		t.Fatal("期望返回错误")
+	}
+	var oauthErr *OAuth2Error
+	if !errors.As(err, &oauthErr) {
+		t.Fatalf("错误应可通过 errors.As 取得 *OAuth2Error: %v", err)
+	}
+	if oauthErr.Code != "invalid_grant" {
+		t.Errorf("Code = %q, want %q", oauthErr.Code, "invalid_grant")
+	}
+	if oauthErr.ErrorDescription != "subject token expired" {
+		t.Errorf("ErrorDescription = %q, want %q", oauthErr.ErrorDescription, "subject token expired")
+	}
+}
+
+func TestOAuth2Handler_CallbackWithCookieState(t *testing.T) {
+	mock := NewMockServer()
+	defer mock.Close()
+
+	svc := NewOAuth2Service(&Config{
+		Server:       mock.URL(),
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+		RedirectURI:  "http://localhost:3000/callback",
+	})
+	handler := NewOAuth2Handler(svc)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/oauth2/state", func(c *gin.Context) {
+		SetStateCookie(c, c.Query("state"))
+		c.Status(http.StatusNoContent)
+	})
+	router.POST("/api/oauth2/callback", handler.CallbackWithCookieState)
+
+	// SetStateCookie 写入 HttpOnly、SameSite=Lax 的 Cookie
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/oauth2/state?state=abc123", nil))
+	setCookie := w.Header().Get("Set-Cookie")
+	for _, want := range []string{StateCookieName + "=abc123", "HttpOnly", "SameSite=Lax", "Max-Age=600"} {
+		if !strings.Contains(setCookie, want) {
+			t.Errorf("Set-Cookie = %q, 应包含 %q", setCookie, want)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		cookie     string
+		query      string
+		body       string
+		wantStatus int
+	}{
+		{"查询参数与 Cookie 一致", "abc123", "?state=abc123", `{"code":"test-code"}`, http.StatusOK},
+		{"请求体 state 与 Cookie 一致", "abc123", "", `{"code":"test-code","state":"abc123"}`, http.StatusOK},
+		{"state 不一致", "abc123", "?state=evil", `{"code":"test-code"}`, http.StatusBadRequest},
+		{"缺少 Cookie", "", "?state=abc123", `{"code":"test-code"}`, http.StatusBadRequest},
+		{"缺少 state", "abc123", "", `{"code":"test-code"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/oauth2/callback"+tt.query, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: StateCookieName, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("状态码 = %d, want %d, body = %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK {
+				var resp TokenResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.AccessToken != "mock-access-token" {
+					t.Errorf("AccessToken = %q, err = %v", resp.AccessToken, err)
+				}
+			} else if !strings.Contains(w.Body.String(), "invalid_state") {
+				t.Errorf("响应应包含 invalid_state: %s", w.Body.String())
+			}
+			// 校验后 Cookie 被清除，state 只能使用一次
+			if got := w.Header().Get("Set-Cookie"); !strings.Contains(got, StateCookieName+"=;") || !strings.Contains(got, "Max-Age=0") {
+				t.Errorf("Set-Cookie = %q, 应清除 state Cookie", got)
+			}
+		})
+	}
+}
@@ -2,29 +2,77 @@ package oauth2
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
 // 默认 OAuth2 服务器地址
 const defaultOAuth2Server = "https://uf.yigechengzi.com/"
 
+// ErrServerUnavailable OAuth2 服务器不可用（网络错误或 5xx 响应）
+//
+// 可通过 errors.Is 与令牌无效区分
+var ErrServerUnavailable = errors.New("OAuth2 服务器不可用")
+
+// ErrInvalidIDToken ID Token 校验失败（格式错误、nonce 不匹配、受众不符或已过期）
+var ErrInvalidIDToken = errors.New("ID Token 无效")
+
 // OAuth2Service OAuth2 服务层
 //
 // 封装 OAuth2 核心业务逻辑，包括授权码换令牌、获取用户信息、刷新令牌等功能
 type OAuth2Service struct {
-	oauth2Server string
-	clientID     string
-	clientSecret string
-	redirectURI  string
-	httpClient   *http.Client
+	oauth2Server  string
+	clientID      string
+	clientSecret  string
+	redirectURI   string
+	httpClient    *http.Client
+	tokenEncoding TokenRequestEncoding
+	parEndpoint   string // WithPAREndpoint 显式设置的端点，优先于发现文档
+	jwksEndpoint  string // WithJWKSEndpoint 显式设置的端点，优先于发现文档
+
+	endpointsMu sync.RWMutex
+	endpoints   Endpoints
+
+	customClient bool              // 是否通过 WithHTTPClient 自定义了客户端
+	clientCerts  []tls.Certificate // mTLS 客户端证书
+	rootCAs      *x509.CertPool    // 校验服务器证书的根证书
 }
 
+// TokenRequestEncoding token 端点请求体编码方式
+type TokenRequestEncoding string
+
+const (
+	// TokenEncodingForm 使用 application/x-www-form-urlencoded（默认，符合 RFC 6749）
+	TokenEncodingForm TokenRequestEncoding = "form"
+
+	// TokenEncodingJSON 使用 application/json，适配要求 JSON 请求体的服务器
+	TokenEncodingJSON TokenRequestEncoding = "json"
+)
+
+// GrantTypeTokenExchange 令牌交换（RFC 8693）的 grant_type
+const GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+// RFC 8693 定义的令牌类型标识，用于 ExchangeToken 的 subjectTokenType 与 requestedTokenType
+const (
+	TokenTypeAccessToken  = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeRefreshToken = "urn:ietf:params:oauth:token-type:refresh_token"
+	TokenTypeIDToken      = "urn:ietf:params:oauth:token-type:id_token"
+	TokenTypeJWT          = "urn:ietf:params:oauth:token-type:jwt"
+)
+
 // ServiceOption 服务配置选项
 type ServiceOption func(*OAuth2Service)
 
@@ -32,6 +80,53 @@ type ServiceOption func(*OAuth2Service)
 func WithHTTPClient(client *http.Client) ServiceOption {
 	return func(s *OAuth2Service) {
 		s.httpClient = client
+		s.customClient = true
+	}
+}
+
+// WithClientCertificate 使用 mTLS 客户端证书向 OAuth2 服务器认证
+//
+// 作用于默认 HTTP 客户端的 token、userinfo、introspect 等所有请求；
+// 同时使用 WithHTTPClient 时以自定义客户端为准，需自行在其 Transport 中配置证书
+func WithClientCertificate(cert tls.Certificate) ServiceOption {
+	return func(s *OAuth2Service) {
+		s.clientCerts = append(s.clientCerts, cert)
+	}
+}
+
+// WithRootCAs 设置校验 OAuth2 服务器证书的根证书，用于私有 CA
+//
+// 与 WithClientCertificate 相同，仅作用于默认 HTTP 客户端
+func WithRootCAs(pool *x509.CertPool) ServiceOption {
+	return func(s *OAuth2Service) {
+		s.rootCAs = pool
+	}
+}
+
+// WithTokenRequestEncoding 设置 token 端点请求体编码方式
+//
+// 影响 ExchangeCodeForToken、RefreshToken 和 ExchangeToken，默认为 TokenEncodingForm
+func WithTokenRequestEncoding(encoding TokenRequestEncoding) ServiceOption {
+	return func(s *OAuth2Service) {
+		s.tokenEncoding = encoding
+	}
+}
+
+// WithPAREndpoint 设置推送授权请求（PAR，RFC 9126）端点
+//
+// 默认为 {Server}/oauth2/par
+func WithPAREndpoint(endpoint string) ServiceOption {
+	return func(s *OAuth2Service) {
+		s.parEndpoint = endpoint
+	}
+}
+
+// WithJWKSEndpoint 设置 JWKS（JSON Web Key Set）端点
+//
+// 默认为 {Server}/.well-known/jwks.json
+func WithJWKSEndpoint(endpoint string) ServiceOption {
+	return func(s *OAuth2Service) {
+		s.jwksEndpoint = endpoint
 	}
 }
 
@@ -47,20 +142,128 @@ func NewOAuth2Service(cfg *Config, opts ...ServiceOption) *OAuth2Service {
 	}
 
 	s := &OAuth2Service{
-		oauth2Server: server,
-		clientID:     cfg.ClientID,
-		clientSecret: cfg.ClientSecret,
-		redirectURI:  cfg.RedirectURI,
-		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		oauth2Server:  server,
+		clientID:      cfg.ClientID,
+		clientSecret:  cfg.ClientSecret,
+		redirectURI:   cfg.RedirectURI,
+		httpClient:    &http.Client{Timeout: 30 * time.Second},
+		tokenEncoding: TokenEncodingForm,
 	}
 
 	for _, opt := range opts {
 		opt(s)
 	}
 
+	s.endpoints = s.defaultEndpoints()
+
+	if !s.customClient && (len(s.clientCerts) > 0 || s.rootCAs != nil) {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{
+			Certificates: s.clientCerts,
+			RootCAs:      s.rootCAs,
+			MinVersion:   tls.VersionTLS12,
+		}
+		s.httpClient.Transport = transport
+	}
+
 	return s
 }
 
+// defaultEndpoints 返回由服务器地址拼接的默认端点，显式设置的端点保持不变
+func (s *OAuth2Service) defaultEndpoints() Endpoints {
+	e := Endpoints{
+		Authorize:  s.oauth2Server + "/oauth2/authorize",
+		Token:      s.oauth2Server + "/oauth2/token",
+		UserInfo:   s.oauth2Server + "/oauth2/userinfo",
+		Introspect: s.oauth2Server + "/oauth2/introspect",
+		Revoke:     s.oauth2Server + "/oauth2/revoke",
+		JWKS:       s.oauth2Server + "/.well-known/jwks.json",
+		PAR:        s.oauth2Server + "/oauth2/par",
+	}
+	if s.parEndpoint != "" {
+		e.PAR = s.parEndpoint
+	}
+	if s.jwksEndpoint != "" {
+		e.JWKS = s.jwksEndpoint
+	}
+	return e
+}
+
+// Endpoints 返回当前使用的各端点地址
+func (s *OAuth2Service) Endpoints() Endpoints {
+	s.endpointsMu.RLock()
+	defer s.endpointsMu.RUnlock()
+	return s.endpoints
+}
+
+// LoadDiscovery 从 {Server}/.well-known/openid-configuration 加载发现文档并更新端点
+//
+// 文档中给出的端点覆盖默认值，未给出的保持默认值；WithPAREndpoint、WithJWKSEndpoint
+// 显式设置的端点不被覆盖。加载失败时返回错误，端点恢复为默认值
+func (s *OAuth2Service) LoadDiscovery(ctx context.Context) error {
+	doc, err := s.fetchDiscovery(ctx)
+
+	e := s.defaultEndpoints()
+	if err == nil {
+		override := func(dst *string, v string) {
+			if v != "" {
+				*dst = v
+			}
+		}
+		override(&e.Authorize, doc.AuthorizationEndpoint)
+		override(&e.Token, doc.TokenEndpoint)
+		override(&e.UserInfo, doc.UserInfoEndpoint)
+		override(&e.Introspect, doc.IntrospectionEndpoint)
+		override(&e.Revoke, doc.RevocationEndpoint)
+		if s.jwksEndpoint == "" {
+			override(&e.JWKS, doc.JWKSURI)
+		}
+		if s.parEndpoint == "" {
+			override(&e.PAR, doc.PAREndpoint)
+		}
+	}
+
+	s.endpointsMu.Lock()
+	s.endpoints = e
+	s.endpointsMu.Unlock()
+	return err
+}
+
+// fetchDiscovery 获取并解析发现文档
+func (s *OAuth2Service) fetchDiscovery(ctx context.Context) (*DiscoveryDocument, error) {
+	discoveryURL := s.oauth2Server + "/.well-known/openid-configuration"
+
+	req, err := http.NewRequestWithContext(ctx, "GET", discoveryURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 发送请求失败: %w", ErrServerUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("%w: 获取发现文档失败，HTTP 状态码: %d", ErrServerUnavailable, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("获取发现文档失败，HTTP 状态码: %d", resp.StatusCode)
+	}
+
+	var doc DiscoveryDocument
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("解析发现文档失败: %w", err)
+	}
+	return &doc, nil
+}
+
 // ExchangeCodeForToken 使用授权码换取访问令牌
 //
 // 参数 code 为 OAuth2 授权服务器返回的授权码
@@ -70,7 +273,7 @@ func (s *OAuth2Service) ExchangeCodeForToken(code string) (*TokenResponse, error
 		return nil, fmt.Errorf("授权码不能为空")
 	}
 
-	tokenURL := s.oauth2Server + "/oauth2/token"
+	tokenURL := s.Endpoints().Token
 
 	formData := url.Values{}
 	formData.Set("grant_type", "authorization_code")
@@ -79,14 +282,11 @@ func (s *OAuth2Service) ExchangeCodeForToken(code string) (*TokenResponse, error
 	formData.Set("client_secret", s.clientSecret)
 	formData.Set("redirect_uri", s.redirectURI)
 
-	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(formData.Encode()))
+	req, err := s.newTokenRequest(tokenURL, formData)
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Accept", "application/json")
-
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
@@ -106,16 +306,7 @@ func (s *OAuth2Service) ExchangeCodeForToken(code string) (*TokenResponse, error
 		return nil, fmt.Errorf("令牌交换失败，HTTP 状态码: %d", resp.StatusCode)
 	}
 
-	var tokenResp TokenResponseBody
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return nil, fmt.Errorf("解析令牌响应失败: %w", err)
-	}
-
-	if tokenResp.AccessToken == "" {
-		return nil, fmt.Errorf("令牌响应中缺少 access_token")
-	}
-
-	return tokenResp.ToTokenResponse(), nil
+	return parseTokenResponse(body)
 }
 
 // GetUserInfo 使用访问令牌获取用户信息
@@ -127,7 +318,7 @@ func (s *OAuth2Service) GetUserInfo(accessToken string) (*UserInfo, error) {
 		return nil, fmt.Errorf("访问令牌不能为空")
 	}
 
-	userInfoURL := s.oauth2Server + "/oauth2/userinfo"
+	userInfoURL := s.Endpoints().UserInfo
 
 	req, err := http.NewRequest("GET", userInfoURL, nil)
 	if err != nil {
@@ -139,7 +330,7 @@ func (s *OAuth2Service) GetUserInfo(accessToken string) (*UserInfo, error) {
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("发送请求失败: %w", err)
+		return nil, fmt.Errorf("%w: 发送请求失败: %w", ErrServerUnavailable, err)
 	}
 	defer resp.Body.Close()
 
@@ -148,6 +339,10 @@ func (s *OAuth2Service) GetUserInfo(accessToken string) (*UserInfo, error) {
 		return nil, fmt.Errorf("读取响应失败: %w", err)
 	}
 
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("%w: 获取用户信息失败，HTTP 状态码: %d", ErrServerUnavailable, resp.StatusCode)
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		var oauthErr OAuth2Error
 		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Code != "" {
@@ -173,7 +368,7 @@ func (s *OAuth2Service) RefreshToken(refreshToken string) (*TokenResponse, error
 		return nil, fmt.Errorf("刷新令牌不能为空")
 	}
 
-	tokenURL := s.oauth2Server + "/oauth2/token"
+	tokenURL := s.Endpoints().Token
 
 	formData := url.Values{}
 	formData.Set("grant_type", "refresh_token")
@@ -181,14 +376,11 @@ func (s *OAuth2Service) RefreshToken(refreshToken string) (*TokenResponse, error
 	formData.Set("client_id", s.clientID)
 	formData.Set("client_secret", s.clientSecret)
 
-	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(formData.Encode()))
+	req, err := s.newTokenRequest(tokenURL, formData)
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Accept", "application/json")
-
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
@@ -208,16 +400,62 @@ func (s *OAuth2Service) RefreshToken(refreshToken string) (*TokenResponse, error
 		return nil, fmt.Errorf("令牌刷新失败，HTTP 状态码: %d", resp.StatusCode)
 	}
 
-	var tokenResp TokenResponseBody
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return nil, fmt.Errorf("解析令牌响应失败: %w", err)
+	return parseTokenResponse(body)
+}
+
+// ExchangeToken 使用令牌交换（RFC 8693）获取新的令牌，用于服务间委托调用
+//
+// 参数 subjectToken 为被交换的令牌，subjectTokenType 为其类型（如 TokenTypeAccessToken）；
+// requestedTokenType 与 audience 为空时不发送，由服务器决定
+// 返回的 TokenResponse.IssuedTokenType 为实际颁发的令牌类型
+func (s *OAuth2Service) ExchangeToken(subjectToken, subjectTokenType, requestedTokenType, audience string) (*TokenResponse, error) {
+	if subjectToken == "" {
+		return nil, fmt.Errorf("主体令牌不能为空")
+	}
+	if subjectTokenType == "" {
+		return nil, fmt.Errorf("主体令牌类型不能为空")
 	}
 
-	if tokenResp.AccessToken == "" {
-		return nil, fmt.Errorf("令牌响应中缺少 access_token")
+	tokenURL := s.Endpoints().Token
+
+	formData := url.Values{}
+	formData.Set("grant_type", GrantTypeTokenExchange)
+	formData.Set("subject_token", subjectToken)
+	formData.Set("subject_token_type", subjectTokenType)
+	if requestedTokenType != "" {
+		formData.Set("requested_token_type", requestedTokenType)
+	}
+	if audience != "" {
+		formData.Set("audience", audience)
 	}
+	formData.Set("client_id", s.clientID)
+	formData.Set("client_secret", s.clientSecret)
 
-	return tokenResp.ToTokenResponse(), nil
+	req, err := s.newTokenRequest(tokenURL, formData)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr OAuth2Error
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Code != "" {
+			return nil, fmt.Errorf("OAuth2 错误: %w", &oauthErr)
+		}
+		return nil, fmt.Errorf("令牌交换失败，HTTP 状态码: %d", resp.StatusCode)
+	}
+
+	return parseTokenResponse(body)
 }
 
 // GetConfig 获取 OAuth2 公开配置
@@ -244,7 +482,7 @@ func (s *OAuth2Service) IntrospectToken(token string) (bool, error) {
 		return false, fmt.Errorf("令牌不能为空")
 	}
 
-	introspectURL := s.oauth2Server + "/oauth2/introspect"
+	introspectURL := s.Endpoints().Introspect
 
 	formData := url.Values{}
 	formData.Set("token", token)
@@ -285,11 +523,49 @@ func (s *OAuth2Service) IntrospectToken(token string) (bool, error) {
 	return result.Active, nil
 }
 
+// FetchJWKS 获取 OAuth2 服务器的 JWKS 原始内容
+//
+// 返回未经修改的响应体，供前端校验 ID Token 签名；响应不是包含 keys 的 JSON 时返回错误
+func (s *OAuth2Service) FetchJWKS() ([]byte, error) {
+	req, err := http.NewRequest("GET", s.Endpoints().JWKS, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 发送请求失败: %w", ErrServerUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("%w: 获取 JWKS 失败，HTTP 状态码: %d", ErrServerUnavailable, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("获取 JWKS 失败，HTTP 状态码: %d", resp.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &jwks); err != nil || jwks.Keys == nil {
+		return nil, fmt.Errorf("解析 JWKS 失败: 响应不是有效的 JWK Set")
+	}
+
+	return body, nil
+}
+
 // 构建授权 URL
 //
 // 用于生成 OAuth2 授权页面的 URL，供前端跳转使用
 func (s *OAuth2Service) BuildAuthorizeURL(state string, scope string) string {
-	authURL := s.oauth2Server + "/oauth2/authorize"
+	authURL := s.Endpoints().Authorize
 
 	params := url.Values{}
 	params.Set("client_id", s.clientID)
@@ -303,3 +579,233 @@ func (s *OAuth2Service) BuildAuthorizeURL(state string, scope string) string {
 
 	return authURL + "?" + params.Encode()
 }
+
+// BuildAuthorizeURLWithNonce 构建带 nonce 的授权 URL（OpenID Connect）
+//
+// nonce 应通过 GenerateNonce 生成并保存到 NonceStore，回调后用于 VerifyIDToken 校验
+func (s *OAuth2Service) BuildAuthorizeURLWithNonce(state, scope, nonce string) string {
+	return s.BuildAuthorizeURL(state, scope) + "&" + url.Values{"nonce": {nonce}}.Encode()
+}
+
+// VerifyIDToken 解析并校验 ID Token
+//
+// 校验 nonce 与 expectedNonce 一致（为空时要求令牌也不含 nonce）、aud 包含本客户端 ID、
+// 多受众时 azp 为本客户端，以及 exp 未过期。
+// 不校验签名：适用于通过 TLS 直接从 token 端点获取的 ID Token（OpenID Connect Core 3.1.3.7）
+func (s *OAuth2Service) VerifyIDToken(idToken, expectedNonce string) (*IDTokenClaims, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: 格式错误", ErrInvalidIDToken)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("%w: 解码载荷失败: %w", ErrInvalidIDToken, err)
+	}
+
+	var claims IDTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: 解析声明失败: %w", ErrInvalidIDToken, err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(expectedNonce)) != 1 {
+		return nil, fmt.Errorf("%w: nonce 不匹配", ErrInvalidIDToken)
+	}
+
+	if !claims.Audience.Contains(s.clientID) {
+		return nil, fmt.Errorf("%w: 受众不包含客户端 %s", ErrInvalidIDToken, s.clientID)
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != s.clientID {
+		return nil, fmt.Errorf("%w: azp 与客户端不符", ErrInvalidIDToken)
+	}
+
+	if claims.ExpiresAt == 0 || time.Now().Unix() >= claims.ExpiresAt {
+		return nil, fmt.Errorf("%w: 已过期", ErrInvalidIDToken)
+	}
+
+	return &claims, nil
+}
+
+// GenerateNonce 生成随机 nonce
+func GenerateNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成 nonce 失败: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// MemoryNonceStore 基于内存的 NonceStore 实现
+//
+// 适用于单实例部署，过期条目在保存新 nonce 时清理
+type MemoryNonceStore struct {
+	mu    sync.Mutex
+	items map[string]nonceItem
+}
+
+type nonceItem struct {
+	nonce    string
+	expireAt time.Time
+}
+
+// NewMemoryNonceStore 创建内存 nonce 存储
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{items: make(map[string]nonceItem)}
+}
+
+// Save 保存 nonce，ttl 后失效
+func (m *MemoryNonceStore) Save(key, nonce string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for k, item := range m.items {
+		if now.After(item.expireAt) {
+			delete(m.items, k)
+		}
+	}
+	m.items[key] = nonceItem{nonce: nonce, expireAt: now.Add(ttl)}
+	return nil
+}
+
+// Pop 取出并删除 nonce，已过期或不存在时返回 false
+func (m *MemoryNonceStore) Pop(key string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.items[key]
+	if !ok {
+		return "", false
+	}
+	delete(m.items, key)
+	if time.Now().After(item.expireAt) {
+		return "", false
+	}
+	return item.nonce, true
+}
+
+// PushAuthorizationRequest 推送授权请求（PAR，RFC 9126）
+//
+// 将授权参数（如 state、scope）连同客户端凭据 POST 到 PAR 端点，返回 request_uri，
+// 之后使用 BuildAuthorizeURLFromPAR 生成授权跳转地址
+func (s *OAuth2Service) PushAuthorizationRequest(params url.Values) (string, error) {
+	formData := url.Values{}
+	for k, v := range params {
+		formData[k] = v
+	}
+	formData.Set("client_id", s.clientID)
+	formData.Set("client_secret", s.clientSecret)
+	if formData.Get("redirect_uri") == "" {
+		formData.Set("redirect_uri", s.redirectURI)
+	}
+	if formData.Get("response_type") == "" {
+		formData.Set("response_type", "code")
+	}
+
+	req, err := http.NewRequest("POST", s.Endpoints().PAR, strings.NewReader(formData.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// RFC 9126 规定成功时返回 201，部分服务器返回 200
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		var oauthErr OAuth2Error
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Code != "" {
+			return "", fmt.Errorf("OAuth2 错误: %w", &oauthErr)
+		}
+		return "", fmt.Errorf("推送授权请求失败，HTTP 状态码: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		RequestURI string `json:"request_uri"`
+		ExpiresIn  int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	if result.RequestURI == "" {
+		return "", fmt.Errorf("PAR 响应中缺少 request_uri")
+	}
+
+	return result.RequestURI, nil
+}
+
+// BuildAuthorizeURLFromPAR 使用 PAR 返回的 request_uri 构建授权 URL
+//
+// 授权参数已推送到服务器，URL 中只包含 client_id 和 request_uri
+func (s *OAuth2Service) BuildAuthorizeURLFromPAR(requestURI string) string {
+	authURL := s.Endpoints().Authorize
+
+	params := url.Values{}
+	params.Set("client_id", s.clientID)
+	params.Set("request_uri", requestURI)
+
+	return authURL + "?" + params.Encode()
+}
+
+// newTokenRequest 按配置的编码方式构建 token 端点请求
+func (s *OAuth2Service) newTokenRequest(tokenURL string, params url.Values) (*http.Request, error) {
+	var req *http.Request
+	var err error
+
+	if s.tokenEncoding == TokenEncodingJSON {
+		fields := make(map[string]string, len(params))
+		for k := range params {
+			fields[k] = params.Get(k)
+		}
+		data, marshalErr := json.Marshal(fields)
+		if marshalErr != nil {
+			return nil, marshalErr
+		}
+		req, err = http.NewRequest("POST", tokenURL, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req, err = http.NewRequest("POST", tokenURL, strings.NewReader(params.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	req.Header.Set("Accept", "application/json")
+	return req, nil
+}
+
+// parseTokenResponse 解析 token 端点返回的 200 响应体
+//
+// 部分 OAuth2 服务器在 200 响应中返回 {"error": "..."}，此时返回 *OAuth2Error
+func parseTokenResponse(body []byte) (*TokenResponse, error) {
+	var oauthErr OAuth2Error
+	if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Code != "" {
+		return nil, fmt.Errorf("OAuth2 错误: %w", &oauthErr)
+	}
+
+	var tokenResp TokenResponseBody
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, fmt.Errorf("解析令牌响应失败: %w", err)
+	}
+
+	if tokenResp.AccessToken == "" {
+		return nil, fmt.Errorf("令牌响应中缺少 access_token")
+	}
+
+	return tokenResp.ToTokenResponse(), nil
+}
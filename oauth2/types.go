@@ -1,21 +1,27 @@
 // Package oauth2 提供 OAuth2 授权码模式登录的后端实现
 //
 // 该模块完全依赖外部 OAuth2 服务器颁发的访问令牌进行身份验证，
-// 不使用 JWT。包含配置管理、授权码换令牌、用户信息获取、令牌刷新等功能。
+// 访问令牌不使用 JWT。包含配置管理、授权码换令牌、用户信息获取、令牌刷新等功能，
+// 以及 OpenID Connect ID Token 的 nonce 校验。
 package oauth2
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // TokenResponse OAuth2 令牌响应
 //
 // 包含访问令牌、刷新令牌及相关过期信息
 type TokenResponse struct {
-	AccessToken      string `json:"access_token"`       // 访问令牌
-	TokenType        string `json:"token_type"`         // 令牌类型（通常为 Bearer）
-	ExpiresIn        int64  `json:"expires_in"`         // 访问令牌有效期（秒）
-	RefreshToken     string `json:"refresh_token"`      // 刷新令牌
-	RefreshExpiresIn int64  `json:"refresh_expires_in"` // 刷新令牌有效期（秒）
-	Scope            string `json:"scope,omitempty"`    // 权限范围
+	AccessToken      string `json:"access_token"`                // 访问令牌
+	TokenType        string `json:"token_type"`                  // 令牌类型（通常为 Bearer）
+	ExpiresIn        int64  `json:"expires_in"`                  // 访问令牌有效期（秒）
+	RefreshToken     string `json:"refresh_token"`               // 刷新令牌
+	RefreshExpiresIn int64  `json:"refresh_expires_in"`          // 刷新令牌有效期（秒）
+	Scope            string `json:"scope,omitempty"`             // 权限范围
+	IDToken          string `json:"id_token,omitempty"`          // OpenID Connect ID Token（请求 openid scope 时返回）
+	IssuedTokenType  string `json:"issued_token_type,omitempty"` // 令牌交换（RFC 8693）颁发的令牌类型
 }
 
 // ExpiresAt 返回访问令牌的过期时间
@@ -89,11 +95,37 @@ func (c *Config) ToPublic() PublicConfig {
 	}
 }
 
+// Endpoints OAuth2 服务器各端点地址
+//
+// 默认由 Server 拼接固定路径得到，调用 OAuth2Service.LoadDiscovery 后以发现文档为准
+type Endpoints struct {
+	Authorize  string // 授权端点，默认 {Server}/oauth2/authorize
+	Token      string // token 端点，默认 {Server}/oauth2/token
+	UserInfo   string // 用户信息端点，默认 {Server}/oauth2/userinfo
+	Introspect string // 令牌内省端点，默认 {Server}/oauth2/introspect
+	Revoke     string // 令牌撤销端点，默认 {Server}/oauth2/revoke
+	JWKS       string // JWKS 端点，默认 {Server}/.well-known/jwks.json
+	PAR        string // 推送授权请求端点，默认 {Server}/oauth2/par
+}
+
+// DiscoveryDocument OIDC 发现文档（/.well-known/openid-configuration）中使用的字段
+type DiscoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	IntrospectionEndpoint string `json:"introspection_endpoint,omitempty"`
+	RevocationEndpoint    string `json:"revocation_endpoint,omitempty"`
+	JWKSURI               string `json:"jwks_uri"`
+	PAREndpoint           string `json:"pushed_authorization_request_endpoint,omitempty"`
+}
+
 // CallbackRequest 授权码回调请求
 //
 // 前端发送授权码的请求体
 type CallbackRequest struct {
-	Code string `json:"code" binding:"required"` // 授权码
+	Code  string `json:"code" binding:"required"` // 授权码
+	State string `json:"state,omitempty"`         // 授权回调返回的 state，CallbackWithCookieState 使用
 }
 
 // RefreshRequest 刷新令牌请求
@@ -113,6 +145,8 @@ type TokenResponseBody struct {
 	RefreshToken     string `json:"refresh_token"`
 	RefreshExpiresIn int64  `json:"refresh_expires_in"`
 	Scope            string `json:"scope"`
+	IDToken          string `json:"id_token"`
+	IssuedTokenType  string `json:"issued_token_type"`
 }
 
 // ToTokenResponse 转换为 TokenResponse
@@ -124,5 +158,61 @@ func (b *TokenResponseBody) ToTokenResponse() *TokenResponse {
 		RefreshToken:     b.RefreshToken,
 		RefreshExpiresIn: b.RefreshExpiresIn,
 		Scope:            b.Scope,
+		IDToken:          b.IDToken,
+		IssuedTokenType:  b.IssuedTokenType,
+	}
+}
+
+// IDTokenClaims ID Token 中的标准声明
+//
+// 由 VerifyIDToken 解析并校验后返回
+type IDTokenClaims struct {
+	Issuer          string   `json:"iss"`             // 签发者
+	Subject         string   `json:"sub"`             // 用户唯一标识
+	Audience        Audience `json:"aud"`             // 受众（客户端 ID）
+	ExpiresAt       int64    `json:"exp"`             // 过期时间（Unix 秒）
+	IssuedAt        int64    `json:"iat"`             // 签发时间（Unix 秒）
+	Nonce           string   `json:"nonce,omitempty"` // 授权请求中发送的 nonce
+	AuthorizedParty string   `json:"azp,omitempty"`   // 被授权方，多受众时必须为本客户端
+}
+
+// Audience ID Token 的 aud 声明
+//
+// 规范允许单个字符串或字符串数组，统一解析为切片
+type Audience []string
+
+// UnmarshalJSON 兼容字符串和字符串数组两种格式
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var multi []string
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return err
+	}
+	*a = multi
+	return nil
+}
+
+// Contains 判断受众中是否包含指定客户端 ID
+func (a Audience) Contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
 	}
+	return false
+}
+
+// NonceStore nonce 存储接口
+//
+// 发起授权时以 state 为键保存 nonce，回调时取出后传给 VerifyIDToken，
+// 可替换为 Redis 等实现以支持多实例部署
+type NonceStore interface {
+	// Save 保存 nonce，ttl 后自动失效
+	Save(key, nonce string, ttl time.Duration) error
+	// Pop 取出并删除 nonce，保证每个 nonce 只能使用一次
+	Pop(key string) (string, bool)
 }
@@ -17,12 +17,18 @@ package uf
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Client UF API 客户端
@@ -32,6 +38,15 @@ import (
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	maxRetries int
+	maxPages   int
+	maxWait    time.Duration
+	sleep      func(ctx context.Context, d time.Duration) error
+	validator  func(resp *RawResponse) error
+	useNumber  bool
+	acceptGzip bool
+	baseCtx    context.Context
+	budget     *retryBudget
 }
 
 // ClientOption 客户端配置选项函数
@@ -49,11 +64,16 @@ type ClientOption func(*Client)
 //	uf.NewClient(
 //	    uf.WithTimeout(60 * time.Second),     // 自定义超时
 //	    uf.WithHTTPClient(customClient),        // 自定义 HTTP 客户端
+//	    uf.WithRetry(3),                        // 被限流时最多重试 3 次
 //	)
 func NewClient(opts ...ClientOption) *Client {
 	client := &Client{
 		baseURL:    DefaultBaseURL,
 		httpClient: &http.Client{Timeout: DefaultTimeout},
+		maxPages:   DefaultMaxPages,
+		maxWait:    DefaultMaxRetryAfter,
+		sleep:      sleepContext,
+		baseCtx:    context.Background(),
 	}
 
 	for _, opt := range opts {
@@ -67,10 +87,17 @@ func NewClient(opts ...ClientOption) *Client {
 //
 // 参数 softwareId 为软件 ID。
 // 返回活跃度记录响应和错误。
-func (c *Client) RecordActivity(softwareId uint) (*ActivityResponse, error) {
+func (c *Client) RecordActivity(softwareId SoftwareID) (*ActivityResponse, error) {
+	return c.RecordActivityContext(context.Background(), softwareId)
+}
+
+// RecordActivityContext 使用指定上下文记录软件活跃度
+//
+// ctx 与 WithContext 设置的基础上下文同时生效，任一方取消即中止请求。
+func (c *Client) RecordActivityContext(ctx context.Context, softwareId SoftwareID) (*ActivityResponse, error) {
 	req := &ActivityRequest{SoftwareID: softwareId}
 	resp := &ActivityResponse{}
-	err := c.doJSONRequest(http.MethodPost, "/api/activity", req, resp)
+	err := c.doJSONRequest(ctx, http.MethodPost, "/api/activity", req, resp)
 	return resp, err
 }
 
@@ -78,25 +105,204 @@ func (c *Client) RecordActivity(softwareId uint) (*ActivityResponse, error) {
 //
 // 参数 softwareId 为软件 ID，machineCode 为机器码。
 // 返回激活检查响应和错误。
-func (c *Client) CheckActivation(softwareId uint, machineCode string) (*ActivationCheckResponse, error) {
+func (c *Client) CheckActivation(softwareId SoftwareID, machineCode string) (*ActivationCheckResponse, error) {
+	return c.CheckActivationContext(context.Background(), softwareId, machineCode)
+}
+
+// CheckActivationContext 使用指定上下文检查软件激活状态
+//
+// ctx 与 WithContext 设置的基础上下文同时生效，任一方取消即中止请求。
+func (c *Client) CheckActivationContext(ctx context.Context, softwareId SoftwareID, machineCode string) (*ActivationCheckResponse, error) {
 	req := &ActivationCheckRequest{
 		SoftwareID:  softwareId,
 		MachineCode: machineCode,
 	}
 	resp := &ActivationCheckResponse{}
-	err := c.doJSONRequest(http.MethodPost, "/api/activation/check", req, resp)
+	err := c.doJSONRequest(ctx, http.MethodPost, "/api/activation/check", req, resp)
 	return resp, err
 }
 
+// IsActivated 检查软件是否已激活，仅返回激活布尔值
+//
+// 请求成功时返回 resp.Activated 和 nil。网络错误、超时、服务器错误或
+// 响应 ok 为 false 等无法确定激活状态的情况下，返回 failOpen 和对应错误：
+// failOpen 为 true 时放行（fail-open），为 false 时拒绝（fail-closed），
+// 调用方可直接使用返回值作为授权判断，同时按需记录错误。
+func (c *Client) IsActivated(softwareId SoftwareID, machineCode string, failOpen bool) (bool, error) {
+	return c.IsActivatedContext(context.Background(), softwareId, machineCode, failOpen)
+}
+
+// IsActivatedContext 使用指定上下文检查软件是否已激活
+//
+// 语义同 IsActivated；ctx 与 WithContext 设置的基础上下文同时生效。
+func (c *Client) IsActivatedContext(ctx context.Context, softwareId SoftwareID, machineCode string, failOpen bool) (bool, error) {
+	resp, err := c.CheckActivationContext(ctx, softwareId, machineCode)
+	if err != nil {
+		return failOpen, err
+	}
+	if !resp.IsOK() {
+		msg := resp.Error
+		if msg == "" {
+			msg = "激活检查失败"
+		}
+		return failOpen, NewServerError(msg)
+	}
+	return resp.Activated, nil
+}
+
+// RecordActivityBatch 并发记录多个软件的活跃度
+//
+// 返回的结果与 softwareIds 一一对应、顺序一致，Key 为软件 ID 的十进制字符串；
+// 单个条目失败不影响其他条目，错误记录在对应结果的 Err 中。
+// 最多同时发起 DefaultBatchConcurrency 个请求。
+func (c *Client) RecordActivityBatch(ctx context.Context, softwareIds []SoftwareID) []ItemResult[*ActivityResponse] {
+	results := make([]ItemResult[*ActivityResponse], len(softwareIds))
+	runBatch(len(softwareIds), func(i int) {
+		resp, err := c.RecordActivityContext(ctx, softwareIds[i])
+		results[i] = ItemResult[*ActivityResponse]{
+			Key:   softwareIds[i].String(),
+			Value: resp,
+			Err:   err,
+		}
+	})
+	return results
+}
+
+// CheckActivationMany 并发检查同一软件下多个机器码的激活状态
+//
+// 返回的结果与 machineCodes 一一对应、顺序一致，Key 为机器码；
+// 单个条目失败不影响其他条目，错误记录在对应结果的 Err 中。
+// 最多同时发起 DefaultBatchConcurrency 个请求。
+func (c *Client) CheckActivationMany(ctx context.Context, softwareId SoftwareID, machineCodes []string) []ItemResult[*ActivationCheckResponse] {
+	results := make([]ItemResult[*ActivationCheckResponse], len(machineCodes))
+	runBatch(len(machineCodes), func(i int) {
+		resp, err := c.CheckActivationContext(ctx, softwareId, machineCodes[i])
+		results[i] = ItemResult[*ActivationCheckResponse]{
+			Key:   machineCodes[i],
+			Value: resp,
+			Err:   err,
+		}
+	})
+	return results
+}
+
+// runBatch 以 DefaultBatchConcurrency 的并发度对 [0, n) 执行 fn，全部完成后返回
+func runBatch(n int, fn func(i int)) {
+	sem := make(chan struct{}, DefaultBatchConcurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// DoListAll 请求列表接口并自动跟随分页
+//
+// 以 GET 请求 path，每页响应按 ListPage 解码后将 Items 交给 appendFn，
+// 再以响应中的 next 游标请求下一页，直到 next 为空。
+// appendFn 返回错误或任一页请求失败时立即停止并返回该错误；
+// 请求页数超过 WithMaxPages 上限时返回 ErrCodeRequestFailed 错误。
+func (c *Client) DoListAll(path string, opts ListOptions, appendFn func(raw json.RawMessage) error) error {
+	return c.DoListAllContext(context.Background(), path, opts, appendFn)
+}
+
+// DoListAllContext 使用指定上下文请求列表接口并自动跟随分页
+//
+// ctx 与 WithContext 设置的基础上下文同时生效，任一方取消即停止翻页。
+// path 可以自带查询串，与 opts.Query、limit、cursor 合并，同名参数以后者为准。
+func (c *Client) DoListAllContext(ctx context.Context, path string, opts ListOptions, appendFn func(raw json.RawMessage) error) error {
+	u, err := url.Parse(path)
+	if err != nil {
+		return NewParamsError(fmt.Sprintf("解析路径失败: %v", err))
+	}
+
+	cursor := opts.Cursor
+	for page := 0; ; page++ {
+		if page >= c.maxPages {
+			return NewRequestError(fmt.Sprintf("分页数超过上限 %d", c.maxPages), nil)
+		}
+
+		query := u.Query()
+		for k, v := range opts.Query {
+			query[k] = v
+		}
+		if opts.Limit > 0 {
+			query.Set("limit", strconv.Itoa(opts.Limit))
+		}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		pageURL := *u
+		pageURL.RawQuery = query.Encode()
+		pagePath := pageURL.String()
+
+		var resp ListPage
+		if err := c.doJSONRequest(ctx, http.MethodGet, pagePath, nil, &resp); err != nil {
+			return err
+		}
+		if err := appendFn(resp.Items); err != nil {
+			return err
+		}
+		if resp.Next == "" {
+			return nil
+		}
+		cursor = resp.Next
+	}
+}
+
 // buildURL 构建完整请求 URL
 func (c *Client) buildURL(path string) string {
 	path = strings.TrimLeft(path, "/")
 	return c.baseURL + "/" + path
 }
 
+// callTimeoutKey 单次调用超时上下文中的键，值为施加超时前的上下文，用于区分超时与取消
+type callTimeoutKey struct{}
+
+// requestContext 将请求级上下文与客户端基础上下文合并
+//
+// 返回的上下文在任一方结束时取消，调用方须在请求完成后调用 cancel。
+// ctx 通过 WithRequestTimeout 设置了超时时，在合并后的上下文上施加一次截止时间，
+// 该次调用的所有请求与限流等待共用这一截止时间。
+func (c *Client) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	merged, cancel := context.WithCancel(ctx)
+	if c.baseCtx.Done() != nil {
+		go func() {
+			select {
+			case <-c.baseCtx.Done():
+				cancel()
+			case <-merged.Done():
+			}
+		}()
+	}
+
+	timeout, ok := ctx.Value(requestTimeoutKey{}).(time.Duration)
+	if !ok || timeout <= 0 {
+		return merged, cancel
+	}
+	timed, timedCancel := context.WithTimeout(merged, timeout)
+	return context.WithValue(timed, callTimeoutKey{}, merged), func() {
+		timedCancel()
+		cancel()
+	}
+}
+
 // doRequest 发起 HTTP 请求
-func (c *Client) doRequest(method, path string, body io.Reader) (*http.Response, error) {
-	req, err := http.NewRequest(method, c.buildURL(path), body)
+func (c *Client) doRequest(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	if c.contextErr(ctx) != nil {
+		return nil, c.canceledError(ctx)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.buildURL(path), body)
 	if err != nil {
 		return nil, NewRequestError(fmt.Sprintf("创建请求失败: %v", err), err)
 	}
@@ -105,9 +311,15 @@ func (c *Client) doRequest(method, path string, body io.Reader) (*http.Response,
 		req.Header.Set("Content-Type", "application/json")
 	}
 	req.Header.Set("Accept", "application/json")
+	if c.acceptGzip {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.httpClientFor(ctx).Do(req)
 	if err != nil {
+		if c.contextErr(ctx) != nil {
+			return nil, c.canceledError(ctx)
+		}
 		if urlErr, ok := err.(*url.Error); ok && urlErr.Timeout() {
 			return nil, NewTimeoutError(fmt.Sprintf("请求超时: %v", err))
 		}
@@ -117,41 +329,246 @@ func (c *Client) doRequest(method, path string, body io.Reader) (*http.Response,
 	return resp, nil
 }
 
+// httpClientFor 返回本次请求使用的 HTTP 客户端
+//
+// 调用设置了单次超时时截止时间由上下文控制，返回不带全局超时的浅拷贝（共享 Transport 与连接池），
+// 使单次超时可长于全局超时。
+func (c *Client) httpClientFor(ctx context.Context) *http.Client {
+	if ctx.Value(callTimeoutKey{}) == nil || c.httpClient.Timeout == 0 {
+		return c.httpClient
+	}
+	hc := *c.httpClient
+	hc.Timeout = 0
+	return &hc
+}
+
+// canceledError 将上下文结束转换为错误
+//
+// 单次调用超时到期返回 ErrCodeTimeout 错误，其余（取消、调用方自身的截止时间）返回请求已取消。
+func (c *Client) canceledError(ctx context.Context) error {
+	err := c.contextErr(ctx)
+	if parent, ok := ctx.Value(callTimeoutKey{}).(context.Context); ok && parent.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		return NewTimeoutError(fmt.Sprintf("请求超时: %v", err))
+	}
+	return NewRequestError(fmt.Sprintf("请求已取消: %v", err), err)
+}
+
+// contextErr 返回基础上下文或请求上下文的错误，优先报告基础上下文
+func (c *Client) contextErr(ctx context.Context) error {
+	if err := c.baseCtx.Err(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
 // doJSONRequest 发起 JSON 请求并解析响应
-func (c *Client) doJSONRequest(method, path string, reqBody, respBody interface{}) error {
-	var body io.Reader
+//
+// 当服务端返回 429 且启用了重试时，按 Retry-After 等待后重新发起请求。
+func (c *Client) doJSONRequest(ctx context.Context, method, path string, reqBody, respBody interface{}) error {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	var data []byte
 	if reqBody != nil {
-		data, err := json.Marshal(reqBody)
+		var err error
+		data, err = json.Marshal(reqBody)
 		if err != nil {
 			return NewParamsError(fmt.Sprintf("序列化请求体失败: %v", err))
 		}
-		body = bytes.NewReader(data)
 	}
 
-	resp, err := c.doRequest(method, path, body)
-	if err != nil {
-		return err
+	c.budget.deposit()
+
+	for attempt := 0; ; attempt++ {
+		var body io.Reader
+		if data != nil {
+			body = bytes.NewReader(data)
+		}
+
+		resp, err := c.doRequest(ctx, method, path, body)
+		if err != nil {
+			return err
+		}
+
+		respBytes, err := c.readResponseBody(resp)
+		if err != nil {
+			return NewResponseError(fmt.Sprintf("读取响应失败: %v", err), err)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			wait := retryAfter
+			if !ok {
+				wait = DefaultRetryDelay
+			}
+			// 建议等待超过上限时不再重试，由调用方按 ErrRateLimited.RetryAfter 决定
+			if attempt < c.maxRetries && wait <= c.maxWait && c.budget.withdraw() {
+				if c.sleep(ctx, wait) != nil {
+					return c.canceledError(ctx)
+				}
+				continue
+			}
+			return NewRateLimitedError(fmt.Sprintf("请求被限流, 响应: %s", string(respBytes)), retryAfter)
+		}
+
+		if c.validator != nil {
+			raw := &RawResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: respBytes}
+			if err := c.validator(raw); err != nil {
+				return NewResponseError("响应校验失败", err)
+			}
+		}
+
+		return c.decodeResponse(resp.StatusCode, respBytes, respBody)
+	}
+}
+
+// sleepContext 等待 d，ctx 结束时提前返回 ctx.Err()
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
+}
+
+// readResponseBody 读取并关闭响应体
+//
+// 启用 WithAcceptGzip 且响应为 Content-Encoding: gzip 时返回解压后的内容。
+func (c *Client) readResponseBody(resp *http.Response) ([]byte, error) {
 	defer resp.Body.Close()
 
-	respBytes, err := io.ReadAll(resp.Body)
+	if !c.acceptGzip || !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return io.ReadAll(resp.Body)
+	}
+
+	zr, err := gzip.NewReader(resp.Body)
 	if err != nil {
-		return NewResponseError(fmt.Sprintf("读取响应失败: %v", err), err)
+		return nil, err
 	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+// decodeResponse 检查状态码并解析响应体
+//
+// 启用 WithUseNumber 时，动态类型字段中的数字解码为 json.Number 而非 float64。
+func (c *Client) decodeResponse(statusCode int, respBytes []byte, respBody interface{}) error {
+	if statusCode < 200 || statusCode >= 300 {
 		var errResp ErrorResponse
 		if json.Unmarshal(respBytes, &errResp) == nil && errResp.Error != "" {
 			return NewServerError(errResp.Error)
 		}
-		return NewServerError(fmt.Sprintf("HTTP 状态码: %d, 响应: %s", resp.StatusCode, string(respBytes)))
+		return NewServerError(fmt.Sprintf("HTTP 状态码: %d, 响应: %s", statusCode, string(respBytes)))
 	}
 
 	if respBody != nil {
-		if err := json.Unmarshal(respBytes, respBody); err != nil {
+		dec := json.NewDecoder(bytes.NewReader(respBytes))
+		if c.useNumber {
+			dec.UseNumber()
+		}
+		if err := dec.Decode(respBody); err != nil {
 			return NewResponseError(fmt.Sprintf("解析响应失败: %v", err), err)
 		}
 	}
 
 	return nil
 }
+
+// parseRetryAfter 解析 Retry-After 响应头
+//
+// 支持秒数（如 "120"）和 HTTP 日期（如 "Wed, 21 Oct 2015 07:28:00 GMT"）两种格式。
+// 日期已过去时返回 0；头部缺失或格式无效时第二个返回值为 false。
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// retryBudgetBurst 重试预算最多累积的请求数，限制长时间空闲后可突发的重试量
+const retryBudgetBurst = 100
+
+// retryBudget 客户端共享的令牌桶式重试预算
+//
+// 每个请求存入 ratio 个令牌，每秒另外补充 minPerSec 个令牌，每次重试消耗 1 个令牌；
+// 令牌不足时不再重试，使重试总量不超过请求量的 ratio 倍（加上每秒 minPerSec 次的保底）。
+// nil 表示不限制，所有方法均可在 nil 上调用。
+type retryBudget struct {
+	mu        sync.Mutex
+	ratio     float64
+	minPerSec float64
+	tokens    float64
+	last      time.Time
+	now       func() time.Time
+}
+
+// newRetryBudget 创建重试预算，初始令牌为 minPerSec 个
+func newRetryBudget(ratio float64, minPerSec int) *retryBudget {
+	b := &retryBudget{
+		ratio:     ratio,
+		minPerSec: float64(minPerSec),
+		tokens:    float64(minPerSec),
+		now:       time.Now,
+	}
+	b.last = b.now()
+	return b
+}
+
+// refill 按经过的时间补充保底令牌并限制上限，调用方需持有锁
+func (b *retryBudget) refill() {
+	now := b.now()
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.minPerSec
+	}
+	b.last = now
+	if limit := b.minPerSec + b.ratio*retryBudgetBurst; b.tokens > limit {
+		b.tokens = limit
+	}
+}
+
+// deposit 记录一次新请求，存入 ratio 个令牌
+func (b *retryBudget) deposit() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens += b.ratio
+	b.refill()
+}
+
+// withdraw 尝试为一次重试消耗 1 个令牌，预算不足时返回 false
+func (b *retryBudget) withdraw() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	// 允许浮点累加误差，如 10 次存入 0.1 应恰好足够 1 次重试
+	if b.tokens < 1-1e-9 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -1,8 +1,19 @@
 package uf
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -305,8 +316,8 @@ func containsHelper(s, substr string) bool {
 func TestClient_RecordActivity(t *testing.T) {
 	tests := []struct {
 		name       string
-		softwareID uint
-		wantID     uint
+		softwareID SoftwareID
+		wantID     uint64
 		wantOK     bool
 	}{
 		{
@@ -355,12 +366,12 @@ func TestClient_RecordActivity(t *testing.T) {
 // TestClient_CheckActivation 测试检查激活状态
 func TestClient_CheckActivation(t *testing.T) {
 	tests := []struct {
-		name           string
-		softwareID     uint
-		machineCode    string
-		wantOK         bool
-		wantActivated  bool
-		wantExpireAt   string
+		name          string
+		softwareID    SoftwareID
+		machineCode   string
+		wantOK        bool
+		wantActivated bool
+		wantExpireAt  string
 	}{
 		{
 			name:          "已激活",
@@ -502,3 +513,1076 @@ func TestActivationCheckResponse_HasError(t *testing.T) {
 		})
 	}
 }
+
+// ============================================================================
+// 限流重试测试
+// ============================================================================
+
+// TestParseRetryAfter 测试 Retry-After 解析
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"秒数", "120", 120 * time.Second, true},
+		{"零秒", "0", 0, true},
+		{"HTTP 日期", now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{"过去的 HTTP 日期", now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"空值", "", 0, false},
+		{"负数", "-5", 0, false},
+		{"无效格式", "soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("parseRetryAfter(%q) = (%v, %v), want (%v, %v)", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// TestClient_RateLimited 测试 429 响应返回 ErrRateLimited
+func TestClient_RateLimited(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter func() string
+		wantMin    time.Duration
+		wantMax    time.Duration
+	}{
+		{
+			name:       "秒数格式",
+			retryAfter: func() string { return "30" },
+			wantMin:    30 * time.Second,
+			wantMax:    30 * time.Second,
+		},
+		{
+			name: "HTTP 日期格式",
+			retryAfter: func() string {
+				return time.Now().Add(2 * time.Minute).UTC().Format(http.TimeFormat)
+			},
+			wantMin: time.Minute,
+			wantMax: 2 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", tt.retryAfter())
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte(`{"ok": false, "error": "too many requests"}`))
+			}))
+			defer server.Close()
+
+			client := NewClient(WithBaseURL(server.URL))
+			_, err := client.RecordActivity(1)
+
+			var rateErr *ErrRateLimited
+			if !errors.As(err, &rateErr) {
+				t.Fatalf("期望 *ErrRateLimited，实际 %v", err)
+			}
+			if rateErr.RetryAfter < tt.wantMin || rateErr.RetryAfter > tt.wantMax {
+				t.Errorf("RetryAfter = %v, want [%v, %v]", rateErr.RetryAfter, tt.wantMin, tt.wantMax)
+			}
+
+			var ufErr *Error
+			if !errors.As(err, &ufErr) || ufErr.Code != ErrCodeRateLimited {
+				t.Errorf("期望错误码 %s，实际 %v", ErrCodeRateLimited, err)
+			}
+		})
+	}
+}
+
+// TestClient_RateLimitedRetry 测试启用重试后按 Retry-After 等待并重试
+func TestClient_RateLimitedRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		wantSleep  time.Duration
+	}{
+		{"秒数格式", "3", 3 * time.Second},
+		{"缺少 Retry-After", "", DefaultRetryDelay},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls == 1 {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"ok": true, "id": 7}`))
+			}))
+			defer server.Close()
+
+			var slept []time.Duration
+			client := NewClient(WithBaseURL(server.URL), WithRetry(2))
+			client.sleep = func(_ context.Context, d time.Duration) error {
+				slept = append(slept, d)
+				return nil
+			}
+
+			resp, err := client.RecordActivity(1)
+			if err != nil {
+				t.Fatalf("RecordActivity() 错误 = %v", err)
+			}
+			if resp.ID != 7 {
+				t.Errorf("ID = %v, want 7", resp.ID)
+			}
+			if calls != 2 {
+				t.Errorf("请求次数 = %d, want 2", calls)
+			}
+			if len(slept) != 1 || slept[0] != tt.wantSleep {
+				t.Errorf("等待时长 = %v, want [%v]", slept, tt.wantSleep)
+			}
+		})
+	}
+}
+
+// TestClient_RateLimitedRetryExhausted 测试重试耗尽后返回 ErrRateLimited
+func TestClient_RateLimitedRetryExhausted(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithRetry(2))
+	client.sleep = func(context.Context, time.Duration) error { return nil }
+
+	_, err := client.CheckActivation(1, "ABC")
+
+	var rateErr *ErrRateLimited
+	if !errors.As(err, &rateErr) {
+		t.Fatalf("期望 *ErrRateLimited，实际 %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("请求次数 = %d, want 3", calls)
+	}
+}
+
+// TestClient_RateLimitedRetryCanceled 测试等待 Retry-After 期间取消上下文立即返回
+func TestClient_RateLimitedRetryCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		useBase bool
+	}{
+		{"请求上下文取消", false},
+		{"基础上下文取消", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			opts := []ClientOption{WithBaseURL(server.URL), WithRetry(2)}
+			reqCtx := context.Background()
+			if tt.useBase {
+				opts = append(opts, WithContext(ctx))
+			} else {
+				reqCtx = ctx
+			}
+			client := NewClient(opts...)
+
+			time.AfterFunc(50*time.Millisecond, cancel)
+			start := time.Now()
+			_, err := client.RecordActivityContext(reqCtx, 1)
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("取消后仍等待了 %v", elapsed)
+			}
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("期望 context.Canceled，实际 %v", err)
+			}
+		})
+	}
+}
+
+// TestClient_RateLimitedRetryMaxWait 测试 Retry-After 超过等待上限时不重试
+func TestClient_RateLimitedRetryMaxWait(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []ClientOption
+		wantCalls int
+	}{
+		{"默认上限", nil, 1},
+		{"自定义上限", []ClientOption{WithMaxRetryAfter(48 * time.Hour)}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Header().Set("Retry-After", "86400")
+				w.WriteHeader(http.StatusTooManyRequests)
+			}))
+			defer server.Close()
+
+			client := NewClient(append([]ClientOption{WithBaseURL(server.URL), WithRetry(2)}, tt.opts...)...)
+			client.sleep = func(context.Context, time.Duration) error { return nil }
+
+			_, err := client.RecordActivity(1)
+			var rateErr *ErrRateLimited
+			if !errors.As(err, &rateErr) {
+				t.Fatalf("期望 *ErrRateLimited，实际 %v", err)
+			}
+			if rateErr.RetryAfter != 24*time.Hour {
+				t.Errorf("RetryAfter = %v, want 24h", rateErr.RetryAfter)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("请求次数 = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+// TestClient_WithRetryBudget 测试高失败率下重试次数不超过共享预算
+func TestClient_WithRetryBudget(t *testing.T) {
+	tests := []struct {
+		name        string
+		ratio       float64
+		minPerSec   int
+		requests    int
+		wantRetries int64
+	}{
+		{"按请求比例", 0.1, 0, 100, 10},
+		{"每秒保底", 0, 5, 20, 5},
+		{"比例与保底叠加", 0.2, 3, 50, 13},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int64
+			client := NewClient(
+				WithRetry(3),
+				WithRetryBudget(tt.ratio, tt.minPerSec),
+				WithDryRun(func(req *http.Request) (*http.Response, error) {
+					atomic.AddInt64(&calls, 1)
+					return &http.Response{
+						StatusCode: http.StatusTooManyRequests,
+						Header:     http.Header{"Retry-After": {"0"}},
+						Body:       io.NopCloser(strings.NewReader("")),
+					}, nil
+				}),
+			)
+			client.sleep = func(context.Context, time.Duration) error { return nil }
+			// 固定时钟，排除测试执行期间的保底补充
+			now := time.Now()
+			client.budget.now = func() time.Time { return now }
+
+			for i := 0; i < tt.requests; i++ {
+				var rateErr *ErrRateLimited
+				if _, err := client.RecordActivity(1); !errors.As(err, &rateErr) {
+					t.Fatalf("期望 *ErrRateLimited，实际 %v", err)
+				}
+			}
+
+			if retries := calls - int64(tt.requests); retries != tt.wantRetries {
+				t.Errorf("重试次数 = %d, want %d（未限制时为 %d）", retries, tt.wantRetries, tt.requests*3)
+			}
+		})
+	}
+}
+
+// ============================================================================
+// 响应校验测试
+// ============================================================================
+
+// TestClient_ResponseValidator 测试响应校验函数拦截非 JSON 响应
+func TestClient_ResponseValidator(t *testing.T) {
+	requireJSON := func(resp *RawResponse) error {
+		if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+			return errors.New("非 JSON 响应: " + resp.Header.Get("Content-Type"))
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantErr     bool
+	}{
+		{"JSON 响应通过", "application/json", `{"ok": true, "id": 1}`, false},
+		{"HTML 错误页被拦截", "text/html; charset=utf-8", `<html><body>502 Bad Gateway</body></html>`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := NewClient(WithBaseURL(server.URL), WithResponseValidator(requireJSON))
+			_, err := client.RecordActivity(1)
+
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("RecordActivity() 错误 = %v", err)
+				}
+				return
+			}
+
+			var ufErr *Error
+			if !errors.As(err, &ufErr) || ufErr.Code != ErrCodeInvalidResponse {
+				t.Errorf("期望错误码 %s，实际 %v", ErrCodeInvalidResponse, err)
+			}
+		})
+	}
+}
+
+// ============================================================================
+// 演练模式测试
+// ============================================================================
+
+// TestClient_DryRun 测试演练模式拦截完整构造的请求
+func TestClient_DryRun(t *testing.T) {
+	var gotReq *http.Request
+	var gotBody []byte
+
+	client := NewClient(
+		WithBaseURL("https://dry-run.example.com/"),
+		WithDryRun(func(req *http.Request) (*http.Response, error) {
+			gotReq = req
+			gotBody, _ = io.ReadAll(req.Body)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(`{"ok": true, "activated": true}`)),
+				Request:    req,
+			}, nil
+		}),
+	)
+
+	resp, err := client.CheckActivation(42, "ABC-123-XYZ")
+	if err != nil {
+		t.Fatalf("CheckActivation() 错误 = %v", err)
+	}
+	if !resp.Activated {
+		t.Error("Activated 应为 true")
+	}
+
+	if gotReq == nil {
+		t.Fatal("演练函数未被调用")
+	}
+	if gotReq.Method != http.MethodPost {
+		t.Errorf("Method = %s, want POST", gotReq.Method)
+	}
+	if gotReq.URL.String() != "https://dry-run.example.com/api/activation/check" {
+		t.Errorf("URL = %s", gotReq.URL.String())
+	}
+	if gotReq.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type = %s", gotReq.Header.Get("Content-Type"))
+	}
+	if gotReq.Header.Get("Accept") != "application/json" {
+		t.Errorf("Accept = %s", gotReq.Header.Get("Accept"))
+	}
+
+	var body ActivationCheckRequest
+	if err := json.Unmarshal(gotBody, &body); err != nil {
+		t.Fatalf("解析请求体失败: %v", err)
+	}
+	if body.SoftwareID != 42 || body.MachineCode != "ABC-123-XYZ" {
+		t.Errorf("请求体 = %+v", body)
+	}
+}
+
+// ============================================================================
+// 大 ID 数字精度测试
+// ============================================================================
+
+// TestClient_LargeID 测试大 ID 强类型解码不丢失精度
+func TestClient_LargeID(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want uint64
+	}{
+		{"超过 2^53", `{"ok": true, "id": 9007199254740993}`, 9007199254740993},
+		{"uint64 最大值", `{"ok": true, "id": 18446744073709551615}`, 18446744073709551615},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := NewClient(WithBaseURL(server.URL))
+			resp, err := client.RecordActivity(1)
+			if err != nil {
+				t.Fatalf("RecordActivity() 错误 = %v", err)
+			}
+			if resp.ID != tt.want {
+				t.Errorf("ID = %d, want %d", resp.ID, tt.want)
+			}
+		})
+	}
+}
+
+// TestClient_UseNumber 测试 WithUseNumber 在动态类型中保留大数精度
+func TestClient_UseNumber(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true, "id": 9007199254740993}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		opts []ClientOption
+		want string
+	}{
+		{"默认 float64 丢失精度", nil, "9007199254740992"},
+		{"json.Number 保留精度", []ClientOption{WithUseNumber()}, "9007199254740993"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(append([]ClientOption{WithBaseURL(server.URL)}, tt.opts...)...)
+
+			var resp map[string]interface{}
+			if err := client.doJSONRequest(context.Background(), http.MethodPost, "/api/activity", nil, &resp); err != nil {
+				t.Fatalf("doJSONRequest() 错误 = %v", err)
+			}
+
+			var got string
+			switch v := resp["id"].(type) {
+			case json.Number:
+				got = v.String()
+			case float64:
+				got = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+			if got != tt.want {
+				t.Errorf("id = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestClient_WithContext 测试基础上下文取消后所有请求中止
+func TestClient_WithContext(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		// 模拟慢请求，直到请求被取消或测试结束
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	client := NewClient(WithBaseURL(server.URL), WithContext(baseCtx))
+
+	// 进行中的请求在基础上下文取消后中止
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.RecordActivity(1)
+		done <- err
+	}()
+	for atomic.LoadInt32(&hits) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancelBase()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("进行中的请求错误 = %v, 期望 context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("基础上下文取消后请求未中止")
+	}
+
+	// 之后的请求直接失败，不访问网络
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"RecordActivity", func() error { _, err := client.RecordActivity(1); return err }},
+		{"CheckActivationContext", func() error {
+			_, err := client.CheckActivationContext(context.Background(), 1, "ABC")
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := atomic.LoadInt32(&hits)
+			start := time.Now()
+			err := tt.call()
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("错误 = %v, 期望 context.Canceled", err)
+			}
+			var ufErr *Error
+			if !errors.As(err, &ufErr) || ufErr.Code != ErrCodeRequestFailed {
+				t.Errorf("错误码 = %v, 期望 %s", err, ErrCodeRequestFailed)
+			}
+			if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+				t.Errorf("请求未快速失败, 耗时 %v", elapsed)
+			}
+			if got := atomic.LoadInt32(&hits); got != before {
+				t.Errorf("取消后不应访问网络, 请求数 %d -> %d", before, got)
+			}
+		})
+	}
+}
+
+// TestClient_RequestContext 测试请求级上下文与基础上下文同时生效
+func TestClient_RequestContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewClient(WithBaseURL(server.URL), WithContext(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.RecordActivityContext(ctx, 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("错误 = %v, 期望 context.DeadlineExceeded", err)
+	}
+}
+
+// TestClient_RecordActivityBatch 测试批量记录活跃度的结果顺序与单项错误
+func TestClient_RecordActivityBatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ActivityRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		w.Header().Set("Content-Type", "application/json")
+		if req.SoftwareID == 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error": "软件不存在"}`))
+			return
+		}
+		w.Write([]byte(`{"ok": true, "id": ` + strconv.FormatUint(uint64(req.SoftwareID)*10, 10) + `}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL))
+	ids := []SoftwareID{5, 2, 1, 9, 3, 7}
+	results := client.RecordActivityBatch(context.Background(), ids)
+
+	if len(results) != len(ids) {
+		t.Fatalf("结果数 = %d, want %d", len(results), len(ids))
+	}
+	for i, id := range ids {
+		r := results[i]
+		if r.Key != strconv.FormatUint(uint64(id), 10) {
+			t.Errorf("results[%d].Key = %s, want %d", i, r.Key, id)
+		}
+		if id == 2 {
+			var ufErr *Error
+			if !errors.As(r.Err, &ufErr) || ufErr.Code != ErrCodeServerError {
+				t.Errorf("results[%d].Err = %v, 期望服务器错误", i, r.Err)
+			}
+			continue
+		}
+		if r.Err != nil {
+			t.Errorf("results[%d].Err = %v", i, r.Err)
+			continue
+		}
+		if r.Value.ID != uint64(id)*10 {
+			t.Errorf("results[%d].Value.ID = %d, want %d", i, r.Value.ID, id*10)
+		}
+	}
+}
+
+// TestClient_CheckActivationMany 测试批量检查激活状态的结果顺序与单项错误
+func TestClient_CheckActivationMany(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ActivationCheckRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		w.Header().Set("Content-Type", "application/json")
+		switch req.MachineCode {
+		case "BAD":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "机器码无效"}`))
+		case "ACTIVE":
+			w.Write([]byte(`{"ok": true, "activated": true}`))
+		default:
+			w.Write([]byte(`{"ok": true, "activated": false}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL))
+	codes := []string{"ACTIVE", "BAD", "INACTIVE", "ACTIVE"}
+	results := client.CheckActivationMany(context.Background(), 1, codes)
+
+	tests := []struct {
+		name          string
+		wantErr       bool
+		wantActivated bool
+	}{
+		{"已激活", false, true},
+		{"单项错误", true, false},
+		{"未激活", false, false},
+		{"重复机器码", false, true},
+	}
+
+	if len(results) != len(tests) {
+		t.Fatalf("结果数 = %d, want %d", len(results), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := results[i]
+			if r.Key != codes[i] {
+				t.Errorf("Key = %s, want %s", r.Key, codes[i])
+			}
+			if (r.Err != nil) != tt.wantErr {
+				t.Fatalf("Err = %v, wantErr %v", r.Err, tt.wantErr)
+			}
+			if !tt.wantErr && r.Value.Activated != tt.wantActivated {
+				t.Errorf("Activated = %v, want %v", r.Value.Activated, tt.wantActivated)
+			}
+		})
+	}
+}
+
+// TestClient_DoListAll 测试自动跟随分页读取全部条目
+func TestClient_DoListAll(t *testing.T) {
+	pages := map[string]string{
+		"":   `{"items": [1, 2], "next": "p2"}`,
+		"p2": `{"items": [3, 4], "next": "p3"}`,
+		"p3": `{"items": [5]}`,
+	}
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Method != http.MethodGet || r.URL.Path != "/api/items" {
+			t.Errorf("请求 = %s %s, want GET /api/items", r.Method, r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("limit") != "2" || q.Get("type") != "app" {
+			t.Errorf("查询参数 = %s, 缺少 limit 或 type", r.URL.RawQuery)
+		}
+		body, ok := pages[q.Get("cursor")]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "游标无效"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL))
+	opts := ListOptions{Limit: 2, Query: url.Values{"type": {"app"}}}
+
+	var got []int
+	err := client.DoListAll("/api/items", opts, func(raw json.RawMessage) error {
+		var items []int
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return err
+		}
+		got = append(got, items...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DoListAll 失败: %v", err)
+	}
+	if want := []int{1, 2, 3, 4, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("条目 = %v, want %v", got, want)
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("请求次数 = %d, want 3", n)
+	}
+}
+
+// TestClient_DoListAllPathQuery 测试路径自带查询串时与分页参数合并
+func TestClient_DoListAllPathQuery(t *testing.T) {
+	var queries []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/items" {
+			t.Errorf("路径 = %s, want /api/items", r.URL.Path)
+		}
+		q := r.URL.Query()
+		queries = append(queries, q)
+		w.Header().Set("Content-Type", "application/json")
+		if q.Get("cursor") == "" {
+			w.Write([]byte(`{"items": [1], "next": "p2"}`))
+			return
+		}
+		w.Write([]byte(`{"items": [2]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL))
+	opts := ListOptions{Limit: 1, Query: url.Values{"type": {"app"}}}
+	err := client.DoListAll("/api/items?a=1&type=old", opts, func(json.RawMessage) error { return nil })
+	if err != nil {
+		t.Fatalf("DoListAll 失败: %v", err)
+	}
+
+	if len(queries) != 2 {
+		t.Fatalf("请求次数 = %d, want 2", len(queries))
+	}
+	for i, q := range queries {
+		if q.Get("a") != "1" || q.Get("type") != "app" || q.Get("limit") != "1" {
+			t.Errorf("第 %d 页查询参数 = %v, want a=1 type=app limit=1", i+1, q)
+		}
+	}
+	if got := queries[1].Get("cursor"); got != "p2" {
+		t.Errorf("第 2 页 cursor = %q, want p2", got)
+	}
+}
+
+// TestClient_DoListAllStop 测试回调错误与分页上限
+func TestClient_DoListAllStop(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		// 游标永不结束
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"items": [], "next": "again"}`))
+	}))
+	defer server.Close()
+
+	errStop := errors.New("stop")
+	tests := []struct {
+		name         string
+		opts         []ClientOption
+		appendFn     func(raw json.RawMessage) error
+		wantErr      error
+		wantRequests int32
+	}{
+		{"回调错误", nil, func(json.RawMessage) error { return errStop }, errStop, 1},
+		{"分页上限", []ClientOption{WithMaxPages(3)}, func(json.RawMessage) error { return nil }, nil, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&requests, 0)
+			client := NewClient(append([]ClientOption{WithBaseURL(server.URL)}, tt.opts...)...)
+			err := client.DoListAll("/api/items", ListOptions{}, tt.appendFn)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("err = %v, want %v", err, tt.wantErr)
+				}
+			} else {
+				var ufErr *Error
+				if !errors.As(err, &ufErr) || ufErr.Code != ErrCodeRequestFailed {
+					t.Errorf("err = %v, 期望请求失败错误", err)
+				}
+			}
+			if n := atomic.LoadInt32(&requests); n != tt.wantRequests {
+				t.Errorf("请求次数 = %d, want %d", n, tt.wantRequests)
+			}
+		})
+	}
+}
+
+// TestClient_IsActivated 测试激活布尔值与失败时的默认值
+func TestClient_IsActivated(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ActivationCheckRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		w.Header().Set("Content-Type", "application/json")
+		switch req.MachineCode {
+		case "ACTIVE":
+			w.Write([]byte(`{"ok": true, "activated": true}`))
+		case "INACTIVE":
+			w.Write([]byte(`{"ok": true, "activated": false}`))
+		case "NOT_OK":
+			w.Write([]byte(`{"ok": false, "error": "软件不存在"}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error": "服务不可用"}`))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		baseURL     string
+		machineCode string
+		failOpen    bool
+		want        bool
+		wantCode    string
+	}{
+		{"已激活", server.URL, "ACTIVE", false, true, ""},
+		{"未激活", server.URL, "INACTIVE", true, false, ""},
+		{"服务器错误放行", server.URL, "ERROR", true, true, ErrCodeServerError},
+		{"服务器错误拒绝", server.URL, "ERROR", false, false, ErrCodeServerError},
+		{"响应失败放行", server.URL, "NOT_OK", true, true, ErrCodeServerError},
+		{"网络错误放行", "http://127.0.0.1:1", "ACTIVE", true, true, ErrCodeNetworkError},
+		{"网络错误拒绝", "http://127.0.0.1:1", "ACTIVE", false, false, ErrCodeNetworkError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(WithBaseURL(tt.baseURL))
+			got, err := client.IsActivated(1, tt.machineCode, tt.failOpen)
+			if got != tt.want {
+				t.Errorf("IsActivated() = %v, want %v", got, tt.want)
+			}
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Errorf("IsActivated() 错误 = %v", err)
+				}
+				return
+			}
+			var ufErr *Error
+			if !errors.As(err, &ufErr) || ufErr.Code != tt.wantCode {
+				t.Errorf("IsActivated() 错误 = %v, want code %s", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestClient_WithAcceptGzip(t *testing.T) {
+	var gotEncoding atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEncoding.Store(r.Header.Get("Accept-Encoding"))
+
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(`{"ok": true, "id": 42}`))
+		zw.Close()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithAcceptGzip())
+	resp, err := client.RecordActivity(1)
+	if err != nil {
+		t.Fatalf("RecordActivity() 错误 = %v", err)
+	}
+	if !resp.OK || resp.ID != 42 {
+		t.Errorf("RecordActivity() = %+v, want OK=true ID=42", resp)
+	}
+	if got := gotEncoding.Load(); got != "gzip" {
+		t.Errorf("Accept-Encoding = %v, want gzip", got)
+	}
+
+	// 自定义 Transport（演练模式）不会透明解压，由客户端解压
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(`{"ok": true, "activated": true}`))
+	zw.Close()
+	client = NewClient(WithAcceptGzip(), WithDryRun(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Encoding": {"gzip"}},
+			Body:       io.NopCloser(bytes.NewReader(buf.Bytes())),
+		}, nil
+	}))
+	check, err := client.CheckActivation(1, "ABC")
+	if err != nil {
+		t.Fatalf("CheckActivation() 错误 = %v", err)
+	}
+	if !check.Activated {
+		t.Errorf("CheckActivation().Activated = false, want true")
+	}
+}
+
+func TestClient_WithRequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithTimeout(5*time.Second))
+
+	// 单次请求的短超时独立于全局超时生效
+	ctx := WithRequestTimeout(context.Background(), 20*time.Millisecond)
+	_, err := client.RecordActivityContext(ctx, 1)
+	var ufErr *Error
+	if !errors.As(err, &ufErr) || ufErr.Code != ErrCodeTimeout {
+		t.Fatalf("短超时请求错误 = %v, want %s", err, ErrCodeTimeout)
+	}
+
+	// 共享客户端未被修改，默认请求仍按全局超时成功
+	if client.httpClient.Timeout != 5*time.Second {
+		t.Errorf("全局超时被修改为 %v", client.httpClient.Timeout)
+	}
+	if _, err := client.RecordActivityContext(context.Background(), 1); err != nil {
+		t.Errorf("默认请求错误 = %v", err)
+	}
+
+	// 单次请求的长超时可超过全局超时
+	short := NewClient(WithBaseURL(server.URL), WithTimeout(20*time.Millisecond))
+	if _, err := short.RecordActivityContext(WithRequestTimeout(context.Background(), 5*time.Second), 1); err != nil {
+		t.Errorf("长超时请求错误 = %v", err)
+	}
+}
+
+// TestClient_WithRequestTimeoutCoversRetry 测试单次超时覆盖整个调用，限流重试与等待不会重新计时
+func TestClient_WithRequestTimeoutCoversRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(30 * time.Millisecond)
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL), WithRetry(3))
+
+	start := time.Now()
+	_, err := client.RecordActivityContext(WithRequestTimeout(context.Background(), 100*time.Millisecond), 1)
+	elapsed := time.Since(start)
+
+	var ufErr *Error
+	if !errors.As(err, &ufErr) || ufErr.Code != ErrCodeTimeout {
+		t.Fatalf("错误 = %v, want %s", err, ErrCodeTimeout)
+	}
+	if elapsed > 500*time.Millisecond {
+		t.Errorf("调用耗时 %v，超过单次超时", elapsed)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("服务端收到 %d 次请求，want 1", n)
+	}
+}
+
+// ============================================================================
+// 服务门面测试
+// ============================================================================
+
+// TestNew_ServicesShareClient 测试门面的各服务共享同一客户端配置
+func TestNew_ServicesShareClient(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true, "activated": true}`))
+	}))
+	defer server.Close()
+
+	svc := New(WithBaseURL(server.URL), WithTimeout(7*time.Second))
+
+	if svc.Activity.client != svc.Client || svc.Activation.client != svc.Client {
+		t.Fatal("各服务应共享 Services.Client")
+	}
+	if svc.Client.baseURL != server.URL {
+		t.Errorf("baseURL = %q, want %q", svc.Client.baseURL, server.URL)
+	}
+	if svc.Client.httpClient.Timeout != 7*time.Second {
+		t.Errorf("Timeout = %v, want 7s", svc.Client.httpClient.Timeout)
+	}
+
+	if _, err := svc.Activity.Record(1); err != nil {
+		t.Fatalf("Activity.Record() 错误 = %v", err)
+	}
+	activated, err := svc.Activation.IsActivated(1, "ABC", false)
+	if err != nil || !activated {
+		t.Fatalf("Activation.IsActivated() = %v, %v, want true, nil", activated, err)
+	}
+
+	want := []string{"/api/activity", "/api/activation/check"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("请求路径 = %v, want %v", paths, want)
+	}
+}
+
+// ============================================================================
+// SoftwareID 测试
+// ============================================================================
+
+// TestSoftwareID_JSON 测试 SoftwareID 在请求中仍序列化为 JSON 数字
+func TestSoftwareID_JSON(t *testing.T) {
+	data, err := json.Marshal(&ActivationCheckRequest{SoftwareID: 18446744073709551615, MachineCode: "ABC"})
+	if err != nil {
+		t.Fatalf("Marshal() 错误 = %v", err)
+	}
+	want := `{"softwareId":18446744073709551615,"machineCode":"ABC"}`
+	if string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+
+	var req ActivityRequest
+	if err := json.Unmarshal([]byte(`{"softwareId": 42}`), &req); err != nil {
+		t.Fatalf("Unmarshal() 错误 = %v", err)
+	}
+	if req.SoftwareID != 42 {
+		t.Errorf("SoftwareID = %d, want 42", req.SoftwareID)
+	}
+	if got := SoftwareID(42).String(); got != "42" {
+		t.Errorf("String() = %q, want \"42\"", got)
+	}
+}
+
+// TestSoftwareID_TypedAPI 测试常量、显式转换与类型化变量均可传入
+func TestSoftwareID_TypedAPI(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, string(body))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithBaseURL(server.URL))
+
+	// 无类型常量
+	if _, err := client.RecordActivity(1); err != nil {
+		t.Fatalf("RecordActivity() 错误 = %v", err)
+	}
+	// 已有的 uint 变量显式转换
+	var legacy uint = 2
+	if _, err := client.CheckActivation(SoftwareID(legacy), "ABC"); err != nil {
+		t.Fatalf("CheckActivation() 错误 = %v", err)
+	}
+	// 类型化变量
+	id := SoftwareID(3)
+	if _, err := client.RecordActivityContext(context.Background(), id); err != nil {
+		t.Fatalf("RecordActivityContext() 错误 = %v", err)
+	}
+
+	want := []string{`{"softwareId":1}`, `{"softwareId":2,"machineCode":"ABC"}`, `{"softwareId":3}`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("请求体 = %v, want %v", got, want)
+	}
+}
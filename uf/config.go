@@ -5,6 +5,7 @@
 package uf
 
 import (
+	"context"
 	"net/http"
 	"strings"
 	"time"
@@ -17,6 +18,18 @@ const (
 
 	// DefaultTimeout 是默认请求超时时间
 	DefaultTimeout = 30 * time.Second
+
+	// DefaultRetryDelay 是 429 响应未携带有效 Retry-After 时的默认重试等待时间
+	DefaultRetryDelay = 1 * time.Second
+
+	// DefaultMaxRetryAfter 是限流重试前愿意等待的最长时间
+	DefaultMaxRetryAfter = 60 * time.Second
+
+	// DefaultBatchConcurrency 是批量方法的最大并发请求数
+	DefaultBatchConcurrency = 4
+
+	// DefaultMaxPages 是 DoListAll 默认最多跟随的页数
+	DefaultMaxPages = 100
 )
 
 // Config 客户端配置
@@ -88,3 +101,150 @@ func WithHTTPClient(client *http.Client) func(*Client) {
 		}
 	}
 }
+
+// WithRetry 设置限流重试次数的选项函数
+//
+// 参数 maxRetries 为收到 429 Too Many Requests 后的最大重试次数，默认 0（不重试）。
+// 每次重试前按响应的 Retry-After 头等待，未携带时等待 DefaultRetryDelay；
+// 等待期间请求上下文或 WithContext 基础上下文取消时立即返回。
+// 建议等待超过 WithMaxRetryAfter 上限（默认 DefaultMaxRetryAfter）时不再重试。
+// 重试耗尽后返回 *ErrRateLimited。
+func WithRetry(maxRetries int) func(*Client) {
+	return func(c *Client) {
+		if maxRetries > 0 {
+			c.maxRetries = maxRetries
+		}
+	}
+}
+
+// WithMaxRetryAfter 设置限流重试最长等待时间的选项函数
+//
+// 参数 d 为重试前愿意等待的最长时间，默认 DefaultMaxRetryAfter。
+// 服务端 Retry-After 超过 d 时不等待，直接返回 *ErrRateLimited，其 RetryAfter 为服务端建议的时长。
+func WithMaxRetryAfter(d time.Duration) func(*Client) {
+	return func(c *Client) {
+		if d > 0 {
+			c.maxWait = d
+		}
+	}
+}
+
+// WithRetryBudget 设置客户端共享重试预算的选项函数
+//
+// 参数 ratio 为重试次数与请求次数的最大比例（如 0.1 表示重试最多占请求量的 10%），
+// minPerSec 为每秒保底允许的重试次数，保证低流量时仍可重试。
+// 预算由同一客户端的所有请求共享，预算耗尽时不再重试并直接返回 *ErrRateLimited，
+// 避免服务端降级时各请求的重试叠加成重试风暴。需配合 WithRetry 使用。
+func WithRetryBudget(ratio float64, minPerSec int) func(*Client) {
+	return func(c *Client) {
+		if ratio < 0 {
+			ratio = 0
+		}
+		if minPerSec < 0 {
+			minPerSec = 0
+		}
+		c.budget = newRetryBudget(ratio, minPerSec)
+	}
+}
+
+// WithMaxPages 设置分页跟随上限的选项函数
+//
+// 参数 n 为 DoListAll 最多请求的页数，默认 DefaultMaxPages。
+// 服务端持续返回 next 游标（如游标不前进）时，超过上限即返回错误，避免无限循环。
+func WithMaxPages(n int) func(*Client) {
+	return func(c *Client) {
+		if n > 0 {
+			c.maxPages = n
+		}
+	}
+}
+
+// WithAcceptGzip 请求 gzip 压缩响应的选项函数
+//
+// 启用后请求携带 Accept-Encoding: gzip，响应为 Content-Encoding: gzip 时在解码前自动解压，
+// 大响应可节省带宽。Go 的 Transport 只在自行设置该请求头时才透明解压，
+// 显式设置后由客户端负责解压。
+func WithAcceptGzip() func(*Client) {
+	return func(c *Client) {
+		c.acceptGzip = true
+	}
+}
+
+// WithResponseValidator 设置响应校验函数的选项函数
+//
+// 参数 fn 在读取完响应体、解码之前调用，
+// 返回非 nil 错误时请求以 ErrCodeInvalidResponse 错误失败。
+// 可用于拒绝错误的内容类型或缺少关键字段的响应。
+func WithResponseValidator(fn func(resp *RawResponse) error) func(*Client) {
+	return func(c *Client) {
+		if fn != nil {
+			c.validator = fn
+		}
+	}
+}
+
+// WithDryRun 设置演练模式的选项函数
+//
+// 参数 fn 拦截所有请求并返回调用方构造的响应，不会访问网络。
+// fn 收到的是完整构造的请求（方法、URL、请求头、请求体），
+// 适合在对接新集成时检查请求构造。
+// 与 WithHTTPClient 同时使用时，以后应用的选项为准。
+func WithDryRun(fn func(req *http.Request) (*http.Response, error)) func(*Client) {
+	return func(c *Client) {
+		if fn != nil {
+			c.httpClient = &http.Client{
+				Transport: roundTripFunc(fn),
+				Timeout:   c.httpClient.Timeout,
+			}
+		}
+	}
+}
+
+// roundTripFunc 将函数适配为 http.RoundTripper
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip 实现 http.RoundTripper 接口
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// WithUseNumber 设置数字解码方式的选项函数
+//
+// 启用后，解码到 interface{} 或 map 等动态类型时，JSON 数字保留为 json.Number，
+// 避免超过 2^53 的大 ID 转为 float64 后丢失精度。
+// 对强类型整数字段（如 ActivityResponse.ID）无影响，它们始终精确解码。
+func WithUseNumber() func(*Client) {
+	return func(c *Client) {
+		c.useNumber = true
+	}
+}
+
+// WithContext 设置客户端基础上下文的选项函数
+//
+// 所有请求的上下文都从 ctx 派生：ctx 被取消或超时后，进行中的请求立即中止，
+// 之后发起的请求直接失败，不会访问网络。
+// 与 RecordActivityContext 等方法传入的请求级上下文同时生效，任一方结束即取消请求。
+// 适合长期运行的 worker 在退出时统一停止所有 UF 调用。
+func WithContext(ctx context.Context) func(*Client) {
+	return func(c *Client) {
+		if ctx != nil {
+			c.baseCtx = ctx
+		}
+	}
+}
+
+// requestTimeoutKey 单次请求超时在上下文中的键
+type requestTimeoutKey struct{}
+
+// WithRequestTimeout 返回携带单次请求超时的上下文
+//
+// 传给 RecordActivityContext 等上下文方法后，该次调用（DoListAllContext 为每一页）整体使用
+// timeout 作为截止时间，限流重试与 Retry-After 等待均计入其中；代替客户端的全局超时，
+// 可长于或短于全局值，不会修改共享的客户端。
+// 超时返回 ErrCodeTimeout 错误。timeout <= 0 时沿用全局超时。
+//
+//	ctx := uf.WithRequestTimeout(ctx, 2*time.Minute) // 批量导出等慢接口
+//	err := client.DoListAllContext(ctx, "/api/export", opts, appendFn)
+func WithRequestTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, requestTimeoutKey{}, timeout)
+}
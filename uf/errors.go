@@ -2,6 +2,7 @@ package uf
 
 import (
 	"fmt"
+	"time"
 )
 
 // ErrorCode 错误码常量
@@ -25,6 +26,9 @@ const (
 
 	// ErrCodeInvalidParams 表示参数错误
 	ErrCodeInvalidParams = "INVALID_PARAMS"
+
+	// ErrCodeRateLimited 表示请求被限流（HTTP 429）
+	ErrCodeRateLimited = "RATE_LIMITED"
 )
 
 // Error UF 服务错误结构
@@ -110,3 +114,40 @@ func NewServerError(message string) *Error {
 func NewParamsError(message string) *Error {
 	return NewError(ErrCodeInvalidParams, message, nil)
 }
+
+// ErrRateLimited 限流错误
+//
+// 当 UF 服务返回 429 Too Many Requests 时返回该错误。
+// RetryAfter 为服务端通过 Retry-After 头建议的等待时长，未提供时为 0。
+// 可通过 errors.As 同时解包为 *ErrRateLimited 或 *Error。
+type ErrRateLimited struct {
+	// Err 底层错误，错误码为 ErrCodeRateLimited
+	Err *Error
+
+	// RetryAfter 建议的重试等待时长
+	RetryAfter time.Duration
+}
+
+// Error 实现 error 接口
+//
+// 返回格式化的错误信息，包含建议的重试等待时长。
+func (e *ErrRateLimited) Error() string {
+	return fmt.Sprintf("%s (Retry-After: %v)", e.Err.Error(), e.RetryAfter)
+}
+
+// Unwrap 返回底层的 *Error
+//
+// 使 errors.As(err, &ufErr) 仍可获取错误码和错误信息。
+func (e *ErrRateLimited) Unwrap() error {
+	return e.Err
+}
+
+// NewRateLimitedError 创建限流错误
+//
+// 参数 retryAfter 为服务端建议的重试等待时长。
+func NewRateLimitedError(message string, retryAfter time.Duration) *ErrRateLimited {
+	return &ErrRateLimited{
+		Err:        NewError(ErrCodeRateLimited, message, nil),
+		RetryAfter: retryAfter,
+	}
+}
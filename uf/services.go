@@ -0,0 +1,84 @@
+package uf
+
+import "context"
+
+// Services 聚合 UF 各服务的门面
+//
+// 各服务字段共享同一个底层 Client，因此共享 BaseURL、超时、重试等全部配置。
+// 需要更底层的控制（如 DoListAll）时可直接使用 Client 字段。
+type Services struct {
+	// Client 底层客户端
+	Client *Client
+
+	// Activity 软件活跃度服务
+	Activity *ActivityService
+
+	// Activation 软件激活服务
+	Activation *ActivationService
+}
+
+// New 创建聚合所有服务的门面
+//
+// 选项与 NewClient 相同，所有服务共享由这些选项创建的同一个 Client。
+//
+//	svc := uf.New(uf.WithTimeout(10 * time.Second))
+//	svc.Activity.Record(1)
+//	svc.Activation.Check(1, "ABC-123-XYZ")
+func New(opts ...ClientOption) *Services {
+	client := NewClient(opts...)
+	return &Services{
+		Client:     client,
+		Activity:   &ActivityService{client: client},
+		Activation: &ActivationService{client: client},
+	}
+}
+
+// ActivityService 软件活跃度服务
+type ActivityService struct {
+	client *Client
+}
+
+// Record 记录软件活跃度，等同于 Client.RecordActivity
+func (s *ActivityService) Record(softwareId SoftwareID) (*ActivityResponse, error) {
+	return s.client.RecordActivity(softwareId)
+}
+
+// RecordContext 使用指定上下文记录软件活跃度，等同于 Client.RecordActivityContext
+func (s *ActivityService) RecordContext(ctx context.Context, softwareId SoftwareID) (*ActivityResponse, error) {
+	return s.client.RecordActivityContext(ctx, softwareId)
+}
+
+// RecordBatch 并发记录多个软件的活跃度，等同于 Client.RecordActivityBatch
+func (s *ActivityService) RecordBatch(ctx context.Context, softwareIds []SoftwareID) []ItemResult[*ActivityResponse] {
+	return s.client.RecordActivityBatch(ctx, softwareIds)
+}
+
+// ActivationService 软件激活服务
+type ActivationService struct {
+	client *Client
+}
+
+// Check 检查软件激活状态，等同于 Client.CheckActivation
+func (s *ActivationService) Check(softwareId SoftwareID, machineCode string) (*ActivationCheckResponse, error) {
+	return s.client.CheckActivation(softwareId, machineCode)
+}
+
+// CheckContext 使用指定上下文检查软件激活状态，等同于 Client.CheckActivationContext
+func (s *ActivationService) CheckContext(ctx context.Context, softwareId SoftwareID, machineCode string) (*ActivationCheckResponse, error) {
+	return s.client.CheckActivationContext(ctx, softwareId, machineCode)
+}
+
+// CheckMany 并发检查多个机器码的激活状态，等同于 Client.CheckActivationMany
+func (s *ActivationService) CheckMany(ctx context.Context, softwareId SoftwareID, machineCodes []string) []ItemResult[*ActivationCheckResponse] {
+	return s.client.CheckActivationMany(ctx, softwareId, machineCodes)
+}
+
+// IsActivated 检查软件是否已激活，等同于 Client.IsActivated
+func (s *ActivationService) IsActivated(softwareId SoftwareID, machineCode string, failOpen bool) (bool, error) {
+	return s.client.IsActivated(softwareId, machineCode, failOpen)
+}
+
+// IsActivatedContext 使用指定上下文检查软件是否已激活，等同于 Client.IsActivatedContext
+func (s *ActivationService) IsActivatedContext(ctx context.Context, softwareId SoftwareID, machineCode string, failOpen bool) (bool, error) {
+	return s.client.IsActivatedContext(ctx, softwareId, machineCode, failOpen)
+}
@@ -1,5 +1,12 @@
 package uf
 
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
 // Response 通用响应结构
 //
 // 所有 API 调用返回的通用响应格式。
@@ -52,6 +59,73 @@ func NewErrorResponse(message string) *ErrorResponse {
 	}
 }
 
+// RawResponse 原始 HTTP 响应
+//
+// 在响应体解码前传递给 WithResponseValidator 注册的校验函数，
+// 用于检查内容类型、必需字段等。
+type RawResponse struct {
+	// StatusCode HTTP 状态码
+	StatusCode int
+
+	// Header 响应头
+	Header http.Header
+
+	// Body 已读取的完整响应体
+	Body []byte
+}
+
+// ItemResult 批量操作中单个条目的结果
+//
+// Key 标识条目（如软件 ID 或机器码），Value 为成功时的响应，
+// Err 为该条目的错误；各条目的成败互不影响。
+type ItemResult[T any] struct {
+	// Key 条目标识
+	Key string
+
+	// Value 条目响应，Err 不为 nil 时可能为零值
+	Value T
+
+	// Err 条目错误，成功时为 nil
+	Err error
+}
+
+// ListOptions 列表请求参数
+//
+// 用于 DoListAll 等分页列表方法。
+type ListOptions struct {
+	// Limit 每页条目数，作为 limit 查询参数发送，0 表示使用服务端默认值
+	Limit int
+
+	// Cursor 起始游标，作为 cursor 查询参数发送，为空时从第一页开始
+	Cursor string
+
+	// Query 额外的查询参数，每页请求都会携带
+	Query url.Values
+}
+
+// ListPage 列表接口的分页响应
+//
+// Next 为空表示已是最后一页。
+type ListPage struct {
+	// Items 当前页条目，保持原始 JSON 由调用方解码
+	Items json.RawMessage `json:"items"`
+
+	// Next 下一页游标
+	Next string `json:"next,omitempty"`
+}
+
+// SoftwareID 软件 ID
+//
+// 以独立类型区分软件 ID 与其他数字参数，避免误传用户 ID 等其他数值；
+// 底层为 uint64 以保证在 32 位平台上也不会截断大 ID，JSON 中仍为数字。
+// 常量可直接传入（如 client.RecordActivity(1)），uint 等变量需显式转换：uf.SoftwareID(id)。
+type SoftwareID uint64
+
+// String 返回软件 ID 的十进制字符串
+func (id SoftwareID) String() string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
 // ============================================================================
 // 活跃度记录相关类型
 // ============================================================================
@@ -61,7 +135,7 @@ func NewErrorResponse(message string) *ErrorResponse {
 // 用于创建或更新软件的活跃度记录。
 type ActivityRequest struct {
 	// SoftwareID 软件 ID
-	SoftwareID uint `json:"softwareId"`
+	SoftwareID SoftwareID `json:"softwareId"`
 }
 
 // ActivityResponse 活跃度记录响应
@@ -75,8 +149,8 @@ type ActivityResponse struct {
 
 	// ID 活跃度记录 ID
 	//
-	// 仅在 OK 为 true 时存在
-	ID uint `json:"id,omitempty"`
+	// 仅在 OK 为 true 时存在，使用 uint64 以保证大 ID 不丢失精度
+	ID uint64 `json:"id,omitempty"`
 
 	// Error 错误信息
 	//
@@ -107,7 +181,7 @@ func (r *ActivityResponse) HasError() bool {
 // 用于检查软件是否已激活及激活状态。
 type ActivationCheckRequest struct {
 	// SoftwareID 软件 ID
-	SoftwareID uint `json:"softwareId"`
+	SoftwareID SoftwareID `json:"softwareId"`
 
 	// MachineCode 机器码
 	MachineCode string `json:"machineCode"`